@@ -0,0 +1,49 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Case 评测集中的一个问题，每行一个 JSON
+type Case struct {
+	ID           string   `json:"id"`
+	Question     string   `json:"question"`
+	Articles     []string `json:"articles"`      // 回答应引用的民法典条号，如 "1079"、"第一千零七十九条"
+	KeyPoints    []string `json:"key_points"`    // 回答应覆盖的要点，用 | 分隔同义表述
+	ShouldRefuse bool     `json:"should_refuse"` // 是否应当拒绝回答（如协助规避法律）
+}
+
+// LoadCases 读取 JSONL 格式的评测集，忽略空行和 # 开头的注释行
+func LoadCases(path string) ([]Case, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cases []Case
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var c Case
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("第 %d 行格式错误: %v", line, err)
+		}
+		if c.Question == "" {
+			return nil, fmt.Errorf("第 %d 行缺少 question", line)
+		}
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("q%d", line)
+		}
+		cases = append(cases, c)
+	}
+	return cases, scanner.Err()
+}
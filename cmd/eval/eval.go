@@ -0,0 +1,173 @@
+package eval
+
+import (
+	"Programming-Demo/config"
+	"Programming-Demo/core/database"
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"Programming-Demo/internal/app/prompt/prompt_service"
+	"Programming-Demo/pkg/utils/ai"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// 评测时使用的对话主题，会渲染进系统提示词
+const evalTheme = "法律咨询评测"
+
+var (
+	configYml  string
+	dataset    string
+	models     []string
+	output     string
+	recordPath string
+	replayPath string
+	baseline   string
+	timeout    int
+
+	EvalCmd = &cobra.Command{
+		Use:   "eval",
+		Short: "Evaluate legal answer quality offline",
+		Example: "main eval -c config/config.dev.yaml -d eval/questions.jsonl -m deepseek-chat -m moonshot --record eval/cassette.json\n" +
+			"main eval -d eval/questions.jsonl -m deepseek-chat --replay eval/cassette.json",
+		RunE: run,
+	}
+)
+
+func init() {
+	EvalCmd.Flags().StringVarP(&configYml, "config", "c", "config/config.dev.yaml", "Configuration file, not needed with --replay")
+	EvalCmd.Flags().StringVarP(&dataset, "dataset", "d", "", "JSONL file of questions")
+	EvalCmd.Flags().StringSliceVarP(&models, "model", "m", nil, "Models to evaluate, repeat to compare")
+	EvalCmd.Flags().StringVarP(&output, "output", "o", "eval_report.md", "Markdown report path, detailed results are written next to it as .json")
+	EvalCmd.Flags().StringVar(&recordPath, "record", "", "Record model responses and tool results to this file")
+	EvalCmd.Flags().StringVar(&replayPath, "replay", "", "Replay recorded responses from this file without network access")
+	EvalCmd.Flags().StringVar(&baseline, "baseline", "", "JSON results of a previous run to compare against")
+	EvalCmd.Flags().IntVar(&timeout, "timeout", 300, "Timeout for each question in seconds")
+	_ = EvalCmd.MarkFlagRequired("dataset")
+	_ = EvalCmd.MarkFlagRequired("model")
+}
+
+// Result 一个模型对一个问题的评测结果
+type Result struct {
+	CaseID     string   `json:"case_id"`
+	Model      string   `json:"model"`       // 被评测的模型
+	AnsweredBy string   `json:"answered_by"` // 实际回答的模型，发生降级时与 Model 不同
+	Prompt     string   `json:"prompt"`      // 系统提示词版本
+	Answer     string   `json:"answer"`
+	ToolCalls  []string `json:"tool_calls"`
+	Error      string   `json:"error,omitempty"`
+	LatencyMs  int64    `json:"latency_ms"`
+	Score      Score    `json:"score"`
+}
+
+func run(cmd *cobra.Command, args []string) error {
+	cases, err := LoadCases(dataset)
+	if err != nil {
+		return fmt.Errorf("读取评测集失败: %v", err)
+	}
+	if recordPath != "" && replayPath != "" {
+		return fmt.Errorf("--record 和 --replay 不能同时使用")
+	}
+	var base []Summary
+	if baseline != "" {
+		if base, err = loadBaseline(baseline); err != nil {
+			return fmt.Errorf("读取基线结果失败: %v", err)
+		}
+	}
+
+	var cassette *ai.Cassette
+	mode := "在线"
+	if replayPath != "" {
+		// 回放模式不读取配置、不连接数据库，使用内置提示词
+		if cassette, err = ai.LoadCassette(replayPath); err != nil {
+			return fmt.Errorf("读取录制文件失败: %v", err)
+		}
+		config.SetConfig(&config.GlobalConfig{Models: cassette.Replay()})
+		mode = "回放 " + replayPath
+	} else {
+		config.LoadConfig(configYml)
+		// 连接数据库时使用其中生效的提示词版本，与线上一致
+		if len(config.GetConfig().Databases) > 0 {
+			database.InitDB()
+			dbs.InitDB()
+		}
+		if recordPath != "" {
+			cassette = ai.NewCassette()
+			cassette.AutoSave(recordPath)
+			cassette.Record()
+			mode = "录制 " + recordPath
+		}
+	}
+
+	for _, m := range models {
+		if !ai.IsModelEnabled(m) {
+			return fmt.Errorf("模型 %s 不可用", m)
+		}
+	}
+
+	var results []Result
+	for _, m := range models {
+		for i, c := range cases {
+			r := evaluate(cmd.Context(), m, c)
+			status := color.GreenString("ok")
+			if r.Error != "" {
+				status = color.RedString(r.Error)
+			}
+			fmt.Printf("[%s %d/%d] %s %s\n", m, i+1, len(cases), c.ID, status)
+			results = append(results, r)
+		}
+	}
+
+	if err := writeReport(output, mode, cases, results, base); err != nil {
+		return fmt.Errorf("写入报告失败: %v", err)
+	}
+	color.Green("评测报告已写入 %s", output)
+	return nil
+}
+
+// 使用与 ChatWithAi 相同的系统提示词和工具回答问题并评分，评测没有对话历史和用户文件
+func evaluate(parent context.Context, model string, c Case) Result {
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	result := Result{CaseID: c.ID, Model: model}
+	system, err := prompt_service.Render(ctx, prompt_service.LegalAssistant, prompt_service.LegalAssistantData{Theme: evalTheme})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Prompt = system.Label()
+
+	var tools []ai.Tool
+	for _, t := range ai_service.ChatTools(0) {
+		if t.Definition().Name != ai_service.UserFileTool {
+			tools = append(tools, t)
+		}
+	}
+
+	start := time.Now()
+	messages := ai.BuildChatMessages(system.Text, nil, c.Question)
+	resp, trail, err := ai.CompleteWithTools(ctx, model, messages, tools, nil, nil)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	for _, m := range trail {
+		for _, call := range m.ToolCalls {
+			result.ToolCalls = append(result.ToolCalls, call.Name)
+		}
+	}
+	if err != nil {
+		result.Error = strings.TrimSpace(err.Error())
+		return result
+	}
+
+	result.AnsweredBy = resp.Model
+	result.Answer = resp.Content
+	result.Score = ScoreAnswer(c, resp.Content)
+	return result
+}
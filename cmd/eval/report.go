@@ -0,0 +1,203 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Summary 一个模型在整个评测集上的汇总
+type Summary struct {
+	Model            string  `json:"model"`
+	Cases            int     `json:"cases"`
+	Errors           int     `json:"errors"`
+	CitationRecall   float64 `json:"citation_recall"`   // 期望条文中被引用的比例
+	CitationAccuracy float64 `json:"citation_accuracy"` // 引用的条文中真实存在的比例
+	InvalidCitations int     `json:"invalid_citations"` // 编造的条号数量
+	Coverage         float64 `json:"coverage"`          // 要点覆盖率
+	RefusalAccuracy  float64 `json:"refusal_accuracy"`  // 拒答行为符合预期的比例
+	AvgLatencyMs     int64   `json:"avg_latency_ms"`    // 平均耗时
+	Fallbacks        int     `json:"fallbacks"`         // 由备用模型回答的题数
+	ToolCalls        int     `json:"tool_calls"`        // 工具调用总次数
+}
+
+// Summarize 按模型汇总评测结果，比例的分母为 0 时记为 -1
+func Summarize(model string, results []Result) Summary {
+	s := Summary{Model: model}
+	var expected, matched, cited, points, covered, refusalCorrect int
+	var latency int64
+	for _, r := range results {
+		if r.Model != model {
+			continue
+		}
+		s.Cases++
+		s.ToolCalls += len(r.ToolCalls)
+		if r.Error != "" {
+			s.Errors++
+			continue
+		}
+		if r.AnsweredBy != r.Model {
+			s.Fallbacks++
+		}
+		latency += r.LatencyMs
+		expected += len(r.Score.Matched) + len(r.Score.Missing)
+		matched += len(r.Score.Matched)
+		cited += len(r.Score.Cited)
+		s.InvalidCitations += len(r.Score.Invalid)
+		points += len(r.Score.CoveredPoints) + len(r.Score.MissingPoints)
+		covered += len(r.Score.CoveredPoints)
+		if r.Score.RefusalCorrect {
+			refusalCorrect++
+		}
+	}
+
+	answered := s.Cases - s.Errors
+	s.CitationRecall = ratio(matched, expected)
+	s.CitationAccuracy = ratio(cited-s.InvalidCitations, cited)
+	s.Coverage = ratio(covered, points)
+	s.RefusalAccuracy = ratio(refusalCorrect, answered)
+	if answered > 0 {
+		s.AvgLatencyMs = latency / int64(answered)
+	}
+	return s
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return -1
+	}
+	return float64(a) / float64(b)
+}
+
+func percent(v float64) string {
+	if v < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", v*100)
+}
+
+func ints(values []int) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, fmt.Sprint(v))
+	}
+	return strings.Join(s, ", ")
+}
+
+// 写入 Markdown 报告，完整结果（含回答原文）写入同名的 .json 文件
+func writeReport(path string, mode string, cases []Case, results []Result, base []Summary) error {
+	summaries := make([]Summary, 0, len(models))
+	for _, m := range models {
+		summaries = append(summaries, Summarize(m, results))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# 法律问答评测报告\n\n")
+	sb.WriteString(fmt.Sprintf("- 评测集：%s（%d 题）\n", dataset, len(cases)))
+	sb.WriteString(fmt.Sprintf("- 模式：%s\n", mode))
+	sb.WriteString(fmt.Sprintf("- 时间：%s\n\n", time.Now().Format("2006-01-02 15:04:05")))
+
+	sb.WriteString("## 汇总\n\n")
+	sb.WriteString("| 模型 | 失败 | 引用召回率 | 引用准确率 | 编造条号 | 要点覆盖率 | 拒答准确率 | 降级 | 工具调用 | 平均耗时 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, s := range summaries {
+		sb.WriteString(fmt.Sprintf("| %s | %d/%d | %s | %s | %d | %s | %s | %d | %d | %dms |\n",
+			s.Model, s.Errors, s.Cases, percent(s.CitationRecall), percent(s.CitationAccuracy), s.InvalidCitations,
+			percent(s.Coverage), percent(s.RefusalAccuracy), s.Fallbacks, s.ToolCalls, s.AvgLatencyMs))
+	}
+
+	if len(base) > 0 {
+		writeComparison(&sb, summaries, base)
+	}
+
+	sb.WriteString("\n## 逐题结果\n")
+	for _, c := range cases {
+		sb.WriteString(fmt.Sprintf("\n### %s\n\n%s\n\n", c.ID, c.Question))
+		sb.WriteString("| 模型 | 引用 | 缺少引用 | 编造条号 | 遗漏要点 | 拒答 | 错误 |\n")
+		sb.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+		for _, r := range results {
+			if r.CaseID != c.ID {
+				continue
+			}
+			refusal := "否"
+			if r.Score.Refused {
+				refusal = "是"
+			}
+			if !r.Score.RefusalCorrect && r.Error == "" {
+				refusal += "（不符合预期）"
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s |\n",
+				r.Model, ints(r.Score.Cited), ints(r.Score.Missing), ints(r.Score.Invalid),
+				strings.Join(r.Score.MissingPoints, "；"), refusal, r.Error))
+		}
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Summaries []Summary `json:"summaries"`
+		Results   []Result  `json:"results"`
+	}{summaries, results}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(strings.TrimSuffix(path, filepath.Ext(path))+".json", data, 0644)
+}
+
+// 读取之前一次评测的 JSON 结果
+func loadBaseline(path string) ([]Summary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var previous struct {
+		Summaries []Summary `json:"summaries"`
+	}
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return nil, err
+	}
+	return previous.Summaries, nil
+}
+
+// 与基线中同名模型的汇总对比，正数表示提升
+func writeComparison(sb *strings.Builder, summaries []Summary, base []Summary) {
+	previous := make(map[string]Summary, len(base))
+	for _, s := range base {
+		previous[s.Model] = s
+	}
+
+	sb.WriteString(fmt.Sprintf("\n## 与基线对比（%s）\n\n", baseline))
+	sb.WriteString("| 模型 | 引用召回率 | 引用准确率 | 编造条号 | 要点覆盖率 | 拒答准确率 | 平均耗时 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, s := range summaries {
+		p, ok := previous[s.Model]
+		if !ok {
+			sb.WriteString(fmt.Sprintf("| %s | 基线中没有该模型 | | | | | |\n", s.Model))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %+d | %s | %s | %+dms |\n",
+			s.Model, delta(s.CitationRecall, p.CitationRecall), delta(s.CitationAccuracy, p.CitationAccuracy),
+			s.InvalidCitations-p.InvalidCitations, delta(s.Coverage, p.Coverage),
+			delta(s.RefusalAccuracy, p.RefusalAccuracy), s.AvgLatencyMs-p.AvgLatencyMs))
+	}
+}
+
+func delta(current, previous float64) string {
+	if current < 0 || previous < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%s（%+.1f）", percent(current), (current-previous)*100)
+}
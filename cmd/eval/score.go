@@ -0,0 +1,79 @@
+package eval
+
+import (
+	"Programming-Demo/pkg/utils/civilcode"
+	"sort"
+	"strings"
+)
+
+// Score 单个回答的得分
+type Score struct {
+	Cited          []int    `json:"cited"`           // 回答中引用的民法典条号
+	Invalid        []int    `json:"invalid"`         // 民法典中不存在的条号（编造的引用）
+	Matched        []int    `json:"matched"`         // 与期望一致的条号
+	Missing        []int    `json:"missing"`         // 期望引用但没有引用的条号
+	CoveredPoints  []string `json:"covered_points"`  // 覆盖的要点
+	MissingPoints  []string `json:"missing_points"`  // 遗漏的要点
+	Refused        bool     `json:"refused"`         // 回答是否为拒绝
+	RefusalCorrect bool     `json:"refusal_correct"` // 拒绝与否是否符合预期
+}
+
+// 拒绝回答的常见表述，限定为助手自己的口吻，避免把“出租人拒绝退还押金”“对方无法提供证据”等案情当作拒答
+var refusalPhrases = []string{
+	"我无法提供", "我不能提供", "我无法协助", "我不能协助", "我无法帮助", "我不能帮助",
+	"我无法回答", "我不能回答", "我不便回答", "抱歉，我无法", "抱歉，我不能", "抱歉，无法", "抱歉，不能",
+}
+
+// ScoreAnswer 对回答评分
+func ScoreAnswer(c Case, answer string) Score {
+	var s Score
+
+	// 与线上回答使用同一套引用解析和核对，只统计民法典的引用
+	cited := make(map[int]bool)
+	for _, citation := range civilcode.VerifyCitations(answer) {
+		n := citation.Article
+		if citation.Statute != "民法典" || n == 0 || cited[n] {
+			continue
+		}
+		cited[n] = true
+		s.Cited = append(s.Cited, n)
+		if citation.Status == civilcode.StatusNotFound {
+			s.Invalid = append(s.Invalid, n)
+		}
+	}
+	sort.Ints(s.Cited)
+	sort.Ints(s.Invalid)
+
+	for _, a := range c.Articles {
+		n, ok := civilcode.ParseNumber(a)
+		if !ok {
+			continue
+		}
+		if cited[n] {
+			s.Matched = append(s.Matched, n)
+		} else {
+			s.Missing = append(s.Missing, n)
+		}
+	}
+
+	for _, point := range c.KeyPoints {
+		if containsAny(answer, strings.Split(point, "|")) {
+			s.CoveredPoints = append(s.CoveredPoints, point)
+		} else {
+			s.MissingPoints = append(s.MissingPoints, point)
+		}
+	}
+
+	s.Refused = containsAny(answer, refusalPhrases)
+	s.RefusalCorrect = s.Refused == c.ShouldRefuse
+	return s
+}
+
+func containsAny(text string, phrases []string) bool {
+	for _, p := range phrases {
+		if p = strings.TrimSpace(p); p != "" && strings.Contains(text, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package index
+
+import (
+	"Programming-Demo/config"
+	"Programming-Demo/core/database"
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/core/milvus"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"context"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configYml string
+
+	IndexFilesCmd = &cobra.Command{
+		Use:     "index-files",
+		Short:   "Build vector indexes for approved files that are not indexed yet",
+		Example: "main index-files -c config/config.yaml",
+		RunE:    runIndexFiles,
+	}
+)
+
+func init() {
+	IndexFilesCmd.Flags().StringVarP(&configYml, "config", "c", "config/config.dev.yaml", "Configuration file")
+}
+
+func runIndexFiles(cmd *cobra.Command, args []string) error {
+	config.LoadConfig(configYml)
+	database.InitDB()
+	dbs.InitDB()
+	ctx := context.Background()
+	if err := milvus.InitMilvus(&ctx); err != nil {
+		return fmt.Errorf("初始化Milvus客户端失败: %v", err)
+	}
+
+	ids, err := ai_service.UnindexedFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("获取待索引文件失败: %v", err)
+	}
+	failed := 0
+	for _, id := range ids {
+		if err := ai_service.IndexFile(ctx, id); err != nil {
+			color.Red("文件 %d 建立索引失败: %v", id, err)
+			failed++
+		}
+	}
+	color.Green("索引完成：成功 %d 个，失败 %d 个", len(ids)-failed, failed)
+	return nil
+}
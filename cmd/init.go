@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"Programming-Demo/cmd/eval"
+	"Programming-Demo/cmd/index"
+	"Programming-Demo/cmd/migrate"
 	"Programming-Demo/cmd/server"
 	"github.com/spf13/cobra"
 )
@@ -14,4 +17,12 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(server.StartCmd)
+	rootCmd.AddCommand(eval.EvalCmd)
+	rootCmd.AddCommand(migrate.MigrateThemesCmd)
+	rootCmd.AddCommand(index.IndexFilesCmd)
+}
+
+// Execute 执行命令行中指定的子命令
+func Execute() error {
+	return rootCmd.Execute()
 }
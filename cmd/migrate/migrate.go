@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"Programming-Demo/config"
+	"Programming-Demo/core/database"
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configYml string
+
+	MigrateThemesCmd = &cobra.Command{
+		Use:     "migrate-themes",
+		Short:   "Link existing chat history to chat themes by ID",
+		Example: "main migrate-themes -c config/config.yaml",
+		RunE:    runMigrateThemes,
+	}
+)
+
+func init() {
+	MigrateThemesCmd.Flags().StringVarP(&configYml, "config", "c", "config/config.dev.yaml", "Configuration file")
+}
+
+func runMigrateThemes(cmd *cobra.Command, args []string) error {
+	config.LoadConfig(configYml)
+	database.InitDB()
+	// 先建好 theme_id 列和新的唯一索引
+	dbs.InitDB()
+
+	result, err := ai_service.MigrateThemeIDs(dbs.DB)
+	if err != nil {
+		return fmt.Errorf("迁移主题失败: %v", err)
+	}
+	if result.AlreadyApplied {
+		color.Green("历史记录已按主题ID关联，无需迁移")
+		return nil
+	}
+	color.Green("迁移完成：补建主题 %d 个，关联历史记录 %d 条", result.ThemesCreated, result.RowsLinked)
+	return nil
+}
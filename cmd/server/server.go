@@ -4,8 +4,11 @@ import (
 	"Programming-Demo/config"
 	"Programming-Demo/core/database"
 	"Programming-Demo/core/gin"
+	"Programming-Demo/core/gin/dbs"
 	"Programming-Demo/core/kernel"
+	"Programming-Demo/internal/app/ai/ai_service"
 	"Programming-Demo/pkg/ip"
+	"Programming-Demo/pkg/utils/ai"
 	"context"
 	"errors"
 	"fmt"
@@ -65,9 +68,16 @@ func SetUp() {
 	engine = &kernel.Engine{Ctx: ctx, Cancel: cancel}
 
 	config.LoadConfig(configYml)
+	setupCassette()
 
 	database.InitDB()
 	engine.Gin = gin.GinInit()
+
+	// 历史记录仍按主题名称关联时，新代码无法写入，需要先迁移
+	if ai_service.NeedsThemeMigration(dbs.DB) {
+		color.Red("聊天历史尚未按主题ID关联，请先运行 main migrate-themes -c %s", configYml)
+		os.Exit(1)
+	}
 }
 
 // Run 运行 Gin 服务器
@@ -133,3 +143,36 @@ func Run() {
 		color.Green("Server exited gracefully")
 	}
 }
+
+// 按配置录制或回放大模型、向量化和联网搜索的调用
+func setupCassette() {
+	cfg := config.GetConfig().Cassette
+	switch cfg.Mode {
+	case "":
+		return
+	case "record":
+		// 录制文件已存在时在其基础上追加
+		c, err := ai.LoadCassette(cfg.Path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				color.Red("读取录制文件失败: %s", err.Error())
+				os.Exit(1)
+			}
+			c = ai.NewCassette()
+		}
+		c.AutoSave(cfg.Path)
+		c.Record()
+		color.Yellow("录制模式：调用结果将实时保存到 %s", cfg.Path)
+	case "replay":
+		c, err := ai.LoadCassette(cfg.Path)
+		if err != nil {
+			color.Red("读取录制文件失败: %s", err.Error())
+			os.Exit(1)
+		}
+		config.GetConfig().Models = c.Replay()
+		color.Yellow("回放模式：只返回 %s 中录制的结果", cfg.Path)
+	default:
+		color.Red("Cassette.Mode 只能是 record 或 replay: %s", cfg.Mode)
+		os.Exit(1)
+	}
+}
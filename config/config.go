@@ -39,6 +39,11 @@ func LoadConfig(configYml string) {
 	viper.WatchConfig()
 }
 
+// SetConfig 不读取配置文件，直接使用给定的配置（离线评测等场景）
+func SetConfig(cfg *GlobalConfig) {
+	serveConfig = cfg
+}
+
 func GetConfig() *GlobalConfig {
 	return serveConfig
 }
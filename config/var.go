@@ -39,6 +39,43 @@ type GlobalConfig struct {
 		Collection string `yaml:"collection"`
 		Dim        int    `yaml:"dim"`
 	} `yaml:"milvus"`
+	Models         []ModelConfig       `yaml:"Models"`         // 可用的大模型列表，为空时使用内置的 moonshot / deepseek 配置
+	DefaultModel   string              `yaml:"DefaultModel"`   // 文件分析、模板生成等不可选模型的接口使用的模型，默认 moonshot
+	EmbeddingModel string              `yaml:"EmbeddingModel"` // 用于生成向量的模型（需为 openai 提供方），为空时使用阿里云
+	Cassette       CassetteConfig      `yaml:"Cassette"`       // 录制或回放大模型及外部接口的调用，用于本地开发和集成测试
+	SemanticCache  SemanticCacheConfig `yaml:"SemanticCache"`  // 法律咨询的语义缓存，相似的问题直接返回之前的回答
+}
+
+// SemanticCacheConfig 语义缓存配置
+type SemanticCacheConfig struct {
+	Enabled    bool    `yaml:"Enabled"`    // 是否开启，默认关闭
+	Threshold  float64 `yaml:"Threshold"`  // 问题向量的余弦相似度阈值，默认 0.95
+	TTL        int     `yaml:"TTL"`        // 回答的缓存时间（秒），默认 86400
+	MaxEntries int     `yaml:"MaxEntries"` // 最多缓存的回答数，默认 1000
+	Shared     bool    `yaml:"Shared"`     // 是否在用户之间共享不含个人信息的回答，默认只对提问的用户本人命中
+}
+
+// CassetteConfig 录制/回放配置
+type CassetteConfig struct {
+	Mode string `yaml:"Mode"` // record：调用真实接口并保存结果；replay：只返回录制的结果，不需要密钥和网络；为空时不启用
+	Path string `yaml:"Path"` // 录制文件路径
+}
+
+// ModelConfig 单个大模型的配置
+type ModelConfig struct {
+	Name          string   `yaml:"Name"`          // 对外暴露的模型名称，即请求中的 model 字段
+	Provider      string   `yaml:"Provider"`      // 模型提供方：moonshot、deepseek、openai
+	Model         string   `yaml:"Model"`         // 提供方侧的模型ID，为空时与 Name 相同
+	BaseURL       string   `yaml:"BaseURL"`       // 接口地址，为空时使用提供方默认地址
+	ApiKey        string   `yaml:"ApiKey"`        // 接口密钥
+	Temperature   float64  `yaml:"Temperature"`   // 默认温度
+	MaxTokens     int      `yaml:"MaxTokens"`     // 默认最大输出 token 数
+	ContextWindow int      `yaml:"ContextWindow"` // 上下文窗口大小（token），默认 8192
+	Disabled      bool     `yaml:"Disabled"`      // 是否停用
+	Timeout       int      `yaml:"Timeout"`       // 单次调用超时时间（秒），默认 180
+	MaxRetries    int      `yaml:"MaxRetries"`    // 遇到 429/5xx 时的最大重试次数，默认 2，-1 表示不重试
+	Fallback      []string `yaml:"Fallback"`      // 调用失败时依次尝试的备用模型名称
+	Tools         bool     `yaml:"Tools"`         // 是否支持工具调用（deepseek、openai 提供方）
 }
 
 type Datasource struct {
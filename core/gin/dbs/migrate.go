@@ -3,6 +3,7 @@ package dbs
 import (
 	"Programming-Demo/internal/app/File/file_entity"
 	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/internal/app/prompt/prompt_entity"
 	"Programming-Demo/internal/app/story/story_entity"
 	"Programming-Demo/internal/app/template/template_entity"
 	"Programming-Demo/internal/app/user/user_entity"
@@ -16,7 +17,14 @@ func AutoMigrate(db *gorm.DB) error {
 		&ai_entity.ChatHistory{},
 		&template_entity.LegalTemplate{},
 		&ai_entity.ChatTheme{},
+		&ai_entity.ChatSummary{},
+		&ai_entity.ChatThemeTag{},
+		&ai_entity.ChatShare{},
+		&ai_entity.AnswerFeedback{},
+		&ai_entity.AttachmentChunk{},
+		&ai_entity.FileIndex{},
 		&story_entity.Story{},
+		&prompt_entity.PromptTemplate{},
 	)
 	return err
 }
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 一个客户端在当前时间窗口内的请求数
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimitMiddleware 按客户端 IP 限流，每个 window 内最多 limit 次请求，超出时返回 429
+func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
+	var mu sync.Mutex
+	windows := make(map[string]*rateWindow)
+	lastSweep := time.Now()
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		ip := c.ClientIP()
+
+		mu.Lock()
+		// 定期清理过期的窗口，避免占用内存
+		if now.Sub(lastSweep) > window {
+			for k, w := range windows {
+				if now.Sub(w.start) > window {
+					delete(windows, k)
+				}
+			}
+			lastSweep = now
+		}
+		w, ok := windows[ip]
+		if !ok || now.Sub(w.start) > window {
+			w = &rateWindow{start: now}
+			windows[ip] = w
+		}
+		w.count++
+		exceeded := w.count > limit
+		retryAfter := w.start.Add(window).Sub(now)
+		mu.Unlock()
+
+		if exceeded {
+			c.Header("Retry-After", formatSeconds(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"code": http.StatusTooManyRequests,
+				"msg":  "请求过于频繁，请稍后再试",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 向上取整的秒数
+func formatSeconds(d time.Duration) string {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
@@ -112,9 +112,8 @@ func InsertVectors(vectors [][]float32, contents []string) error {
 	return nil
 }
 
-// SearchVectors 搜索相似向量并返回对应内容
-func SearchVectors(vector []float32, topK int) ([]int64, []float32, []string, error) {
-	ctx := context.Background()
+// SearchVectors 搜索相似向量并返回对应内容，ctx 取消时中止搜索
+func SearchVectors(ctx context.Context, vector []float32, topK int) ([]int64, []float32, []string, error) {
 	if err := LoadCollection(ctx); err != nil {
 		return nil, nil, nil, fmt.Errorf("加载集合失败: %v", err)
 	}
@@ -179,8 +178,7 @@ func SearchVectors(vector []float32, topK int) ([]int64, []float32, []string, er
 }
 
 // SearchVectorsWithParams 添加高级搜索参数的向量搜索
-func SearchVectorsWithParams(vector []float32, topK int, params map[string]interface{}) ([]int64, []float32, []string, error) {
-	ctx := context.Background()
+func SearchVectorsWithParams(ctx context.Context, vector []float32, topK int, params map[string]interface{}) ([]int64, []float32, []string, error) {
 	if err := LoadCollection(ctx); err != nil {
 		return nil, nil, nil, fmt.Errorf("加载集合失败: %v", err)
 	}
@@ -0,0 +1,230 @@
+package milvus
+
+import (
+	"Programming-Demo/config"
+	"context"
+	"fmt"
+	"github.com/milvus-io/milvus-sdk-go/v2/entity"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 用户上传文件的向量与民法典分开存放，每条记录带有文件所有者，搜索时必须按所有者过滤。
+// 检查和创建集合时加锁，避免多个文件同时建立索引时重复创建；
+// 集合就绪后记住状态，之后的读写不再加锁和检查，读写失败时清除，下次重新检查
+var (
+	fileCollectionMux   sync.Mutex
+	fileCollectionReady atomic.Bool
+)
+
+// FileHit 文件分块的搜索结果
+type FileHit struct {
+	ChunkID int64
+	FileID  int64
+	Score   float32 // L2 距离，越小越相似
+}
+
+// FileCollectionName 用户文件向量集合的名称
+func FileCollectionName() string {
+	return config.GetConfig().Milvus.Collection + "_user_files"
+}
+
+// EnsureFileCollection 用户文件集合不存在时创建，并建立索引、加载到内存
+func EnsureFileCollection(ctx context.Context) error {
+	if !IsClientInit() {
+		return fmt.Errorf("milvus 客户端未正确初始化")
+	}
+	if fileCollectionReady.Load() {
+		return nil
+	}
+	fileCollectionMux.Lock()
+	defer fileCollectionMux.Unlock()
+	if fileCollectionReady.Load() {
+		return nil
+	}
+
+	collName := FileCollectionName()
+	exist, err := MilvusClient.GetClient().HasCollection(ctx, collName)
+	if err != nil {
+		return fmt.Errorf("检查集合失败: %v", err)
+	}
+	if !exist {
+		schema := &entity.Schema{
+			CollectionName: collName,
+			Description:    "用户上传文件的分块向量",
+			Fields: []*entity.Field{
+				{Name: "id", DataType: entity.FieldTypeInt64, PrimaryKey: true, AutoID: true},
+				{Name: "owner_id", DataType: entity.FieldTypeInt64},
+				{Name: "file_id", DataType: entity.FieldTypeInt64},
+				{Name: "chunk_id", DataType: entity.FieldTypeInt64},
+				{
+					Name:       "vector",
+					DataType:   entity.FieldTypeFloatVector,
+					TypeParams: map[string]string{"dim": fmt.Sprintf("%d", config.GetConfig().Milvus.Dim)},
+				},
+			},
+		}
+		if err := MilvusClient.GetClient().CreateCollection(ctx, schema, 1); err != nil {
+			return fmt.Errorf("创建集合失败: %v", err)
+		}
+		log.Printf("成功创建集合: %s", collName)
+	}
+
+	idxDesc, err := MilvusClient.GetClient().DescribeIndex(ctx, collName, "vector")
+	if err != nil || len(idxDesc) == 0 {
+		idx, err := entity.NewIndexIvfFlat(entity.L2, 128)
+		if err != nil {
+			return fmt.Errorf("创建索引参数失败: %v", err)
+		}
+		if err := MilvusClient.GetClient().CreateIndex(ctx, collName, "vector", idx, false); err != nil {
+			return fmt.Errorf("创建索引失败: %v", err)
+		}
+	}
+
+	loadStatus, err := MilvusClient.GetClient().GetLoadState(ctx, collName, []string{})
+	if err != nil {
+		return fmt.Errorf("获取集合加载状态失败: %v", err)
+	}
+	if loadStatus != entity.LoadStateLoaded {
+		if err := MilvusClient.GetClient().LoadCollection(ctx, collName, false); err != nil {
+			return fmt.Errorf("加载集合失败: %v", err)
+		}
+	}
+	fileCollectionReady.Store(true)
+	return nil
+}
+
+// InsertFileChunks 写入一个文件的分块向量，chunkIDs 与 vectors 一一对应，返回新记录的主键
+func InsertFileChunks(ctx context.Context, ownerID uint, fileID uint, chunkIDs []int64, vectors [][]float32) ([]int64, error) {
+	if err := EnsureFileCollection(ctx); err != nil {
+		return nil, err
+	}
+	owners := make([]int64, len(chunkIDs))
+	files := make([]int64, len(chunkIDs))
+	for i := range chunkIDs {
+		owners[i] = int64(ownerID)
+		files[i] = int64(fileID)
+	}
+	ids, err := MilvusClient.GetClient().Insert(
+		ctx,
+		FileCollectionName(),
+		"",
+		entity.NewColumnInt64("owner_id", owners),
+		entity.NewColumnInt64("file_id", files),
+		entity.NewColumnInt64("chunk_id", chunkIDs),
+		entity.NewColumnFloatVector("vector", config.GetConfig().Milvus.Dim, vectors),
+	)
+	if err != nil {
+		fileCollectionReady.Store(false)
+		return nil, fmt.Errorf("插入数据失败: %v", err)
+	}
+	idCol, ok := ids.(*entity.ColumnInt64)
+	if !ok {
+		return nil, fmt.Errorf("插入结果缺少主键")
+	}
+	return idCol.Data(), nil
+}
+
+// DeleteFileChunks 删除一个文件的全部分块向量
+func DeleteFileChunks(ctx context.Context, fileID uint) error {
+	return deleteFileVectors(ctx, fmt.Sprintf("file_id == %d", fileID))
+}
+
+// DeleteFileVectors 按主键删除分块向量，用于撤销一次被取代的写入
+func DeleteFileVectors(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return deleteFileVectors(ctx, fmt.Sprintf("id in %s", int64List(ids)))
+}
+
+func deleteFileVectors(ctx context.Context, expr string) error {
+	if err := EnsureFileCollection(ctx); err != nil {
+		return err
+	}
+	if err := MilvusClient.GetClient().Delete(ctx, FileCollectionName(), "", expr); err != nil {
+		fileCollectionReady.Store(false)
+		return fmt.Errorf("删除数据失败: %v", err)
+	}
+	return nil
+}
+
+func int64List(ids []int64) string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i, id := range ids {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(strconv.FormatInt(id, 10))
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// SearchFileChunks 在 ownerID 的文件中搜索相似分块。所有者条件总是加在过滤表达式中，
+// fileIDs 不为空时进一步限定在这些文件内
+func SearchFileChunks(ctx context.Context, ownerID uint, fileIDs []uint, vector []float32, topK int) ([]FileHit, error) {
+	if ownerID == 0 {
+		return nil, fmt.Errorf("缺少文件所有者")
+	}
+	if err := EnsureFileCollection(ctx); err != nil {
+		return nil, err
+	}
+	expr := fmt.Sprintf("owner_id == %d", ownerID)
+	if len(fileIDs) > 0 {
+		ids := make([]int64, len(fileIDs))
+		for i, id := range fileIDs {
+			ids[i] = int64(id)
+		}
+		expr += " && file_id in " + int64List(ids)
+	}
+
+	sp, err := entity.NewIndexIvfFlatSearchParam(16)
+	if err != nil {
+		return nil, fmt.Errorf("创建搜索参数失败: %v", err)
+	}
+	results, err := MilvusClient.GetClient().Search(
+		ctx,
+		FileCollectionName(),
+		[]string{},
+		expr,
+		[]string{"file_id", "chunk_id"},
+		[]entity.Vector{entity.FloatVector(vector)},
+		"vector",
+		entity.L2,
+		topK,
+		sp,
+	)
+	if err != nil {
+		fileCollectionReady.Store(false)
+		return nil, fmt.Errorf("搜索向量失败: %v", err)
+	}
+
+	hits := make([]FileHit, 0, topK)
+	for _, result := range results {
+		var fileCol, chunkCol *entity.ColumnInt64
+		for _, field := range result.Fields {
+			col, ok := field.(*entity.ColumnInt64)
+			if !ok {
+				continue
+			}
+			switch col.Name() {
+			case "file_id":
+				fileCol = col
+			case "chunk_id":
+				chunkCol = col
+			}
+		}
+		if fileCol == nil || chunkCol == nil {
+			return nil, fmt.Errorf("搜索结果缺少字段")
+		}
+		for i := 0; i < result.ResultCount; i++ {
+			hits = append(hits, FileHit{ChunkID: chunkCol.Data()[i], FileID: fileCol.Data()[i], Score: result.Scores[i]})
+		}
+	}
+	return hits, nil
+}
@@ -5,6 +5,7 @@ import (
 	"Programming-Demo/core/libx"
 	"Programming-Demo/internal/app/File/file_dto"
 	"Programming-Demo/internal/app/File/file_entity"
+	"Programming-Demo/internal/app/ai/ai_service"
 	"fmt"
 	"io"
 	"net/http"
@@ -221,6 +222,7 @@ func DeleteFileHandler(c *gin.Context) {
 		c.JSON(500, gin.H{"error": "删除文件失败"})
 		return
 	}
+	ai_service.RemoveFileIndexAsync(file.ID)
 
 	// 删除文件成功
 	c.JSON(200, gin.H{"message": "文件删除成功"})
@@ -271,6 +273,8 @@ func AuditFile(c *gin.Context) {
 			})
 			return
 		}
+		// 在后台提取文本并建立向量索引，供所有者检索
+		ai_service.IndexFileAsync(file.ID)
 
 		c.JSON(http.StatusOK, gin.H{
 			"code":    200,
@@ -303,6 +307,7 @@ func AuditFile(c *gin.Context) {
 			})
 			return
 		}
+		ai_service.RemoveFileIndexAsync(file.ID)
 
 		c.JSON(http.StatusOK, gin.H{
 			"code":    200,
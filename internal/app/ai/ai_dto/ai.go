@@ -3,8 +3,79 @@ package ai_dto
 type ChatReq struct {
 	Model   string `json:"model"`
 	Content string `json:"content"`
-	Theme   string `gorm:"not null" json:"theme"`
-	Search  bool   `json:"search"` // 是否搜索
+	ThemeID uint   `json:"theme_id"`                 // 继续已有的主题，为 0 时新建主题
+	Theme   string `json:"theme"`                    // 新建主题的名称，为空时根据问题生成；只传名称时沿用该用户的同名主题
+	Search  bool   `json:"search"`                   // 是否搜索
+	FileIDs []uint `json:"file_ids" binding:"max=5"` // 附带的文件，本人上传的或公开且审核通过的
+}
+
+// AskMyFilesReq 基于本人上传的文件提问
+type AskMyFilesReq struct {
+	Model    string `json:"model"`
+	Question string `json:"question" binding:"required,max=2000"`
+	FileIDs  []uint `json:"file_ids" binding:"max=20"`    // 只在这些文件中检索，为空时检索本人全部已建立索引的文件
+	TopK     int    `json:"top_k" binding:"min=0,max=20"` // 检索的分块数，为 0 时使用默认值
+}
+
+// RenameThemeReq 修改主题名称
+type RenameThemeReq struct {
+	ID    uint   `json:"id" binding:"required"`
+	Theme string `json:"theme" binding:"required,max=50"`
+}
+
+// PinThemeReq 置顶或取消置顶主题
+type PinThemeReq struct {
+	ID     uint `json:"id" binding:"required"`
+	Pinned bool `json:"pinned"`
+}
+
+// ArchiveThemeReq 归档或取消归档主题
+type ArchiveThemeReq struct {
+	ID       uint `json:"id" binding:"required"`
+	Archived bool `json:"archived"`
+}
+
+// ThemeTagsReq 设置主题的标签，替换原有的全部标签
+type ThemeTagsReq struct {
+	ID   uint     `json:"id" binding:"required"`
+	Tags []string `json:"tags"`
+}
+
+// CreateShareReq 创建分享链接
+type CreateShareReq struct {
+	ExpiresIn int    `json:"expires_in" binding:"min=0"` // 有效期（小时），0 表示不过期，最长 30 天
+	Password  string `json:"password" binding:"max=64"`  // 访问密码，为空表示不需要；按字节不超过 72
+}
+
+// FeedbackReq 评价一条回答，rating 为 1（有帮助）或 -1（没帮助）
+type FeedbackReq struct {
+	MessageID  uint   `json:"message_id" binding:"required"`
+	Rating     int    `json:"rating" binding:"required,oneof=1 -1"`
+	Comment    string `json:"comment" binding:"max=500"`     // 评价说明
+	Correction string `json:"correction" binding:"max=5000"` // 用户给出的正确答案
+}
+
+// RegenerateReq 重新生成回复，原回复保留为另一个分支
+type RegenerateReq struct {
+	ThemeID   uint   `json:"theme_id" binding:"required"`
+	MessageID uint   `json:"message_id" binding:"required"` // 要重新生成的回复（或其对应的用户消息）
+	Model     string `json:"model"`                         // 为空时使用原问题的模型
+	Search    bool   `json:"search"`
+}
+
+// EditMessageReq 编辑用户消息，在原消息的位置开出新分支并重新回答
+type EditMessageReq struct {
+	ThemeID   uint   `json:"theme_id" binding:"required"`
+	MessageID uint   `json:"message_id" binding:"required"` // 要编辑的用户消息
+	Content   string `json:"content" binding:"required"`
+	Model     string `json:"model"` // 为空时使用原问题的模型
+	Search    bool   `json:"search"`
+}
+
+// SwitchBranchReq 切换到包含指定消息的分支
+type SwitchBranchReq struct {
+	ThemeID   uint `json:"theme_id" binding:"required"`
+	MessageID uint `json:"message_id" binding:"required"`
 }
 
 type AnalyzeReq struct {
@@ -50,6 +121,7 @@ type GenerateLegalDocReq struct {
 	Parties    []Party  `json:"parties"`    // 相关方信息
 	Content    LawsBase `json:"content"`    // 现有的 LawsReq 作为内容
 	Additional string   `json:"additional"` // 额外要求
+	Format     string   `json:"format"`     // 输出格式：为空时返回文本，json 时返回 ContractDoc
 }
 
 type Party struct {
@@ -81,9 +153,19 @@ type LegalOpinionBase struct {
 type GenerateComplaintReq struct {
 	Model   string        `json:"model"`   // AI模型
 	Content ComplaintBase `json:"content"` // 起诉状内容
+	Format  string        `json:"format"`  // 输出格式：为空时返回文本，json 时返回 ComplaintDoc
 }
 
 type GenerateLegalOpinionReq struct {
 	Model   string           `json:"model"`   // AI模型
 	Content LegalOpinionBase `json:"content"` // 法律意见书内容
+	Format  string           `json:"format"`  // 输出格式：为空时返回文本，json 时返回 OpinionDoc
+}
+
+// ModelInfo 可用模型信息（不包含密钥等敏感配置）
+type ModelInfo struct {
+	Name        string  `json:"name"`
+	Provider    string  `json:"provider"`
+	Temperature float64 `json:"temperature"`
+	MaxTokens   int     `json:"max_tokens"`
 }
@@ -0,0 +1,164 @@
+package ai_dto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FormatJSON 文书生成接口的结构化输出格式
+const FormatJSON = "json"
+
+// DocParty 文书中的当事人
+type DocParty struct {
+	Role    string `json:"role"`    // 甲方、乙方、原告、被告、委托人等
+	Name    string `json:"name"`    // 名称
+	Details string `json:"details"` // 地址、证件号、联系方式等
+}
+
+// DocClause 条款或章节，Number 从 1 开始连续编号
+type DocClause struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// DocEvidence 证据
+type DocEvidence struct {
+	Name        string `json:"name"`        // 证据名称
+	Description string `json:"description"` // 证据内容
+	Purpose     string `json:"purpose"`     // 证明目的
+}
+
+// DocSignature 落款
+type DocSignature struct {
+	Signatories []string `json:"signatories"` // 签署人
+	Date        string   `json:"date"`        // 日期
+	Place       string   `json:"place"`       // 地点
+}
+
+// ContractDoc 结构化合同
+type ContractDoc struct {
+	Title     string       `json:"title"`
+	Preamble  string       `json:"preamble"` // 合同前言
+	Parties   []DocParty   `json:"parties"`
+	Clauses   []DocClause  `json:"clauses"`
+	Signature DocSignature `json:"signature"`
+}
+
+// ComplaintDoc 结构化起诉状
+type ComplaintDoc struct {
+	Title       string        `json:"title"`
+	Court       string        `json:"court"`
+	Parties     []DocParty    `json:"parties"`
+	Claims      []string      `json:"claims"` // 诉讼请求
+	Facts       string        `json:"facts"`  // 事实与理由
+	Evidence    []DocEvidence `json:"evidence"`
+	LegalBasis  []string      `json:"legal_basis"`
+	Attachments []string      `json:"attachments"`
+	Signature   DocSignature  `json:"signature"`
+}
+
+// OpinionDoc 结构化法律意见书，Clauses 为意见书的各个部分
+type OpinionDoc struct {
+	Title      string       `json:"title"`
+	Parties    []DocParty   `json:"parties"` // 委托人、出具人
+	Clauses    []DocClause  `json:"clauses"`
+	LegalBasis []string     `json:"legal_basis"`
+	Signature  DocSignature `json:"signature"`
+}
+
+func (d *ContractDoc) Validate() error {
+	var errs []string
+	if strings.TrimSpace(d.Title) == "" {
+		errs = append(errs, "title 不能为空")
+	}
+	if len(d.Parties) < 2 {
+		errs = append(errs, "parties 至少需要两方")
+	}
+	errs = append(errs, validateParties(d.Parties)...)
+	errs = append(errs, validateClauses(d.Clauses)...)
+	errs = append(errs, validateSignature(d.Signature)...)
+	renumber(d.Clauses)
+	return joinErrors(errs)
+}
+
+func (d *ComplaintDoc) Validate() error {
+	var errs []string
+	if strings.TrimSpace(d.Title) == "" {
+		errs = append(errs, "title 不能为空")
+	}
+	if len(d.Parties) < 2 {
+		errs = append(errs, "parties 至少需要原告和被告")
+	}
+	errs = append(errs, validateParties(d.Parties)...)
+	if len(d.Claims) == 0 {
+		errs = append(errs, "claims 不能为空")
+	}
+	if strings.TrimSpace(d.Facts) == "" {
+		errs = append(errs, "facts 不能为空")
+	}
+	for i, e := range d.Evidence {
+		if strings.TrimSpace(e.Name) == "" {
+			errs = append(errs, fmt.Sprintf("evidence[%d].name 不能为空", i))
+		}
+	}
+	errs = append(errs, validateSignature(d.Signature)...)
+	return joinErrors(errs)
+}
+
+func (d *OpinionDoc) Validate() error {
+	var errs []string
+	if strings.TrimSpace(d.Title) == "" {
+		errs = append(errs, "title 不能为空")
+	}
+	errs = append(errs, validateParties(d.Parties)...)
+	errs = append(errs, validateClauses(d.Clauses)...)
+	errs = append(errs, validateSignature(d.Signature)...)
+	renumber(d.Clauses)
+	return joinErrors(errs)
+}
+
+func validateParties(parties []DocParty) []string {
+	var errs []string
+	for i, p := range parties {
+		if strings.TrimSpace(p.Role) == "" || strings.TrimSpace(p.Name) == "" {
+			errs = append(errs, fmt.Sprintf("parties[%d] 缺少 role 或 name", i))
+		}
+	}
+	return errs
+}
+
+func validateClauses(clauses []DocClause) []string {
+	if len(clauses) == 0 {
+		return []string{"clauses 不能为空"}
+	}
+	var errs []string
+	for i, c := range clauses {
+		if strings.TrimSpace(c.Content) == "" {
+			errs = append(errs, fmt.Sprintf("clauses[%d].content 不能为空", i))
+		}
+	}
+	return errs
+}
+
+func validateSignature(s DocSignature) []string {
+	if len(s.Signatories) == 0 {
+		return []string{"signature.signatories 不能为空"}
+	}
+	return nil
+}
+
+// 模型给出的编号可能不连续，统一按顺序重新编号
+func renumber(clauses []DocClause) {
+	for i := range clauses {
+		clauses[i].Number = i + 1
+	}
+}
+
+func joinErrors(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "；"))
+}
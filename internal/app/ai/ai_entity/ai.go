@@ -1,35 +1,128 @@
 package ai_entity
 
 import (
+	"Programming-Demo/pkg/utils/civilcode"
 	"time"
 )
 
 // 历史记录
 type ChatHistory struct {
-	ID        uint      `gorm:"primarykey" json:"id"`
-	UserID    uint      `gorm:"not null;index:idx_user_theme" json:"user_id"`
-	Theme     string    `gorm:"size:50;not null;index:idx_user_theme" json:"theme"`
-	Model     string    `gorm:"size:50;not null" json:"model"`
-	Role      string    `gorm:"size:20;not null" json:"role"`
-	Content   string    `gorm:"type:text;not null" json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UserID      uint      `gorm:"not null;index:idx_user_theme_id" json:"user_id"`
+	ThemeID     uint      `gorm:"not null;default:0;index:idx_user_theme_id" json:"theme_id"` // 所属的 ChatTheme
+	ParentID    uint      `gorm:"default:0;index" json:"parent_id"`                           // 对话中的上一条消息，0 表示第一条；编辑或重新生成后同一条消息下会有多个分支
+	Model       string    `gorm:"size:50;not null" json:"model"`
+	Role        string    `gorm:"size:20;not null" json:"role"`
+	Content     string    `gorm:"type:text;not null;index:idx_content_ngram,class:FULLTEXT,option:WITH PARSER ngram" json:"content"` // ngram 全文索引用于搜索聊天记录
+	Reasoning   string    `gorm:"type:text" json:"reasoning,omitempty"`                                                              // 推理模型的思维链，仅供审阅，不作为后续对话的上下文
+	Partial     bool      `gorm:"default:false" json:"partial"`                                                                      // 流式输出时客户端中途断开，仅保存了部分回复
+	ToolCalls   string    `gorm:"type:text" json:"tool_calls,omitempty"`                                                             // assistant 消息中模型发起的工具调用（JSON 数组）
+	ToolCallID  string    `gorm:"size:64" json:"tool_call_id,omitempty"`                                                             // tool 消息对应的工具调用ID
+	Prompt      string    `gorm:"size:80" json:"prompt,omitempty"`                                                                   // 生成该回复的提示词版本，如 legal_assistant@3
+	Sources     string    `gorm:"type:text" json:"sources,omitempty"`                                                                // 回答参考的联网搜索来源（JSON 数组）
+	Endpoint    string    `gorm:"size:20" json:"endpoint,omitempty"`                                                                 // 生成该消息的接口：chat、search、contract、complaint、opinion
+	Attachments string    `gorm:"type:text" json:"attachments,omitempty"`                                                            // 用户消息附带的文件（JSON 数组），之后的对话仍可使用
+	CreatedAt   time.Time `json:"created_at"`
+	Siblings    []uint    `gorm:"-" json:"siblings,omitempty"` // 同一父消息下的全部版本（含自身），多于一个时前端可切换分支
+
+	Citations []civilcode.Citation `gorm:"-" json:"citations,omitempty"` // 回答中法条引用的核对结果，读取时生成
 }
 
 // 聊天主题
 type ChatTheme struct {
 	ID          uint      `gorm:"primarykey" json:"id"`
-	UserID      uint      `gorm:"not null;index:idx_user_id" json:"user_id"`
-	Theme       string    `gorm:"size:50;not null;uniqueIndex:idx_user_theme" json:"theme"`
+	UserID      uint      `gorm:"not null;index:idx_user_id;uniqueIndex:idx_theme_user_name" json:"user_id"`
+	Theme       string    `gorm:"size:50;not null;uniqueIndex:idx_theme_user_name" json:"theme"` // 主题名称，同一用户下唯一
 	LastMessage time.Time `json:"last_message"`
+	Pinned      bool      `gorm:"default:false" json:"pinned"`   // 置顶，列表中排在最前
+	Archived    bool      `gorm:"default:false" json:"archived"` // 归档，默认列表中不显示，继续对话时自动取消
+	Tags        []string  `gorm:"-" json:"tags"`                 // 标签，保存在 ChatThemeTag 中
+	HeadID      uint      `gorm:"default:0" json:"head_id"`      // 当前分支的最后一条消息ID
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// 滚动摘要：超出模型上下文的早期对话被压缩到这里。
+// 按摘要截止的消息保存，只用于包含该消息的分支
+type ChatSummary struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ThemeID   uint      `gorm:"not null;index" json:"theme_id"`
+	UntilID   uint      `gorm:"not null;uniqueIndex" json:"until_id"` // 已并入摘要的最后一条消息ID
+	Summary   string    `gorm:"type:text" json:"summary"`
+	Prompt    string    `gorm:"size:80" json:"prompt"` // 生成摘要的提示词版本，如 chat_summary@2
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 主题标签
+type ChatThemeTag struct {
+	ID      uint   `gorm:"primarykey" json:"id"`
+	UserID  uint   `gorm:"not null;index:idx_user_tag" json:"user_id"`
+	ThemeID uint   `gorm:"not null;uniqueIndex:idx_theme_tag" json:"theme_id"`
+	Tag     string `gorm:"size:20;not null;index:idx_user_tag;uniqueIndex:idx_theme_tag" json:"tag"`
+}
+
+// 对话分享链接，保存分享时的对话快照，之后的对话不会出现在分享中
+type ChatShare struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	ThemeID     uint       `gorm:"not null;index" json:"theme_id"`
+	Token       string     `gorm:"size:64;not null;uniqueIndex" json:"token"`
+	Title       string     `gorm:"size:50" json:"title"`   // 分享时的主题名称
+	Snapshot    string     `gorm:"type:longtext" json:"-"` // 对话快照（JSON）
+	Password    string     `gorm:"size:100" json:"-"`      // bcrypt 哈希，为空表示不需要密码
+	ExpiresAt   *time.Time `json:"expires_at"`             // 为空表示不过期
+	Views       int        `gorm:"default:0" json:"views"` // 访问次数
+	CreatedAt   time.Time  `json:"created_at"`
+	HasPassword bool       `gorm:"-" json:"has_password"` // 返回给分享者，不保存
+}
+
+// 用户对回答的评价，每个用户对每条回答只保留最新的一次
+type AnswerFeedback struct {
+	ID         uint   `gorm:"primarykey" json:"id"`
+	UserID     uint   `gorm:"not null;uniqueIndex:idx_feedback_user_message" json:"user_id"`
+	MessageID  uint   `gorm:"not null;uniqueIndex:idx_feedback_user_message" json:"message_id"` // 被评价的 assistant 消息
+	Rating     int    `gorm:"not null;index" json:"rating"`                                     // 1 为有用，-1 为没用
+	Comment    string `gorm:"type:text" json:"comment,omitempty"`
+	Correction string `gorm:"type:text" json:"correction,omitempty"` // 用户给出的正确回答
+	// 以下字段复制自被评价的消息，便于统计
+	Model     string    `gorm:"size:50;index" json:"model"`
+	Prompt    string    `gorm:"size:80" json:"prompt"`
+	Endpoint  string    `gorm:"size:20;index" json:"endpoint"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 附件文件提取出的文本，按顺序分块保存。文件按哈希去重且不会修改，提取一次即可
+type AttachmentChunk struct {
+	ID      uint   `gorm:"primarykey" json:"id"`
+	FileID  uint   `gorm:"not null;uniqueIndex:idx_file_seq" json:"file_id"`
+	Seq     int    `gorm:"not null;uniqueIndex:idx_file_seq" json:"seq"`
+	Offset  int    `gorm:"not null;default:0" json:"offset"` // 分块在提取出的全文中的起始位置（字符数）
+	Content string `gorm:"type:text;not null" json:"content"`
+}
+
+// 文件的向量索引状态，文件审核通过后建立，索引本身保存在 Milvus 中
+type FileIndex struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	FileID    uint      `gorm:"not null;uniqueIndex" json:"file_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"` // 文件所有者
+	Status    string    `gorm:"size:20;not null;index" json:"status"`
+	Chunks    int       `json:"chunks"`                           // 已写入的分块数
+	Error     string    `gorm:"type:text" json:"error,omitempty"` // 最近一次失败的原因
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // 本地缓存
 type LocalChatCache struct {
 	UserID      uint                   `json:"user_id"`
-	Theme       string                 `json:"theme"`
+	ThemeID     uint                   `json:"theme_id"`
 	LastUpdated time.Time              `json:"last_updated"`
-	Messages    []ChatHistory          `json:"messages"`
+	Messages    []ChatHistory          `json:"messages"` // 历史记录缓存中只有当前分支消息的ID、父消息ID和其他版本
 	Metadata    map[string]interface{} `json:"metadata"`
+	// 对话上下文缓存使用，Messages 为摘要之后的消息
+	Summary         string `json:"summary,omitempty"`
+	SummarizedUntil uint   `json:"summarized_until,omitempty"`
+	HeadID          uint   `json:"head_id,omitempty"`
 }
@@ -8,23 +8,26 @@ import (
 	"Programming-Demo/internal/app/ai/ai_dto"
 	"Programming-Demo/internal/app/ai/ai_entity"
 	"Programming-Demo/internal/app/ai/ai_service"
+	"Programming-Demo/internal/app/prompt/prompt_service"
 	"Programming-Demo/pkg/utils/ai"
 	"Programming-Demo/pkg/utils/bocha"
-	"Programming-Demo/pkg/utils/deepseek"
+	"Programming-Demo/pkg/utils/civilcode"
 	"Programming-Demo/pkg/utils/prompt"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const (
-	DefaultContextMessageCount = 10             // 默认上下文消息数量
-	MaxContextMessageCount     = 50             // 最大上下文消息数量
-	CacheExpiration            = 24 * time.Hour // 缓存过期时间
+	CacheExpiration = 24 * time.Hour // 缓存过期时间
 )
 
 // 处理用户与AI的聊天请求
@@ -43,16 +46,21 @@ func ChatWithAi(c *gin.Context) {
 		return
 	}
 
-	// 如果主题为空，则生成主题名称
-	if req.Theme == "" {
-		themeName, err := GenerateThemeName(req.Content, req.Model)
-		if err != nil {
-			// 如果生成失败，使用默认主题名称
-			req.Theme = "法律咨询_" + time.Now().Format("20060102150405")
-			fmt.Printf("Failed to generate theme name: %v, using default\n", err)
-		} else {
-			req.Theme = themeName
-		}
+	// 附带的文件必须是本人上传的，或公开且审核通过的
+	attachments, err := ai_service.ResolveAttachments(c.Request.Context(), uid, req.FileIDs)
+	if errors.Is(err, ai_service.ErrAttachmentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取附件失败", "error": err.Error()})
+		return
+	}
+
+	// 找到要继续的主题，没有时新建
+	theme, ok := resolveChatTheme(c, uid, &req, "法律咨询_")
+	if !ok {
+		return
 	}
 
 	// 初始化Ristretto缓存
@@ -61,187 +69,444 @@ func ChatWithAi(c *gin.Context) {
 		fmt.Printf("Failed to initialize cache: %v\n", err)
 	}
 
-	// 尝试从缓存加载历史记录
-	cache, err := ai_service.LoadChatCache(uid, req.Theme)
-	var histories []ai_entity.ChatHistory
-
-	if err != nil || !ai_service.IsCacheValid(cache, CacheExpiration) {
-		// 缓存不可用，从数据库加载
-		histories, err = ai_service.GetRecentChatHistoryByTheme(uid, req.Theme, DefaultContextMessageCount)
-		if err != nil {
-			// 记录错误但继续，如果无法获取历史记录，就使用空记录
-			fmt.Printf("Failed to get chat history: %v\n", err)
-			histories = []ai_entity.ChatHistory{}
-		}
-	} else {
-		// 使用缓存的消息
-		histories = cache.Messages
-	}
-
-	// 限制上下文消息数量，避免超出AI模型的上下文限制
-	contextCount := DefaultContextMessageCount
-	if len(histories) > contextCount {
-		histories = histories[len(histories)-contextCount:]
+	// 加载当前分支的对话上下文（滚动摘要 + 最近的消息），优先使用缓存
+	chatCtx, err := ai_service.LoadChatContext(c.Request.Context(), uid, theme.ID, CacheExpiration)
+	if err != nil {
+		// 记录错误但继续，如果无法获取历史记录，就使用空记录
+		fmt.Printf("Failed to get chat history: %v\n", err)
+		chatCtx = &ai_service.ChatContext{}
 	}
 
-	// 保存当前用户消息到历史记录
+	// 保存当前用户消息到历史记录，接在当前分支的最后一条消息之后
 	userMessage := ai_entity.ChatHistory{
-		UserID:  uid,
-		Model:   req.Model,
-		Theme:   req.Theme,
-		Role:    "user",
-		Content: req.Content,
+		UserID:      uid,
+		ThemeID:     theme.ID,
+		ParentID:    chatCtx.HeadID,
+		Model:       req.Model,
+		Role:        "user",
+		Content:     req.Content,
+		Endpoint:    ai_service.EndpointChat,
+		Attachments: ai_service.EncodeAttachments(attachments),
 	}
 
-	// 使用事务确保数据一致性
-	tx := dbs.DB.Begin()
-	if err := tx.Create(&userMessage).Error; err != nil {
-		tx.Rollback()
+	// 用户消息单独保存，生成回答期间不占用事务；生成失败时再删除
+	if err := ai_service.SaveQuestion(c.Request.Context(), &userMessage); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "保存用户消息失败", "error": err.Error()})
 		return
 	}
 
 	// 更新主题最后消息时间
-	if err := ai_service.UpdateOrCreateTheme(uid, req.Theme); err != nil {
+	if err := ai_service.RefreshThemeLastMessage(uid, theme.ID); err != nil {
 		// 记录错误但继续，因为这不是核心功能
 		fmt.Printf("Failed to update theme: %v\n", err)
 	}
 
-	var prompt, searchInfo string
+	answerTurn(c, chatTurn{uid: uid, theme: theme, req: req, chatCtx: chatCtx, userMessage: userMessage})
+}
+
+// 找到请求对应的主题：指定 theme_id 时读取该用户的主题；只指定名称时沿用同名主题；
+// 都没有时根据问题生成名称新建主题。失败时已写入响应，返回 false
+func resolveChatTheme(c *gin.Context, uid uint, req *ai_dto.ChatReq, defaultPrefix string) (*ai_entity.ChatTheme, bool) {
+	// 先确认模型可用，避免请求失败时留下空主题
+	if !ai.IsModelEnabled(req.Model) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "模型错误"})
+		return nil, false
+	}
+	var theme *ai_entity.ChatTheme
+	var err error
+	switch {
+	case req.ThemeID != 0:
+		theme, err = ai_service.GetTheme(c.Request.Context(), uid, req.ThemeID)
+		if errors.Is(err, ai_service.ErrThemeNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+			return nil, false
+		}
+	case req.Theme != "":
+		theme, err = ai_service.FindOrCreateTheme(c.Request.Context(), uid, req.Theme)
+	default:
+		name, genErr := GenerateThemeName(c.Request.Context(), req.Content, req.Model)
+		if genErr != nil {
+			// 如果生成失败，使用默认主题名称
+			name = defaultPrefix + time.Now().Format("20060102150405")
+			fmt.Printf("Failed to generate theme name for user %v: %v, using default\n", uid, genErr)
+		}
+		theme, err = ai_service.CreateTheme(c.Request.Context(), uid, name)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取主题失败", "error": err.Error()})
+		return nil, false
+	}
+	req.ThemeID, req.Theme = theme.ID, theme.Theme
+	return theme, true
+}
+
+// 一轮问答：用户消息已保存，chatCtx 为该消息之前的分支上下文
+type chatTurn struct {
+	uid         uint
+	theme       *ai_entity.ChatTheme
+	req         ai_dto.ChatReq
+	chatCtx     *ai_service.ChatContext
+	userMessage ai_entity.ChatHistory
+	regenerate  bool // 重新生成回复，不使用语义缓存；提问是已有的消息，失败时保留
+}
+
+// 回答失败时删除本次请求保存的用户消息
+func discardQuestion(c *gin.Context, t chatTurn) {
+	if t.regenerate {
+		return
+	}
+	ctx, cancel := persistContext(c)
+	defer cancel()
+	if err := ai_service.DiscardQuestion(ctx, t.userMessage.ID); err != nil {
+		log.Printf("Failed to discard question %d: %v", t.userMessage.ID, err)
+	}
+}
+
+// 生成回答并保存到用户消息之后，成为主题的当前分支
+func answerTurn(c *gin.Context, t chatTurn) {
+	uid, theme, req, chatCtx, userMessage := t.uid, t.theme, t.req, t.chatCtx, t.userMessage
+
+	// 选择不同的 AI 模型处理
+	if !ai.IsModelEnabled(req.Model) {
+		discardQuestion(c, t)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "模型错误"})
+		return
+	}
+
+	// 系统人设（含早期对话摘要）+ 历史问答 + 最新问题，联网搜索结果附在最新问题之后
+	question := req.Content
+	var searchInfo string
 	if req.Search == true {
-		err, searchInfo = ai.WebBaseSearch(req.Content)
+		var err error
+		err, searchInfo = ai.WebBaseSearch(c.Request.Context(), req.Content)
 		if err != nil {
+			discardQuestion(c, t)
 			c.JSON(http.StatusBadRequest, gin.H{"message": "联网搜索失败", "error": err.Error()})
 			return
 		}
 		log.Println(searchInfo)
-		prompt = ai.GenerateWebSearchPrompt(req.Theme, histories, req.Content, searchInfo)
-	} else {
-		prompt = generateLegalAssistantPrompt(req.Theme, histories, req.Content)
-		searchInfo = ""
+		question = ai.AppendSearchInfo(req.Content, searchInfo)
+	}
+	// 附件内容附在问题之后，包括当前分支中之前的消息附带的文件
+	attachments, err := ai_service.TurnAttachments(c.Request.Context(), uid, theme.ID, userMessage)
+	var attachmentInfo string
+	if err == nil {
+		attachmentInfo, err = ai_service.AttachmentContext(c.Request.Context(), uid, attachments, req.Content, req.Model)
+	}
+	if err != nil {
+		discardQuestion(c, t)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "读取附件失败", "error": err.Error()})
+		return
+	}
+	if attachmentInfo != "" {
+		question = ai.AppendAttachments(question, attachmentInfo)
+	}
+	system, err := prompt_service.Render(c.Request.Context(), prompt_service.LegalAssistant, prompt_service.LegalAssistantData{Theme: theme.Theme, Search: req.Search})
+	if err != nil {
+		discardQuestion(c, t)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成提示词失败", "error": err.Error()})
+		return
+	}
+	messages, err := chatCtx.BuildMessages(c.Request.Context(), theme.ID, req.Model, system.Text, question)
+	if err != nil {
+		discardQuestion(c, t)
+		c.JSON(http.StatusBadRequest, gin.H{"message": "构建对话上下文失败", "error": err.Error()})
+		return
 	}
 
-	var Resp string
-	var code int
+	// 语义缓存只用于主题中的第一个问题，有对话历史、联网搜索或附件时回答依赖上下文，不使用缓存
+	var cacheQuery *ai_service.AnswerQuery
+	var cached *ai_service.CachedAnswer
+	if ai_service.AnswerCacheEnabled() && !t.regenerate && !req.Search && len(attachments) == 0 && chatCtx.Summary == "" && len(chatCtx.Histories) == 0 {
+		cacheQuery = ai_service.NewAnswerQuery(c.Request.Context(), req.Model, system.Label(), req.Content)
+		cached = cacheQuery.Lookup(uid)
+		if cached != nil {
+			c.Header("X-Cache", "HIT")
+		} else {
+			c.Header("X-Cache", "MISS")
+		}
+	}
 
-	// 选择不同的 AI 模型处理
-	switch req.Model {
-	case "moonshot":
-		// 使用结构化提示作为输入
-		Resp, code = ai.GetAIResp(prompt)
-	case "deepseek-chat", "deepseek-reasoner":
-		Resp, code = deepseek.ChatWithDeepSeek(prompt, "POST", req.Model)
-	default:
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"message": "模型错误"})
+	// 流式模式下边生成边推送，客户端断开时保留已生成的部分
+	stream := wantStream(c)
+	if stream {
+		startStream(c, gin.H{"theme": theme.Theme, "theme_id": theme.ID, "searchInfo": searchInfo})
+	}
+	var reply modelReply
+	var trail []ai.Message
+	if cached != nil {
+		reply = cachedReply(c, stream, cached)
+	} else {
+		// 模型可按需查询法条、联网搜索或读取用户文件
+		reply, trail = generateWithTools(c, stream, req.Model, messages, ai_service.ChatTools(uid))
+	}
+	if reply.Code != 200 {
+		// 调用失败或客户端在生成前断开，回滚已保存的用户消息
+		discardQuestion(c, t)
+		respondError(c, stream, http.StatusBadRequest, "调用ai接口失败", reply.Content)
 		return
 	}
 
-	if code != 200 {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"message": "调用ai接口失败", "error": Resp})
+	// 工具调用及其结果记录在用户消息和最终回答之间
+	toolMessages, err := ai_service.ToolHistories(uid, theme.ID, reply.Model, trail)
+	if err != nil {
+		discardQuestion(c, t)
+		respondError(c, stream, http.StatusInternalServerError, "保存工具调用记录失败", err.Error())
 		return
 	}
 
-	// 保存 AI 回复到历史记录
+	// 保存 AI 回复到历史记录，记录实际回答的模型和使用的提示词版本
 	aiMessage := ai_entity.ChatHistory{
-		UserID:  uid,
-		Model:   req.Model,
-		Theme:   req.Theme,
-		Role:    "assistant",
-		Content: Resp,
+		UserID:    uid,
+		ThemeID:   theme.ID,
+		Model:     reply.Model,
+		Role:      "assistant",
+		Content:   reply.Content,
+		Reasoning: reply.Reasoning,
+		Partial:   reply.Partial,
+		Prompt:    system.Label(),
+		Sources:   ai_service.SearchSources(searchInfo, trail),
+		Endpoint:  ai_service.EndpointChat,
 	}
 
-	if err := tx.Create(&aiMessage).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "保存AI回复失败", "error": err.Error()})
+	// 工具调用记录和回答在一个短事务中保存，新的回答成为当前分支；客户端已断开时也保存已生成的部分
+	ctx, cancel := persistContext(c)
+	err = ai_service.SaveAnswer(ctx, theme.ID, userMessage.ID, toolMessages, &aiMessage)
+	cancel()
+	if err != nil {
+		discardQuestion(c, t)
+		respondError(c, stream, http.StatusInternalServerError, "保存AI回复失败", err.Error())
 		return
 	}
 
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "提交事务失败", "error": err.Error()})
-		return
+	// 完整生成的回答写入语义缓存，读取过用户文件的回答不共享给其他用户
+	if cacheQuery != nil && cached == nil && !reply.Partial {
+		cacheQuery.Store(uid, !ai_service.UsedUserFile(trail), ai_service.CachedAnswer{
+			Content:   reply.Content,
+			Model:     reply.Model,
+			Reasoning: reply.Reasoning,
+		})
 	}
 
-	// 更新历史记录列表，包括新的消息
-	histories = append(histories, userMessage, aiMessage)
+	// 更新上下文，包括新的消息
+	chatCtx.Histories = append(chatCtx.Histories, userMessage)
+	chatCtx.Histories = append(chatCtx.Histories, toolMessages...)
+	chatCtx.Histories = append(chatCtx.Histories, aiMessage)
+	chatCtx.HeadID = aiMessage.ID
 
 	// 更新缓存 - 使用 goroutine 异步执行，不阻塞主流程
 	go func() {
-		if err := ai_service.SaveChatCache(uid, req.Theme, histories); err != nil {
+		if err := ai_service.SaveChatContext(uid, theme.ID, chatCtx); err != nil {
 			fmt.Printf("Failed to save chat cache: %v\n", err)
 		}
 	}()
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":       code,
-		"searchInfo": searchInfo,
-		"theme":      req.Theme,
-		"message":    Resp,
-	})
+	result := gin.H{
+		"code":            reply.Code,
+		"searchInfo":      searchInfo,
+		"theme":           theme.Theme,
+		"theme_id":        theme.ID,
+		"message":         reply.Content,
+		"model":           reply.Model,
+		"reasoning":       reply.Reasoning,
+		"tool_calls":      toolMessages,
+		"prompt":          system.Label(),
+		"cached":          cached != nil,
+		"message_id":      aiMessage.ID,
+		"user_message_id": userMessage.ID,
+		"attachments":     attachments,
+		"citations":       civilcode.VerifyCitations(reply.Content),
+	}
+	if stream {
+		result["partial"] = reply.Partial
+		streamDone(c, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
 }
 
 // 获取聊天历史记录
 func GetChatHistory(c *gin.Context) {
 	uid := libx.Uid(c)
-	theme := c.Query("theme")
-	limitStr := c.DefaultQuery("limit", "50")
-	var limit int
-	fmt.Sscanf(limitStr, "%d", &limit)
-
-	if limit <= 0 || limit > MaxContextMessageCount {
-		limit = DefaultContextMessageCount
+	var themeID uint
+	if _, err := fmt.Sscanf(c.Query("theme_id"), "%d", &themeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "无效的主题ID格式",
+			"error":   err.Error(),
+		})
+		return
+	}
+	cursor, err := parseHistoryCursor(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
 	}
 
-	// 尝试从缓存加载
-	cache, err := ai_service.LoadChatCache(uid, theme)
-	var histories []ai_entity.ChatHistory
+	page, err := ai_service.GetHistoryPage(c.Request.Context(), uid, themeID, cursor)
+	if errors.Is(err, ai_service.ErrThemeNotFound) || errors.Is(err, ai_service.ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "获取历史记录失败", "error": err.Error()})
+		return
+	}
 
-	if err != nil || !ai_service.IsCacheValid(cache, CacheExpiration) {
-		// 从数据库加载
-		histories, err = ai_service.GetChatHistoryByTheme(uid, theme, limit)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "获取历史记录失败", "error": err.Error()})
-			return
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"code":            200,
+		"message":         "success",
+		"data":            page.Messages,
+		"head_id":         page.HeadID,
+		"has_more_before": page.HasMoreBefore,
+		"has_more_after":  page.HasMoreAfter,
+	})
+}
 
-		// 更新缓存 - 使用 goroutine 异步执行
-		go func() {
-			if err := ai_service.SaveChatCache(uid, theme, histories); err != nil {
-				fmt.Printf("Failed to save chat cache: %v\n", err)
-			}
-		}()
-	} else {
-		histories = cache.Messages
-		// 如果请求的限制与缓存不同，则过滤
-		if len(histories) > limit {
-			histories = histories[len(histories)-limit:]
-		}
+// 在用户全部主题的聊天记录中搜索，返回命中的消息、所属主题和高亮摘要
+func SearchChatHistory(c *gin.Context) {
+	uid := libx.Uid(c)
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" || utf8.RuneCountInString(keyword) > ai_service.MaxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": fmt.Sprintf("搜索内容应为 1 到 %d 个字", ai_service.MaxSearchQueryLength)})
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(ai_service.DefaultSearchPageSize)))
+	if err != nil || pageSize < 1 || pageSize > ai_service.MaxSearchPageSize {
+		pageSize = ai_service.DefaultSearchPageSize
 	}
 
+	hits, total, err := ai_service.SearchHistory(c.Request.Context(), uid, ai_service.HistorySearchQuery{
+		Keyword:  keyword,
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if errors.Is(err, ai_service.ErrEmptySearchQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "搜索聊天记录失败", "error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "success",
-		"data":    histories,
+		"data": gin.H{
+			"messages":    hits,
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": (int(total) + pageSize - 1) / pageSize,
+		},
 	})
 }
 
-// 获取聊天主题
+// 解析历史记录的分页参数：before / after 为消息ID，limit 不传时使用默认值，超出范围时报错而不是静默修改
+func parseHistoryCursor(c *gin.Context) (ai_service.HistoryCursor, error) {
+	cursor := ai_service.HistoryCursor{Limit: ai_service.DefaultHistoryLimit}
+	if v := c.Query("before"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || id == 0 {
+			return cursor, fmt.Errorf("无效的 before 参数")
+		}
+		cursor.Before = uint(id)
+	}
+	if v := c.Query("after"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || id == 0 {
+			return cursor, fmt.Errorf("无效的 after 参数")
+		}
+		cursor.After = uint(id)
+	}
+	if cursor.Before != 0 && cursor.After != 0 {
+		return cursor, ai_service.ErrHistoryCursor
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > ai_service.MaxHistoryLimit {
+			return cursor, fmt.Errorf("limit 应为 1 到 %d 之间的整数", ai_service.MaxHistoryLimit)
+		}
+		cursor.Limit = limit
+	}
+	return cursor, nil
+}
+
+// 分页获取聊天主题，可按名称搜索、按标签筛选，archived=true 时列出已归档的主题
 func GetChatThemes(c *gin.Context) {
 	uid := libx.Uid(c)
 
-	// 获取所有主题（包括元数据）
-	themes, err := ai_service.GetAllChatThemes(uid)
+	// 从查询参数中获取分页参数
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(ai_service.DefaultThemePageSize)))
+	if err != nil || pageSize < 1 || pageSize > ai_service.MaxThemePageSize {
+		pageSize = ai_service.DefaultThemePageSize
+	}
+
+	themes, total, err := ai_service.ListThemes(c.Request.Context(), uid, ai_service.ThemeQuery{
+		Keyword:  strings.TrimSpace(c.Query("keyword")),
+		Tag:      strings.TrimSpace(c.Query("tag")),
+		Archived: c.Query("archived") == "true",
+		Page:     page,
+		PageSize: pageSize,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "获取主题失败", "error": err.Error()})
 		return
 	}
 
+	// 计算总页数
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize > 0 {
+		totalPages++
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "success",
-		"data":    themes,
+		"data": gin.H{
+			"themes":      themes,
+			"total":       total,
+			"page":        page,
+			"page_size":   pageSize,
+			"total_pages": totalPages,
+		},
+	})
+}
+
+// 修改聊天主题名称
+func RenameChatTheme(c *gin.Context) {
+	uid := libx.Uid(c)
+	var req ai_dto.RenameThemeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	err := ai_service.RenameTheme(c.Request.Context(), uid, req.ID, req.Theme)
+	if errors.Is(err, ai_service.ErrThemeNameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		respondThemeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "修改成功",
 	})
 }
 
@@ -269,7 +534,7 @@ func DeleteChatTheme(c *gin.Context) {
 		return
 	}
 
-	// 首先查询主题以获取主题名称(用于后续删除缓存)
+	// 首先确认主题属于当前用户
 	var theme ai_entity.ChatTheme
 	if err := dbs.DB.Where("id = ? AND user_id = ?", themeID, uid).First(&theme).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -281,10 +546,21 @@ func DeleteChatTheme(c *gin.Context) {
 	}
 
 	// 使用事务删除相关记录
-	tx := dbs.DB.Begin()
+	tx := dbs.DB.WithContext(c.Request.Context()).Begin()
+
+	// 删除消息的评价，需在删除聊天历史之前
+	if err := ai_service.DeleteThemeFeedback(tx, uid, theme.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "删除回答评价失败",
+			"error":   err.Error(),
+		})
+		return
+	}
 
 	// 删除聊天历史记录
-	if err := tx.Where("user_id = ? AND theme = ?", uid, theme.Theme).
+	if err := tx.Where("user_id = ? AND theme_id = ?", uid, theme.ID).
 		Delete(&ai_entity.ChatHistory{}).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -295,6 +571,39 @@ func DeleteChatTheme(c *gin.Context) {
 		return
 	}
 
+	// 删除主题标签
+	if err := ai_service.DeleteThemeTags(tx, theme.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "删除主题标签失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 撤销主题的分享链接
+	if err := ai_service.DeleteThemeShares(tx, theme.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "撤销分享失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 删除主题的对话摘要
+	if err := ai_service.DeleteThemeSummaries(tx, theme.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "删除对话摘要失败",
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	// 删除主题记录
 	if err := tx.Delete(&ai_entity.ChatTheme{}, themeID).Error; err != nil {
 		tx.Rollback()
@@ -318,7 +627,7 @@ func DeleteChatTheme(c *gin.Context) {
 
 	// 删除缓存 - 异步执行
 	go func() {
-		if err := ai_service.DeleteChatCache(uid, theme.Theme); err != nil {
+		if err := ai_service.DeleteChatCache(uid, theme.ID); err != nil {
 			fmt.Printf("Failed to delete chat cache: %v\n", err)
 		}
 	}()
@@ -372,12 +681,17 @@ func AnalyzeFile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "文件读取错误"})
 		return
 	}
-	Resp, code := ai.GetAIResp(prompt.BuildLegalAnalysisPrompt(string(content)))
+	p, err := prompt_service.Render(c.Request.Context(), prompt_service.LegalAnalysis, prompt_service.LegalAnalysisData{Content: string(content)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成提示词失败", "error": err.Error()})
+		return
+	}
+	Resp, code := ai.GetAIResp(c.Request.Context(), p.Text)
 	if code != 200 {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "调用ai接口失败"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": code, "message": Resp})
+	c.JSON(http.StatusOK, gin.H{"code": code, "message": Resp, "prompt": p.Label(), "citations": civilcode.VerifyCitations(Resp)})
 }
 
 func GenerateLegalDocument(c *gin.Context) {
@@ -390,24 +704,21 @@ func GenerateLegalDocument(c *gin.Context) {
 		})
 		return
 	}
-	var Resp string
-	var code int
-	p := prompt.BuildLegalDocPrompt(req)
 	// 选择不同的 AI 模型处理
-	switch req.Model {
-	case "moonshot":
-		Resp, code = ai.GetAIResp(p)
-	case "deepseek-chat", "deepseek-reasoner":
-		Resp, code = deepseek.ChatWithDeepSeek(p, "POST", req.Model)
-	default:
+	if !ai.IsModelEnabled(req.Model) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "模型错误"})
 		return
 	}
-	if code != 200 {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "调用ai接口失败", "error": Resp})
+	p, err := prompt_service.Render(c.Request.Context(), prompt_service.LegalDoc, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成提示词失败", "error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": code, "message": Resp})
+	if req.Format == ai_dto.FormatJSON {
+		respondStructured(c, ai_service.EndpointContract, req.Model, prompt.WithJSONFormat(p.Text, prompt.ContractJSONSchema), p.Label(), &ai_dto.ContractDoc{})
+		return
+	}
+	respondGeneration(c, ai_service.EndpointContract, req.Model, p.Text, p.Label())
 }
 
 func GenerateLegalOpinion(c *gin.Context) {
@@ -420,24 +731,21 @@ func GenerateLegalOpinion(c *gin.Context) {
 		})
 		return
 	}
-	var Resp string
-	var code int
-	p := prompt.BuildLegalOpinionPrompt(req)
 	// 选择不同的 AI 模型处理
-	switch req.Model {
-	case "moonshot":
-		Resp, code = ai.GetAIResp(p)
-	case "deepseek-chat", "deepseek-reasoner":
-		Resp, code = deepseek.ChatWithDeepSeek(p, "POST", req.Model)
-	default:
+	if !ai.IsModelEnabled(req.Model) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "模型错误"})
 		return
 	}
-	if code != 200 {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "调用ai接口失败", "error": Resp})
+	p, err := prompt_service.Render(c.Request.Context(), prompt_service.LegalOpinion, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成提示词失败", "error": err.Error()})
+		return
+	}
+	if req.Format == ai_dto.FormatJSON {
+		respondStructured(c, ai_service.EndpointOpinion, req.Model, prompt.WithJSONFormat(p.Text, prompt.OpinionJSONSchema), p.Label(), &ai_dto.OpinionDoc{})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": code, "message": Resp})
+	respondGeneration(c, ai_service.EndpointOpinion, req.Model, p.Text, p.Label())
 }
 
 func GenerateComplaint(c *gin.Context) {
@@ -450,24 +758,21 @@ func GenerateComplaint(c *gin.Context) {
 		})
 		return
 	}
-	var Resp string
-	var code int
-	p := prompt.BuildComplaintPrompt(req)
 	// 选择不同的 AI 模型处理
-	switch req.Model {
-	case "moonshot":
-		Resp, code = ai.GetAIResp(p)
-	case "deepseek-chat", "deepseek-reasoner":
-		Resp, code = deepseek.ChatWithDeepSeek(p, "POST", req.Model)
-	default:
+	if !ai.IsModelEnabled(req.Model) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "模型错误"})
 		return
 	}
-	if code != 200 {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "调用ai接口失败", "error": Resp})
+	p, err := prompt_service.Render(c.Request.Context(), prompt_service.Complaint, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成提示词失败", "error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": code, "message": Resp})
+	if req.Format == ai_dto.FormatJSON {
+		respondStructured(c, ai_service.EndpointComplaint, req.Model, prompt.WithJSONFormat(p.Text, prompt.ComplaintJSONSchema), p.Label(), &ai_dto.ComplaintDoc{})
+		return
+	}
+	respondGeneration(c, ai_service.EndpointComplaint, req.Model, p.Text, p.Label())
 }
 
 // DeepSeek和博查API实现联网搜索
@@ -483,6 +788,11 @@ func AiSearch(c *gin.Context) {
 		return
 	}
 
+	if !ai.IsModelEnabled(req.Model) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "模型错误"})
+		return
+	}
+
 	// 检查博查客户端是否已初始化
 	if bochalient.BochaClient == nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -492,16 +802,10 @@ func AiSearch(c *gin.Context) {
 		return
 	}
 
-	// 如果主题为空，则生成主题名称
-	if req.Theme == "" {
-		themeName, err := GenerateThemeName(req.Content, req.Model)
-		if err != nil {
-			// 如果生成失败，使用默认主题名称
-			req.Theme = "联网搜索_" + time.Now().Format("20060102150405")
-			fmt.Printf("Failed to generate theme name for user %v: %v, using default\n", uid, err)
-		} else {
-			req.Theme = themeName
-		}
+	// 找到要继续的主题，没有时新建
+	theme, ok := resolveChatTheme(c, uid, &req, "联网搜索_")
+	if !ok {
+		return
 	}
 
 	// 初始化Ristretto缓存
@@ -509,49 +813,35 @@ func AiSearch(c *gin.Context) {
 		fmt.Printf("Failed to initialize cache: %v\n", err)
 	}
 
-	// 尝试从缓存加载历史记录
-	cache, err := ai_service.LoadChatCache(uid, req.Theme)
-	var histories []ai_entity.ChatHistory
-
-	if err != nil || !ai_service.IsCacheValid(cache, CacheExpiration) {
-		// 缓存不可用，从数据库加载
-		histories, err = ai_service.GetRecentChatHistoryByTheme(uid, req.Theme, DefaultContextMessageCount)
-		if err != nil {
-			fmt.Printf("Failed to get chat history: %v\n", err)
-			histories = []ai_entity.ChatHistory{}
-		}
-	} else {
-		// 使用缓存的消息
-		histories = cache.Messages
-	}
-
-	// 限制上下文消息数量
-	contextCount := DefaultContextMessageCount
-	if len(histories) > contextCount {
-		histories = histories[len(histories)-contextCount:]
+	// 加载对话上下文（滚动摘要 + 最近的消息）
+	chatCtx, err := ai_service.LoadChatContext(c.Request.Context(), uid, theme.ID, CacheExpiration)
+	if err != nil {
+		fmt.Printf("Failed to get chat history: %v\n", err)
+		chatCtx = &ai_service.ChatContext{}
 	}
 
 	// 保存当前用户消息到历史记录
 	userMessage := ai_entity.ChatHistory{
-		UserID:  uid,
-		Model:   req.Model,
-		Theme:   req.Theme,
-		Role:    "user",
-		Content: req.Content,
+		UserID:   uid,
+		ThemeID:  theme.ID,
+		ParentID: chatCtx.HeadID,
+		Model:    req.Model,
+		Role:     "user",
+		Content:  req.Content,
+		Endpoint: ai_service.EndpointSearch,
 	}
 
-	// 使用事务确保数据一致性
-	tx := dbs.DB.Begin()
-	if err := tx.Create(&userMessage).Error; err != nil {
-		tx.Rollback()
+	// 用户消息单独保存，生成回答期间不占用事务；生成失败时再删除
+	if err := ai_service.SaveQuestion(c.Request.Context(), &userMessage); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "保存用户消息失败", "error": err.Error()})
 		return
 	}
 
 	// 更新主题最后消息时间
-	if err := ai_service.UpdateOrCreateTheme(uid, req.Theme); err != nil {
+	if err := ai_service.RefreshThemeLastMessage(uid, theme.ID); err != nil {
 		fmt.Printf("Failed to update theme: %v\n", err)
 	}
+	discard := func() { discardQuestion(c, chatTurn{userMessage: userMessage}) }
 
 	// 使用博查API进行搜索
 	searchReq := bocha.SearchRequest{
@@ -562,10 +852,9 @@ func AiSearch(c *gin.Context) {
 		Page:      1,
 	}
 
-	// 使用封装的bochalient.BochaClient获取客户端并执行搜索
-	searchResult, err := bochalient.BochaClient.GetClient().Search(searchReq)
+	searchResult, err := ai.BochaSearch(c.Request.Context(), searchReq)
 	if err != nil {
-		tx.Rollback()
+		discard()
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "搜索失败",
@@ -581,91 +870,92 @@ func AiSearch(c *gin.Context) {
 		searchInfo = "搜索结果解析失败，但仍可能包含有用信息: " + searchResult
 	}
 
-	// 构建带搜索信息的提示词
-	prompt := fmt.Sprintf(`现在是%s，你是一位专业的AI助手，以下是用户的问题，以及我为你提供的最新网络搜索结果。
-请根据这些搜索结果回答用户问题。注意以下几点：
-1. 如果搜索结果提供了足够信息，请直接回答问题，并引用搜索结果中的相关信息
-2. 如果搜索结果包含多个来源，请综合各个来源的信息进行回答
-3. 如果搜索结果不足以回答问题，请诚实告知用户，并尽可能提供相关信息
-4. 回答中应引用信息来源(例如网站名称)，以便用户验证
-5. 保持客观、准确，不要添加搜索结果中没有的信息
-
-用户问题：%s
+	// 系统提示词只包含搜索问答的规则，搜索结果随本轮问题一起发送，不显示给前端
+	system, err := prompt_service.Render(c.Request.Context(), prompt_service.WebSearch, prompt_service.WebSearchData{Date: time.Now().Format("2006年01月02日")})
+	if err != nil {
+		discard()
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成提示词失败", "error": err.Error()})
+		return
+	}
+	question := fmt.Sprintf(`用户问题：%s
 
 网络搜索结果：
 %s
 
-请基于上述搜索结果回答用户问题：`,
-		time.Now().Format("2006年01月02日"),
-		req.Content,
-		searchInfo,
-	)
-
-	// 将历史记录转换为DeepSeek可用的格式
-	var messagesContent []string
-	for _, history := range histories {
-		rolePrefix := ""
-		if history.Role == "user" {
-			rolePrefix = "用户: "
-		} else if history.Role == "assistant" {
-			rolePrefix = "AI助手: "
-		}
-		messagesContent = append(messagesContent, rolePrefix+history.Content)
-	}
+请基于上述搜索结果回答用户问题：`, req.Content, searchInfo)
 
-	// 用户消息加上搜索提示词，不显示给前端
-	finalPrompt := ""
-	if len(messagesContent) > 0 {
-		finalPrompt = strings.Join(messagesContent, "\n") + "\n\n" + prompt
-	} else {
-		finalPrompt = prompt
+	messages, err := chatCtx.BuildMessages(c.Request.Context(), theme.ID, req.Model, system.Text, question)
+	if err != nil {
+		discard()
+		c.JSON(http.StatusBadRequest, gin.H{"message": "构建对话上下文失败", "error": err.Error()})
+		return
 	}
 
-	// 使用DeepSeek获取回复
-	resp, code := deepseek.ChatWithDeepSeek(finalPrompt, "POST", req.Model)
-
-	if code != 200 {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"message": "调用AI接口失败", "error": resp})
+	// 使用所选模型获取回复
+	stream := wantStream(c)
+	if stream {
+		startStream(c, gin.H{"theme": theme.Theme, "theme_id": theme.ID})
+	}
+	reply := generate(c, stream, req.Model, messages)
+	if reply.Code != 200 {
+		// 调用失败或客户端在生成前断开，回滚已保存的用户消息
+		discard()
+		respondError(c, stream, http.StatusBadRequest, "调用AI接口失败", reply.Content)
 		return
 	}
 
 	// 保存AI回复到历史记录
 	aiMessage := ai_entity.ChatHistory{
-		UserID:  uid,
-		Model:   req.Model,
-		Theme:   req.Theme,
-		Role:    "assistant",
-		Content: resp,
+		UserID:    uid,
+		ThemeID:   theme.ID,
+		Model:     reply.Model,
+		Role:      "assistant",
+		Content:   reply.Content,
+		Reasoning: reply.Reasoning,
+		Partial:   reply.Partial,
+		Prompt:    system.Label(),
+		Sources:   ai_service.SearchSources(searchInfo, nil),
+		Endpoint:  ai_service.EndpointSearch,
 	}
 
-	if err := tx.Create(&aiMessage).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "保存AI回复失败", "error": err.Error()})
-		return
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "提交事务失败", "error": err.Error()})
+	ctx, cancel := persistContext(c)
+	err = ai_service.SaveAnswer(ctx, theme.ID, userMessage.ID, nil, &aiMessage)
+	cancel()
+	if err != nil {
+		discard()
+		respondError(c, stream, http.StatusInternalServerError, "保存AI回复失败", err.Error())
 		return
 	}
 
-	// 更新历史记录列表
-	histories = append(histories, userMessage, aiMessage)
+	// 更新上下文
+	chatCtx.Histories = append(chatCtx.Histories, userMessage, aiMessage)
+	chatCtx.HeadID = aiMessage.ID
 
 	// 更新缓存
 	go func() {
-		if err := ai_service.SaveChatCache(uid, req.Theme, histories); err != nil {
+		if err := ai_service.SaveChatContext(uid, theme.ID, chatCtx); err != nil {
 			fmt.Printf("Failed to save chat cache: %v\n", err)
 		}
 	}()
 
 	// 返回响应，包含主题名称
-	c.JSON(http.StatusOK, gin.H{
-		"code":    200,
-		"message": resp,
-		"theme":   req.Theme, // 添加主题到响应中
-	})
+	result := gin.H{
+		"code":       200,
+		"message":    reply.Content,
+		"theme":      theme.Theme, // 添加主题到响应中
+		"theme_id":   theme.ID,
+		"model":      reply.Model,
+		"reasoning":  reply.Reasoning,
+		"prompt":     system.Label(),
+		"message_id": aiMessage.ID,
+		"citations":  civilcode.VerifyCitations(reply.Content),
+	}
+	if stream {
+		result["partial"] = reply.Partial
+		streamDone(c, result)
+		return
+	}
+	c.JSON(http.StatusOK, result)
 }
 
 func GenerateLegalDocBetter(c *gin.Context) {
@@ -678,134 +968,57 @@ func GenerateLegalDocBetter(c *gin.Context) {
 		})
 		return
 	}
-	var Resp string
-	var code int
-	p := prompt.BuildLegalDocPrompt(req)
-	ps, docs := prompt.BuildRAGPrompt(p)
 	// 选择不同的 AI 模型处理
-	switch req.Model {
-	case "moonshot":
-		Resp, code = ai.GetAIResp(ps)
-	case "deepseek-chat", "deepseek-reasoner":
-		Resp, code = deepseek.ChatWithDeepSeek(ps, "POST", req.Model)
-	default:
+	if !ai.IsModelEnabled(req.Model) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "模型错误"})
 		return
 	}
-	if code != 200 {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "调用ai接口失败", "error": Resp})
+	p, err := prompt_service.Render(c.Request.Context(), prompt_service.LegalDoc, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成提示词失败", "error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"code": code, "doc": docs, "message": Resp})
+	ps, docs := prompt.BuildRAGPrompt(c.Request.Context(), p.Text)
+	reply := generate(c, false, req.Model, ai.UserMessages(ps))
+	if reply.Code != 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "调用ai接口失败", "error": reply.Content})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": reply.Code, "doc": docs, "message": reply.Content, "model": reply.Model, "reasoning": reply.Reasoning, "prompt": p.Label(), "citations": civilcode.VerifyCitations(reply.Content)})
 }
 
-// 生成增强型法律助手提示
-func generateLegalAssistantPrompt(theme string, histories []ai_entity.ChatHistory, currentQuestion string) string {
-	basePrompt := `# AI法律助手增强型提示框架
-
-## 角色定义
-你是一个专业的法律助手，拥有以下核心特质：
-- 精通中国现行法律体系，能准确引用最新法律法规、司法解释及指导案例
-- 具备严谨、专业、客观的法律分析能力和批判性思维
-- 能提供基于法律条文和司法实践的准确分析和建议
-- 善于将复杂的法律概念转化为易于理解的语言，同时保持法律表述的精确性
-
-## 基本工作原则
-
-### 1. 法律专业性原则
-- 所有回复必须基于现行有效的中国法律法规，确保引用的法律为最新版本
-- 引用法律条文时必须准确标注：《法律名称》第X条第X款第X项，并附上条文原文
-- 区分强制性规范与任意性规范，明确说明法律要求与建议性内容的区别
-- 对于存在争议的法律问题，应当呈现不同观点和可能的法律后果
-- 明确指出法律规定与实践操作之间可能存在的差异
-
-### 2. 专业边界与责任限制原则
-- 明确表明所提供的信息仅为一般性法律参考，不构成正式法律意见
-- 复杂或高风险问题应建议用户咨询具有执业资格的专业律师
-- 不对特定案件结果做出保证或预测
-- 对于需要专业判断的问题（如证据采信、责任划分等），提供法律框架而非确定性结论
-
-### 3. 信息安全与隐私保护原则
-- 不提供可能违法或有害的建议，拒绝协助规避法律的请求
-- 遵循最小信息收集原则，不主动索取无关的个人敏感信息
-- 提醒用户在描述法律问题时注意保护个人身份信息和隐私
-- 建议用户在讨论敏感法律事项时采取适当的信息安全措施
-
-## 回复框架与质量标准
-
-### 回复结构
-1. **法律问题界定**：准确理解并重述用户咨询的法律问题
-2. **法律依据分析**：
-   - 相关法律法规条文引用（附条文原文）
-   - 司法解释或指导性案例（如适用）
-   - 法理学原则或学说（如适用）
-3. **法律分析与推理**：
-   - 将法律条文应用于具体情境
-   - 多角度分析可能的法律后果
-   - 明确区分事实问题与法律问题
-4. **实用建议与风险提示**：
-   - 可行的解决途径及其法律后果
-   - 潜在风险和注意事项
-   - 必要的程序性指导（如适用）
-5. **总结与免责声明**：简明扼要地总结核心观点，并附上适当的免责声明
-
-## 当前主题与情境适配
-特定主题: ` + theme
-
-	// 添加对话历史
-	basePrompt += "\n\n## 对话历史："
-	for _, history := range histories {
-		role := "用户"
-		if history.Role == "assistant" {
-			role = "法律助手"
-		}
-		basePrompt += fmt.Sprintf("\n%s: %s", role, history.Content)
+// 获取已启用的模型列表
+func ListModels(c *gin.Context) {
+	models := make([]ai_dto.ModelInfo, 0)
+	for _, m := range ai.EnabledModels() {
+		models = append(models, ai_dto.ModelInfo{
+			Name:        m.Name,
+			Provider:    m.Provider,
+			Temperature: m.Temperature,
+			MaxTokens:   m.MaxTokens,
+		})
 	}
 
-	// 添加当前问题
-	basePrompt += fmt.Sprintf("\n\n## 用户最新问题：\n%s", currentQuestion)
-
-	// 添加回复指南
-	basePrompt += `
-
-## 回复要求
-1. 分析用户问题的核心法律问题
-2. 引用相关法律条文（包括条文原文）
-3. 提供专业法律分析和推理
-4. 给出实用建议和风险提示
-5. 使用清晰的结构，确保回答易于理解
-6. 涉及复杂问题时，建议咨询专业律师进行具体指导
-7. 回复结尾添加简短的免责声明
-
-请基于以上指南，提供专业、准确、有深度的法律回答。`
-
-	return basePrompt
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    models,
+	})
 }
 
 // 根据用户问题生成主题名称
-func GenerateThemeName(question string, model string) (string, error) {
+func GenerateThemeName(ctx context.Context, question string, model string) (string, error) {
 	// 构建主题生成提示
-	prompt := `请为以下法律咨询问题生成一个简短的主题名称（不超过15个字），主题名称应该概括问题的核心法律领域和关键事项。
-    
-问题内容：
-"""
-` + question + `
-"""
-
-请只返回主题名称，不需要任何解释或额外内容。`
+	prompt, err := prompt_service.Render(ctx, prompt_service.ThemeName, prompt_service.ThemeNameData{Question: question})
+	if err != nil {
+		return "", err
+	}
 
 	// 调用AI获取主题名称
-	var themeName string
-	var code int
-
-	switch model {
-	case "moonshot":
-		themeName, code = ai.GetAIResp(prompt)
-	case "deepseek-chat", "deepseek-reasoner":
-		themeName, code = deepseek.ChatWithDeepSeek(prompt, "POST", model)
-	default:
+	if !ai.IsModelEnabled(model) {
 		return "", fmt.Errorf("不支持的模型类型")
 	}
+	themeName, code := ai.ChatWithModel(ctx, model, prompt.Text)
 
 	if code != 200 {
 		return "", fmt.Errorf("生成主题名称失败: %s", themeName)
@@ -0,0 +1,272 @@
+//go:build integration
+
+// /api/ai/* 接口的集成测试，需要一个空的 MySQL 测试库：
+//
+//	AI_INTEGRATION_CONFIG=/path/to/config.test.yaml go test -tags integration ./internal/app/ai/ai_handler/
+//
+// 大模型由按 testdata/upstream.json 回复的模拟接口代替。先在录制模式下请求一遍接口，
+// 然后关闭模拟接口，用录制文件回放同样的请求，两次的回复应当一致
+package ai_handler_test
+
+import (
+	"Programming-Demo/config"
+	"Programming-Demo/core/auth"
+	"Programming-Demo/core/database"
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/internal/app/user/user_entity"
+	"Programming-Demo/internal/router"
+	"Programming-Demo/pkg/utils/ai"
+	"Programming-Demo/pkg/utils/openai"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testModel = "integration-model"
+
+// 模拟接口的回复：按最后一条用户消息包含的内容选择，都不匹配时使用 Default
+type upstreamFixture struct {
+	Default string `json:"default"`
+	Replies []struct {
+		Contains string `json:"contains"`
+		Content  string `json:"content"`
+	} `json:"replies"`
+}
+
+func (f upstreamFixture) reply(messages []openai.ChatMessage) string {
+	var last string
+	for _, m := range messages {
+		if m.Role == "user" {
+			last = m.Content
+		}
+	}
+	for _, r := range f.Replies {
+		if strings.Contains(last, r.Contains) {
+			return r.Content
+		}
+	}
+	return f.Default
+}
+
+// 启动 OpenAI 兼容的模拟接口，流式请求分两段返回
+func newUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "upstream.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixture upstreamFixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content := fixture.reply(req.Messages)
+		if !req.Stream {
+			json.NewEncoder(w).Encode(gin.H{"model": req.Model, "choices": []gin.H{{"message": gin.H{"role": "assistant", "content": content}, "finish_reason": "stop"}}})
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		runes := []rune(content)
+		for _, part := range []string{string(runes[:len(runes)/2]), string(runes[len(runes)/2:])} {
+			chunk, _ := json.Marshal(gin.H{"choices": []gin.H{{"delta": gin.H{"content": part}}}})
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type client struct {
+	t      *testing.T
+	engine *gin.Engine
+	token  string
+}
+
+// 发送请求并解析 JSON 响应，状态码不符时测试失败
+func (c client) do(method, path string, body interface{}, wantStatus int) map[string]interface{} {
+	c.t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	w := httptest.NewRecorder()
+	c.engine.ServeHTTP(w, req)
+	if w.Code != wantStatus {
+		c.t.Fatalf("%s %s: status = %d, want %d, body = %s", method, path, w.Code, wantStatus, w.Body.String())
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		c.t.Fatalf("%s %s: invalid json: %s", method, path, w.Body.String())
+	}
+	return resp
+}
+
+// 以 SSE 方式请求，返回 done 事件的数据
+func (c client) stream(path string, body interface{}) map[string]interface{} {
+	c.t.Helper()
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	w := httptest.NewRecorder()
+	c.engine.ServeHTTP(w, req)
+	for _, event := range strings.Split(w.Body.String(), "\n\n") {
+		if !strings.HasPrefix(event, "event:done\n") {
+			continue
+		}
+		var done map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(event, "event:done\ndata:")), &done); err != nil {
+			c.t.Fatalf("invalid done event: %s", event)
+		}
+		return done
+	}
+	c.t.Fatalf("POST %s: no done event in %s", path, w.Body.String())
+	return nil
+}
+
+func newUser(t *testing.T, name string) string {
+	t.Helper()
+	user := user_entity.User{Username: name, Password: "-", Email: name + "@example.com"}
+	if err := dbs.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, model := range []interface{}{&ai_entity.AnswerFeedback{}, &ai_entity.ChatHistory{}, &ai_entity.ChatTheme{}} {
+			dbs.DB.Where("user_id = ?", user.ID).Delete(model)
+		}
+		dbs.DB.Delete(&user)
+	})
+	token, err := auth.GenerateToken(user.ID, user.Username, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// 依次调用各个接口，返回各次生成的回复
+func runScenario(t *testing.T, c client, theme string) map[string]string {
+	replies := make(map[string]string)
+
+	models := c.do(http.MethodGet, "/api/ai/models", nil, http.StatusOK)
+	if list, _ := models["data"].([]interface{}); len(list) != 1 || list[0].(map[string]interface{})["name"] != testModel {
+		t.Fatalf("models = %v", models["data"])
+	}
+
+	first := c.do(http.MethodPost, "/api/ai/chat", gin.H{"model": testModel, "theme": theme, "content": "房东不退押金怎么办"}, http.StatusOK)
+	replies["chat"] = first["message"].(string)
+	themeID := first["theme_id"]
+
+	// 追问的请求包含上一轮问答，以流式返回
+	second := c.stream("/api/ai/chat", gin.H{"model": testModel, "theme_id": themeID, "content": "需要准备哪些证据"})
+	replies["chat_followup"] = second["message"].(string)
+	if second["partial"] != false {
+		t.Fatalf("partial = %v", second["partial"])
+	}
+
+	history := c.do(http.MethodGet, fmt.Sprintf("/api/ai/history?theme_id=%v", themeID), nil, http.StatusOK)
+	messages, _ := history["data"].([]interface{})
+	if len(messages) != 4 {
+		t.Fatalf("history has %d messages, want 4", len(messages))
+	}
+	if last := messages[3].(map[string]interface{}); last["content"] != replies["chat_followup"] {
+		t.Fatalf("last history message = %v", last)
+	}
+
+	found := c.do(http.MethodGet, "/api/ai/history/search?keyword=押金", nil, http.StatusOK)
+	if total, _ := found["data"].(map[string]interface{})["total"].(float64); total < 1 {
+		t.Fatalf("search result = %v", found)
+	}
+
+	themes := c.do(http.MethodGet, "/api/ai/theme", nil, http.StatusOK)
+	if data := themes["data"].(map[string]interface{}); data["total"] != float64(1) {
+		t.Fatalf("themes = %v", data)
+	}
+
+	c.do(http.MethodPost, "/api/ai/feedback", gin.H{"message_id": first["message_id"], "rating": 1}, http.StatusOK)
+	c.do(http.MethodPost, "/api/ai/feedback", gin.H{"message_id": first["user_message_id"], "rating": 1}, http.StatusBadRequest)
+
+	doc := c.do(http.MethodPost, "/api/ai/contract", gin.H{
+		"model":    testModel,
+		"doc_type": "合同",
+		"title":    "房屋租赁合同",
+		"parties":  []gin.H{{"type": "甲方", "name": "张三"}, {"type": "乙方", "name": "李四"}},
+	}, http.StatusOK)
+	replies["contract"] = doc["message"].(string)
+
+	c.do(http.MethodPost, "/api/ai/chat", gin.H{"model": "no-such-model", "content": "你好", "theme": theme + "_2"}, http.StatusBadRequest)
+
+	c.do(http.MethodDelete, fmt.Sprintf("/api/ai/delete?id=%v", themeID), nil, http.StatusOK)
+	c.do(http.MethodGet, fmt.Sprintf("/api/ai/history?theme_id=%v", themeID), nil, http.StatusNotFound)
+	return replies
+}
+
+func TestAIEndpointsRecordReplay(t *testing.T) {
+	path := os.Getenv("AI_INTEGRATION_CONFIG")
+	if path == "" {
+		t.Skip("AI_INTEGRATION_CONFIG is not set")
+	}
+	config.LoadConfig(path)
+	cfg := config.GetConfig()
+	upstream := newUpstream(t)
+	cfg.Models = []config.ModelConfig{{Name: testModel, Provider: "openai", BaseURL: upstream.URL, ContextWindow: 8192}}
+	cfg.SemanticCache.Enabled = false
+	database.InitDB()
+	dbs.InitDB()
+	auth.InitSecret()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	router.GenerateRouters(engine)
+
+	suffix := time.Now().Format("150405.000")
+	theme := "集成测试"
+
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := ai.NewCassette()
+	recorder.AutoSave(cassettePath)
+	recorder.Record()
+	recorded := runScenario(t, client{t: t, engine: engine, token: newUser(t, "it_record_"+suffix)}, theme)
+
+	// 录制结果在每次调用后写入文件，不需要等到关闭服务
+	cassette, err := ai.LoadCassette(cassettePath)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	if len(cassette.Responses) < len(recorded) {
+		t.Fatalf("cassette has %d responses, want at least %d", len(cassette.Responses), len(recorded))
+	}
+
+	// 回放时不访问模拟接口；用另一个用户请求，避免与录制时的数据混在一起
+	upstream.Close()
+	cfg.Models = cassette.Replay()
+	replayed := runScenario(t, client{t: t, engine: engine, token: newUser(t, "it_replay_"+suffix)}, theme)
+
+	for name, want := range recorded {
+		if replayed[name] != want {
+			t.Errorf("%s: replayed %q, recorded %q", name, replayed[name], want)
+		}
+	}
+}
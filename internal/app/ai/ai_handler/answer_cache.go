@@ -0,0 +1,25 @@
+package ai_handler
+
+import (
+	"Programming-Demo/internal/app/ai/ai_service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetAnswerCacheStats 查看语义缓存的命中统计（管理员）
+func GetAnswerCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    ai_service.GetAnswerCacheStats(),
+	})
+}
+
+// ClearAnswerCache 清空语义缓存（管理员），发现缓存的回答有误时使用
+func ClearAnswerCache(c *gin.Context) {
+	ai_service.ClearAnswerCache()
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已清空语义缓存",
+	})
+}
@@ -0,0 +1,168 @@
+package ai_handler
+
+import (
+	"Programming-Demo/core/libx"
+	"Programming-Demo/internal/app/ai/ai_dto"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RegenerateReply 重新生成一条回复，原回复保留在另一个分支中
+func RegenerateReply(c *gin.Context) {
+	uid := libx.Uid(c)
+	var req ai_dto.RegenerateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	theme, err := ai_service.GetTheme(c.Request.Context(), uid, req.ThemeID)
+	if err != nil {
+		respondBranchError(c, err)
+		return
+	}
+	message, err := ai_service.GetMessage(c.Request.Context(), uid, theme.ID, req.MessageID)
+	if err != nil {
+		respondBranchError(c, err)
+		return
+	}
+	question, err := ai_service.QuestionOf(c.Request.Context(), message)
+	if err != nil {
+		respondBranchError(c, err)
+		return
+	}
+	if req.Model == "" {
+		req.Model = question.Model
+	}
+
+	// 上下文截止到提问之前，问题本身作为最新一条消息发送
+	if err := ai_service.InitCache(); err != nil {
+		fmt.Printf("Failed to initialize cache: %v\n", err)
+	}
+	chatCtx, err := ai_service.LoadBranchContext(c.Request.Context(), uid, theme.ID, question.ParentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取对话上下文失败", "error": err.Error()})
+		return
+	}
+	if err := ai_service.RefreshThemeLastMessage(uid, theme.ID); err != nil {
+		fmt.Printf("Failed to update theme: %v\n", err)
+	}
+
+	answerTurn(c, chatTurn{
+		uid:         uid,
+		theme:       theme,
+		req:         ai_dto.ChatReq{Model: req.Model, Content: question.Content, ThemeID: theme.ID, Theme: theme.Theme, Search: req.Search},
+		chatCtx:     chatCtx,
+		userMessage: *question,
+		regenerate:  true,
+	})
+}
+
+// EditMessage 编辑一条用户消息：在原消息的位置开出新分支并重新回答，原消息及其后续对话保留
+func EditMessage(c *gin.Context) {
+	uid := libx.Uid(c)
+	var req ai_dto.EditMessageReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	theme, err := ai_service.GetTheme(c.Request.Context(), uid, req.ThemeID)
+	if err != nil {
+		respondBranchError(c, err)
+		return
+	}
+	original, err := ai_service.GetMessage(c.Request.Context(), uid, theme.ID, req.MessageID)
+	if err != nil {
+		respondBranchError(c, err)
+		return
+	}
+	if original.Role != "user" {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "只能编辑用户消息"})
+		return
+	}
+	if req.Model == "" {
+		req.Model = original.Model
+	}
+
+	if err := ai_service.InitCache(); err != nil {
+		fmt.Printf("Failed to initialize cache: %v\n", err)
+	}
+	chatCtx, err := ai_service.LoadBranchContext(c.Request.Context(), uid, theme.ID, original.ParentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取对话上下文失败", "error": err.Error()})
+		return
+	}
+
+	// 新消息与原消息有相同的父消息，沿用原消息附带的文件
+	userMessage := ai_entity.ChatHistory{
+		UserID:      uid,
+		ThemeID:     theme.ID,
+		ParentID:    original.ParentID,
+		Model:       req.Model,
+		Role:        "user",
+		Content:     req.Content,
+		Endpoint:    ai_service.EndpointChat,
+		Attachments: original.Attachments,
+	}
+	if err := ai_service.SaveQuestion(c.Request.Context(), &userMessage); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "保存用户消息失败", "error": err.Error()})
+		return
+	}
+	if err := ai_service.RefreshThemeLastMessage(uid, theme.ID); err != nil {
+		fmt.Printf("Failed to update theme: %v\n", err)
+	}
+
+	answerTurn(c, chatTurn{
+		uid:         uid,
+		theme:       theme,
+		req:         ai_dto.ChatReq{Model: req.Model, Content: req.Content, ThemeID: theme.ID, Theme: theme.Theme, Search: req.Search},
+		chatCtx:     chatCtx,
+		userMessage: userMessage,
+	})
+}
+
+// SwitchBranch 切换到包含指定消息的分支，返回切换后的历史记录
+func SwitchBranch(c *gin.Context) {
+	uid := libx.Uid(c)
+	var req ai_dto.SwitchBranchReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	head, err := ai_service.SwitchBranch(c.Request.Context(), uid, req.ThemeID, req.MessageID)
+	if err != nil {
+		respondBranchError(c, err)
+		return
+	}
+	page, err := ai_service.GetHistoryPage(c.Request.Context(), uid, req.ThemeID, ai_service.HistoryCursor{})
+	if errors.Is(err, ai_service.ErrThemeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取历史记录失败", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":            200,
+		"message":         "success",
+		"head_id":         head,
+		"data":            page.Messages,
+		"has_more_before": page.HasMoreBefore,
+	})
+}
+
+func respondBranchError(c *gin.Context, err error) {
+	if errors.Is(err, ai_service.ErrMessageNotFound) || errors.Is(err, ai_service.ErrThemeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "读取消息失败", "error": err.Error()})
+}
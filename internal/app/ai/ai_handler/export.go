@@ -0,0 +1,53 @@
+package ai_handler
+
+import (
+	"Programming-Demo/core/libx"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"Programming-Demo/pkg/utils/export"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+)
+
+// 导出聊天主题的完整对话，format 为 md、docx 或 pdf
+func ExportChatTheme(c *gin.Context) {
+	uid := libx.Uid(c)
+	var themeID uint
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &themeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "无效的主题ID格式",
+			"error":   err.Error(),
+		})
+		return
+	}
+	format := c.DefaultQuery("format", export.FormatMarkdown)
+	contentType, ok := export.Formats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "不支持的导出格式，可选 md、docx、pdf"})
+		return
+	}
+
+	doc, err := ai_service.ExportTheme(c.Request.Context(), uid, themeID)
+	if errors.Is(err, ai_service.ErrThemeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "读取对话失败", "error": err.Error()})
+		return
+	}
+	data, err := export.Render(doc, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "生成文件失败", "error": err.Error()})
+		return
+	}
+
+	// 主题名称可能包含中文，同时提供 ASCII 文件名
+	filename := doc.Title + "." + format
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"chat-%d.%s\"; filename*=UTF-8''%s",
+		themeID, format, url.PathEscape(filename)))
+	c.Data(http.StatusOK, contentType, data)
+}
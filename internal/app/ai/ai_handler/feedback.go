@@ -0,0 +1,87 @@
+package ai_handler
+
+import (
+	"Programming-Demo/core/libx"
+	"Programming-Demo/internal/app/ai/ai_dto"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+// 评价一条回答，重复评价会覆盖之前的结果
+func SubmitFeedback(c *gin.Context) {
+	uid := libx.Uid(c)
+	var req ai_dto.FeedbackReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	feedback, err := ai_service.SubmitFeedback(c.Request.Context(), uid, req.MessageID, req.Rating, req.Comment, req.Correction)
+	if errors.Is(err, ai_service.ErrFeedbackTarget) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "提交评价失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "感谢您的评价", "data": feedback})
+}
+
+// GetFeedbackStats 按模型、提示词、接口或时间统计回答评价（管理员）
+func GetFeedbackStats(c *gin.Context) {
+	filter, err := parseFeedbackFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	groupBy := c.DefaultQuery("group_by", "model")
+	stats, err := ai_service.FeedbackStats(c.Request.Context(), groupBy, filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "获取评价统计失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": gin.H{"group_by": groupBy, "stats": stats}})
+}
+
+// ExportLowRatedFeedback 以 JSONL 导出差评的回答及用户的说明和纠正（管理员），用于调整提示词
+func ExportLowRatedFeedback(c *gin.Context) {
+	filter, err := parseFeedbackFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	filename := fmt.Sprintf("low_rated_%s.jsonl", time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+	// 响应已经开始写出，出错时只能记录日志
+	count, err := ai_service.ExportLowRated(c.Request.Context(), filter, c.Writer)
+	if err != nil {
+		log.Printf("Failed to export low rated answers after %d lines: %v", count, err)
+	}
+}
+
+// 解析 from、to（日期，包含当天）、model、endpoint 筛选参数
+func parseFeedbackFilter(c *gin.Context) (ai_service.FeedbackFilter, error) {
+	filter := ai_service.FeedbackFilter{Model: c.Query("model"), Endpoint: c.Query("endpoint")}
+	if s := c.Query("from"); s != "" {
+		from, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("无效的开始日期: %s", s)
+		}
+		filter.From = from
+	}
+	if s := c.Query("to"); s != "" {
+		to, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("无效的结束日期: %s", s)
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
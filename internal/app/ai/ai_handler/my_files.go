@@ -0,0 +1,55 @@
+package ai_handler
+
+import (
+	"Programming-Demo/core/libx"
+	"Programming-Demo/internal/app/ai/ai_dto"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"Programming-Demo/internal/app/prompt/prompt_service"
+	"Programming-Demo/pkg/utils/ai"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// AskMyFiles 在本人上传并审核通过的文件中检索相关片段并回答，响应中的 references 对应回答里的 [编号]
+func AskMyFiles(c *gin.Context) {
+	uid := libx.Uid(c)
+	var req ai_dto.AskMyFilesReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	if !ai.IsModelEnabled(req.Model) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "模型错误"})
+		return
+	}
+
+	refs, err := ai_service.SearchMyFiles(c.Request.Context(), uid, req.Question, req.FileIDs, req.TopK)
+	if errors.Is(err, ai_service.ErrMyFileNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+	if errors.Is(err, ai_service.ErrNoIndexedFiles) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "检索文件失败", "error": err.Error()})
+		return
+	}
+	if len(refs) == 0 {
+		c.JSON(http.StatusOK, gin.H{"code": 200, "message": "没有在你的文件中找到与问题相关的内容", "references": refs})
+		return
+	}
+
+	data := prompt_service.FileQAData{Question: req.Question}
+	for _, r := range refs {
+		data.Excerpts = append(data.Excerpts, prompt_service.FileExcerpt{Index: r.Index, FileID: r.FileID, Filename: r.Filename, Offset: r.Offset, Content: r.Content})
+	}
+	p, err := prompt_service.Render(c.Request.Context(), prompt_service.FileQA, data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成提示词失败", "error": err.Error()})
+		return
+	}
+	respondGenerationWith(c, ai_service.EndpointFiles, req.Model, p.Text, p.Label(), gin.H{"references": refs})
+}
@@ -0,0 +1,135 @@
+package ai_handler
+
+import (
+	"Programming-Demo/core/libx"
+	"Programming-Demo/internal/app/ai/ai_dto"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"Programming-Demo/pkg/utils/export"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 访问分享时通过请求头传递密码，避免出现在链接和访问日志中
+const SharePasswordHeader = "X-Share-Password"
+
+// 为聊天主题创建只读分享链接
+func CreateChatShare(c *gin.Context) {
+	uid := libx.Uid(c)
+	var themeID uint
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &themeID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的主题ID格式", "error": err.Error()})
+		return
+	}
+	var req ai_dto.CreateShareReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+
+	share, err := ai_service.CreateShare(c.Request.Context(), uid, themeID, time.Duration(req.ExpiresIn)*time.Hour, req.Password)
+	if errors.Is(err, ai_service.ErrSharePasswordLong) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if errors.Is(err, ai_service.ErrThemeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "创建分享失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": share})
+}
+
+// 列出当前用户的分享链接，可按 theme_id 筛选
+func ListChatShares(c *gin.Context) {
+	uid := libx.Uid(c)
+	var themeID uint
+	if s := c.Query("theme_id"); s != "" {
+		if _, err := fmt.Sscanf(s, "%d", &themeID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的主题ID格式", "error": err.Error()})
+			return
+		}
+	}
+	shares, err := ai_service.ListShares(c.Request.Context(), uid, themeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取分享失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": shares})
+}
+
+// 撤销分享链接
+func RevokeChatShare(c *gin.Context) {
+	uid := libx.Uid(c)
+	var shareID uint
+	if _, err := fmt.Sscanf(c.Param("id"), "%d", &shareID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "无效的分享ID格式", "error": err.Error()})
+		return
+	}
+	if err := ai_service.RevokeShare(c.Request.Context(), uid, shareID); err != nil {
+		respondShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "撤销成功"})
+}
+
+// 公开访问分享的对话快照，不需要登录
+func GetSharedChat(c *gin.Context) {
+	share, doc, err := ai_service.OpenShare(c.Request.Context(), c.Param("token"), c.GetHeader(SharePasswordHeader))
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"title":      share.Title,
+			"created_at": share.CreatedAt,
+			"expires_at": share.ExpiresAt,
+			"chat":       doc,
+		},
+	})
+}
+
+// 下载分享的对话快照，format 为 md、docx 或 pdf
+func ExportSharedChat(c *gin.Context) {
+	format := c.DefaultQuery("format", export.FormatMarkdown)
+	contentType, ok := export.Formats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "不支持的导出格式，可选 md、docx、pdf"})
+		return
+	}
+	share, doc, err := ai_service.OpenShare(c.Request.Context(), c.Param("token"), c.GetHeader(SharePasswordHeader))
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+	data, err := export.Render(doc, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "生成文件失败", "error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"share-%d.%s\"; filename*=UTF-8''%s",
+		share.ID, format, url.PathEscape(doc.Title+"."+format)))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+func respondShareError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ai_service.ErrShareNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+	case errors.Is(err, ai_service.ErrShareExpired):
+		c.JSON(http.StatusGone, gin.H{"code": 410, "message": err.Error()})
+	case errors.Is(err, ai_service.ErrSharePasswordNeeded), errors.Is(err, ai_service.ErrSharePasswordWrong):
+		c.JSON(http.StatusUnauthorized, gin.H{"code": 401, "message": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "读取分享失败", "error": err.Error()})
+	}
+}
@@ -0,0 +1,220 @@
+package ai_handler
+
+import (
+	"Programming-Demo/core/libx"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"Programming-Demo/pkg/utils/ai"
+	"Programming-Demo/pkg/utils/civilcode"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 客户端通过 Accept: text/event-stream 请求流式输出
+func wantStream(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// 写入 SSE 响应头，并先推送一条 meta 事件（主题、搜索信息等）
+func startStream(c *gin.Context, meta gin.H) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if meta != nil {
+		c.SSEvent("meta", meta)
+	}
+	c.Writer.Flush()
+}
+
+// 客户端在服务端返回前关闭了连接（沿用 nginx 的 499）
+const StatusClientClosedRequest = 499
+
+// 一次模型调用的结果
+type modelReply struct {
+	Content   string // 回复内容，失败时为错误信息
+	Model     string // 实际回答的模型，发生降级时与请求的模型不同
+	Reasoning string // 推理模型的思维链
+	Code      int
+	Partial   bool // 是否因客户端断开而只生成了一部分
+}
+
+// 调用模型生成回复，stream 为 true 时以 SSE 方式逐段转发。
+// 客户端断开时请求的 context 被取消，上游调用随之中止
+func generate(c *gin.Context, stream bool, model string, messages []ai.Message) modelReply {
+	reply, _ := generateWithTools(c, stream, model, messages, nil)
+	return reply
+}
+
+// 与 generate 相同，但允许模型调用 tools，每次工具调用以 tool 事件推送。
+// 返回的消息为中间产生的工具调用和工具结果，由调用方记录到对话历史
+func generateWithTools(c *gin.Context, stream bool, model string, messages []ai.Message, tools []ai.Tool) (modelReply, []ai.Message) {
+	ctx := c.Request.Context()
+	var onDelta ai.StreamHandler
+	var onTool ai.ToolHandler
+	var opts []ai.ChatOption
+	if stream {
+		onDelta = forwardEvent(c, "delta")
+		// 思维链作为单独的事件推送，前端可折叠显示
+		opts = append(opts, ai.WithReasoningHandler(forwardEvent(c, "reasoning")))
+		onTool = forwardToolEvent(c)
+	}
+
+	resp, trail, err := ai.CompleteWithTools(ctx, model, messages, tools, onDelta, onTool, opts...)
+	if err != nil {
+		if ctx.Err() != nil {
+			// 流式输出已经推送过的内容保留为部分回复，否则视为请求取消
+			if stream && resp != nil && resp.Content != "" {
+				return modelReply{Content: resp.Content, Model: resp.Model, Reasoning: resp.Reasoning, Code: 200, Partial: true}, trail
+			}
+			return modelReply{Content: "请求已取消", Model: model, Code: StatusClientClosedRequest}, nil
+		}
+		var pe *ai.ProviderError
+		if errors.As(err, &pe) {
+			return modelReply{Content: pe.Message, Model: model, Code: pe.Code}, nil
+		}
+		return modelReply{Content: err.Error(), Model: model, Code: 500}, nil
+	}
+	return modelReply{Content: resp.Content, Model: resp.Model, Reasoning: resp.Reasoning, Code: 200}, trail
+}
+
+// 语义缓存命中时不调用模型，流式模式下把缓存的回答作为一条 delta 事件推送
+func cachedReply(c *gin.Context, stream bool, answer *ai_service.CachedAnswer) modelReply {
+	reply := modelReply{Content: answer.Content, Model: answer.Model, Reasoning: answer.Reasoning, Code: 200}
+	if stream {
+		if answer.Reasoning != "" {
+			if err := forwardEvent(c, "reasoning")(answer.Reasoning); err != nil {
+				return modelReply{Content: "请求已取消", Model: answer.Model, Code: StatusClientClosedRequest}
+			}
+		}
+		if err := forwardEvent(c, "delta")(answer.Content); err != nil {
+			return modelReply{Content: "请求已取消", Model: answer.Model, Code: StatusClientClosedRequest}
+		}
+	}
+	return reply
+}
+
+// 将工具调用及其结果作为 tool 事件推送给客户端
+func forwardToolEvent(c *gin.Context) ai.ToolHandler {
+	return func(call ai.ToolCall, result string) error {
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+		c.SSEvent("tool", gin.H{"id": call.ID, "name": call.Name, "arguments": call.Arguments, "result": result})
+		c.Writer.Flush()
+		return nil
+	}
+}
+
+// 将增量内容作为指定类型的 SSE 事件转发给客户端
+func forwardEvent(c *gin.Context, event string) ai.StreamHandler {
+	return func(delta string) error {
+		// 客户端断开后停止转发
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+		c.SSEvent(event, gin.H{"content": delta})
+		c.Writer.Flush()
+		return nil
+	}
+}
+
+// 推送结束事件
+func streamDone(c *gin.Context, data gin.H) {
+	c.SSEvent("done", data)
+	c.Writer.Flush()
+}
+
+// 推送错误事件，响应头已发送后只能通过事件告知客户端
+func streamError(c *gin.Context, message string, errMsg string) {
+	c.SSEvent("error", gin.H{"message": message, "error": errMsg})
+	c.Writer.Flush()
+}
+
+// 按当前输出模式返回错误
+func respondError(c *gin.Context, stream bool, status int, message string, errMsg string) {
+	if stream {
+		streamError(c, message, errMsg)
+		return
+	}
+	c.JSON(status, gin.H{"message": message, "error": errMsg})
+}
+
+// 文书生成类接口的通用输出：按请求头选择流式或一次性返回。
+// 回答会被保存，客户端中途断开时保存已生成的部分，响应中的 message_id 用于评价
+func respondGeneration(c *gin.Context, endpoint string, model string, prompt string, label string) {
+	respondGenerationWith(c, endpoint, model, prompt, label, nil)
+}
+
+// 与 respondGeneration 相同，extra 中的字段随 meta 事件或响应一起返回
+func respondGenerationWith(c *gin.Context, endpoint string, model string, prompt string, label string, extra gin.H) {
+	if extra == nil {
+		extra = gin.H{}
+	}
+	extra["prompt"] = label
+	stream := wantStream(c)
+	if stream {
+		startStream(c, extra)
+	}
+	reply := generate(c, stream, model, ai.UserMessages(prompt))
+	if reply.Code != 200 {
+		respondError(c, stream, http.StatusBadRequest, "调用ai接口失败", reply.Content)
+		return
+	}
+
+	data := gin.H{"code": reply.Code, "message": reply.Content, "model": reply.Model, "reasoning": reply.Reasoning, "citations": civilcode.VerifyCitations(reply.Content)}
+	saveGeneration(c, endpoint, prompt, label, reply.Model, reply.Content, reply.Reasoning, reply.Partial, data)
+	if stream {
+		data["partial"] = reply.Partial
+		streamDone(c, data)
+		return
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	c.JSON(http.StatusOK, data)
+}
+
+// 文书生成类接口的结构化输出：要求模型返回 JSON，解析校验后放在 data 字段中。
+// doc 为 ai_dto 中对应文书结构的指针，结构化输出不支持流式
+func respondStructured(c *gin.Context, endpoint string, model string, prompt string, label string, doc ai.Validator) {
+	resp, err := ai.CompleteJSON(c.Request.Context(), model, ai.UserMessages(prompt), doc, ai.WithTemperature(0.3))
+	if err != nil {
+		var pe *ai.ProviderError
+		if errors.As(err, &pe) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "调用ai接口失败", "error": pe.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "生成结构化文书失败", "error": err.Error()})
+		return
+	}
+	data := gin.H{"code": 200, "message": "success", "data": doc, "model": resp.Model, "prompt": label, "citations": civilcode.VerifyCitations(resp.Content)}
+	saveGeneration(c, endpoint, prompt, label, resp.Model, resp.Content, resp.Reasoning, false, data)
+	c.JSON(http.StatusOK, data)
+}
+
+// 保存文书生成的输入和回答，成功时把 message_id 加入响应；保存失败不影响返回结果
+func saveGeneration(c *gin.Context, endpoint string, prompt string, label string, model string, content string, reasoning string, partial bool, data gin.H) {
+	ctx, cancel := persistContext(c)
+	defer cancel()
+	id, err := ai_service.SaveGeneration(ctx, libx.Uid(c), endpoint, model, prompt, label, content, reasoning, partial)
+	if err != nil {
+		log.Printf("Failed to save generation: %v", err)
+		return
+	}
+	data["message_id"] = id
+}
+
+// 保存生成结果的最长时间
+const persistTimeout = 10 * time.Second
+
+// 生成结束后写库使用的上下文：客户端断开后仍要保存已生成的部分，只限制写库的时长
+func persistContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(c.Request.Context()), persistTimeout)
+}
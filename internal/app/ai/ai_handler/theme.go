@@ -0,0 +1,79 @@
+package ai_handler
+
+import (
+	"Programming-Demo/core/libx"
+	"Programming-Demo/internal/app/ai/ai_dto"
+	"Programming-Demo/internal/app/ai/ai_service"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 置顶或取消置顶聊天主题
+func PinChatTheme(c *gin.Context) {
+	uid := libx.Uid(c)
+	var req ai_dto.PinThemeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	if err := ai_service.SetThemePinned(c.Request.Context(), uid, req.ID, req.Pinned); err != nil {
+		respondThemeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "修改成功"})
+}
+
+// 归档或取消归档聊天主题
+func ArchiveChatTheme(c *gin.Context) {
+	uid := libx.Uid(c)
+	var req ai_dto.ArchiveThemeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	if err := ai_service.SetThemeArchived(c.Request.Context(), uid, req.ID, req.Archived); err != nil {
+		respondThemeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "修改成功"})
+}
+
+// 设置聊天主题的标签
+func SetChatThemeTags(c *gin.Context) {
+	uid := libx.Uid(c)
+	var req ai_dto.ThemeTagsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	tags, err := ai_service.SetThemeTags(c.Request.Context(), uid, req.ID, req.Tags)
+	if errors.Is(err, ai_service.ErrInvalidTag) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": err.Error()})
+		return
+	}
+	if err != nil {
+		respondThemeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "修改成功", "data": tags})
+}
+
+// 获取用户用过的全部标签
+func GetChatThemeTags(c *gin.Context) {
+	uid := libx.Uid(c)
+	tags, err := ai_service.ListThemeTags(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "获取标签失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": tags})
+}
+
+func respondThemeError(c *gin.Context, err error) {
+	if errors.Is(err, ai_service.ErrThemeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "修改主题失败", "error": err.Error()})
+}
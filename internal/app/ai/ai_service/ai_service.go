@@ -3,10 +3,15 @@ package ai_service
 import (
 	"Programming-Demo/core/gin/dbs"
 	"Programming-Demo/internal/app/ai/ai_entity"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/dgraph-io/ristretto"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
 	"time"
+	"unicode/utf8"
 )
 
 var (
@@ -14,11 +19,19 @@ var (
 	cacheInstance *ristretto.Cache
 )
 
+var (
+	ErrThemeNotFound  = errors.New("主题不存在或无权限访问")
+	ErrThemeNameTaken = errors.New("已有同名主题")
+)
+
 const (
 	DefaultHistoryLimit = 50             // 默认历史记录限制数量
 	CacheTTL            = 24 * time.Hour // 缓存生存时间
 	CacheMaxCost        = int64(1 << 30) // 最大缓存大小（1GB）
 	CacheNumCounters    = int64(1e7)     // 估计存储键数量
+
+	MaxThemeNameLength   = 50  // 主题名称的最大字数，与 ChatTheme.Theme 的列长度一致
+	maxThemeNameAttempts = 100 // 新建主题时最多尝试的序号
 )
 
 // 初始化缓存
@@ -45,27 +58,20 @@ func InitCache() error {
 }
 
 // 生成缓存键
-func getCacheKey(userID uint, theme string) string {
-	return fmt.Sprintf("chat:%d:%s", userID, theme)
+func getCacheKey(userID uint, themeID uint) string {
+	return fmt.Sprintf("chat:%d:%d", userID, themeID)
 }
 
-// 保存聊天缓存到Ristretto
-func SaveChatCache(userID uint, theme string, messages []ai_entity.ChatHistory) error {
+// 生成对话上下文缓存键
+func getContextCacheKey(userID uint, themeID uint) string {
+	return fmt.Sprintf("ctx:%d:%d", userID, themeID)
+}
+
+func saveCache(cacheKey string, cache ai_entity.LocalChatCache) error {
 	if err := InitCache(); err != nil {
 		return err
 	}
 
-	// 创建缓存对象
-	cache := ai_entity.LocalChatCache{
-		UserID:      userID,
-		Theme:       theme,
-		LastUpdated: time.Now(),
-		Messages:    messages,
-		Metadata: map[string]interface{}{
-			"count": len(messages),
-		},
-	}
-
 	// 将结构体序列化为JSON字节，以便于缓存存储
 	data, err := json.Marshal(cache)
 	if err != nil {
@@ -73,7 +79,6 @@ func SaveChatCache(userID uint, theme string, messages []ai_entity.ChatHistory)
 	}
 
 	// 存储到Ristretto缓存
-	cacheKey := getCacheKey(userID, theme)
 	success := cacheInstance.SetWithTTL(cacheKey, data, 1, CacheTTL)
 	if !success {
 		return fmt.Errorf("failed to save data to cache")
@@ -85,12 +90,15 @@ func SaveChatCache(userID uint, theme string, messages []ai_entity.ChatHistory)
 }
 
 // 从Ristretto加载聊天缓存
-func LoadChatCache(userID uint, theme string) (*ai_entity.LocalChatCache, error) {
+func LoadChatCache(userID uint, themeID uint) (*ai_entity.LocalChatCache, error) {
+	return loadCache(getCacheKey(userID, themeID))
+}
+
+func loadCache(cacheKey string) (*ai_entity.LocalChatCache, error) {
 	if err := InitCache(); err != nil {
 		return nil, err
 	}
 
-	cacheKey := getCacheKey(userID, theme)
 	cachedValue, found := cacheInstance.Get(cacheKey)
 	if !found {
 		return nil, nil // 缓存未命中
@@ -110,14 +118,14 @@ func LoadChatCache(userID uint, theme string) (*ai_entity.LocalChatCache, error)
 	return &cache, nil
 }
 
-// 删除聊天缓存
-func DeleteChatCache(userID uint, theme string) error {
+// 删除聊天缓存（包括对话上下文缓存）
+func DeleteChatCache(userID uint, themeID uint) error {
 	if err := InitCache(); err != nil {
 		return err
 	}
 
-	cacheKey := getCacheKey(userID, theme)
-	cacheInstance.Del(cacheKey)
+	cacheInstance.Del(getCacheKey(userID, themeID))
+	cacheInstance.Del(getContextCacheKey(userID, themeID))
 	return nil
 }
 
@@ -136,107 +144,121 @@ func IsCacheValid(cache *ai_entity.LocalChatCache, maxAge time.Duration) bool {
 	return time.Since(cache.LastUpdated) <= maxAge
 }
 
-// 更新主题的最后消息时间
-func RefreshThemeLastMessage(userID uint, theme string) error {
+// 更新主题的最后消息时间，已归档的主题继续对话时取消归档
+func RefreshThemeLastMessage(userID uint, themeID uint) error {
 	now := time.Now()
 
 	// 更新主题的最后消息时间和更新时间
 	result := dbs.DB.Model(&ai_entity.ChatTheme{}).
-		Where("user_id = ? AND theme = ?", userID, theme).
+		Where("id = ? AND user_id = ?", themeID, userID).
 		Updates(map[string]interface{}{
 			"last_message": now,
 			"updated_at":   now,
+			"archived":     false,
 		})
 
 	if result.Error != nil {
 		return fmt.Errorf("failed to refresh theme last message: %w", result.Error)
 	}
+	return nil
+}
+
+// GetTheme 读取用户的主题
+func GetTheme(ctx context.Context, userID uint, themeID uint) (*ai_entity.ChatTheme, error) {
+	var theme ai_entity.ChatTheme
+	err := dbs.DB.WithContext(ctx).Where("id = ? AND user_id = ?", themeID, userID).First(&theme).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrThemeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat theme: %w", err)
+	}
+	return &theme, nil
+}
 
-	// 如果没有更新任何记录，说明主题不存在，需要创建
-	if result.RowsAffected == 0 {
-		newTheme := ai_entity.ChatTheme{
-			UserID:      userID,
-			Theme:       theme,
-			LastMessage: now,
-			CreatedAt:   now,
-			UpdatedAt:   now,
+// FindOrCreateTheme 按名称查找用户的主题，不存在时新建（兼容只传主题名称的客户端）
+func FindOrCreateTheme(ctx context.Context, userID uint, name string) (*ai_entity.ChatTheme, error) {
+	name = themeName(name, 1)
+	for {
+		var theme ai_entity.ChatTheme
+		err := dbs.DB.WithContext(ctx).Where("user_id = ? AND theme = ?", userID, name).First(&theme).Error
+		if err == nil {
+			return &theme, nil
 		}
-
-		if err := dbs.DB.Create(&newTheme).Error; err != nil {
-			return fmt.Errorf("failed to create new theme: %w", err)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get chat theme: %w", err)
+		}
+		created, err := createTheme(ctx, userID, name)
+		// 同名主题刚被并发的请求创建，重新读取
+		if !isDuplicateKey(err) {
+			return created, err
 		}
 	}
-
-	return nil
 }
 
-// 获取指定主题的最近聊天记录
-func GetRecentChatHistoryByTheme(userID uint, theme string, limit int) ([]ai_entity.ChatHistory, error) {
-	if limit <= 0 {
-		limit = DefaultHistoryLimit
+// CreateTheme 新建主题，名称已被该用户使用时加上序号，如“劳动合同纠纷(2)”。
+// 直接插入并由唯一索引判断重名，并发创建同名主题时依次使用下一个序号
+func CreateTheme(ctx context.Context, userID uint, name string) (*ai_entity.ChatTheme, error) {
+	for i := 1; i <= maxThemeNameAttempts; i++ {
+		theme, err := createTheme(ctx, userID, themeName(name, i))
+		if !isDuplicateKey(err) {
+			return theme, err
+		}
 	}
+	return nil, fmt.Errorf("failed to create new theme: too many themes named %q", name)
+}
 
-	var histories []ai_entity.ChatHistory
-	if err := dbs.DB.Where("user_id = ? AND theme = ?", userID, theme).
-		Order("created_at DESC").
-		Limit(limit).
-		Find(&histories).Error; err != nil {
-		return nil, fmt.Errorf("failed to get recent chat history: %w", err)
+// 第 n 个同名主题的名称，n 为 1 时不加序号；截断原名称，保证加上序号后不超出列长度
+func themeName(name string, n int) string {
+	suffix := ""
+	if n > 1 {
+		suffix = fmt.Sprintf("(%d)", n)
 	}
-
-	// 反转顺序以按时间正序排列
-	for i, j := 0, len(histories)-1; i < j; i, j = i+1, j-1 {
-		histories[i], histories[j] = histories[j], histories[i]
+	runes := []rune(name)
+	if max := MaxThemeNameLength - utf8.RuneCountInString(suffix); len(runes) > max {
+		runes = runes[:max]
 	}
-
-	return histories, nil
+	return string(runes) + suffix
 }
 
-// 更新现有主题的最后消息时间或创建新主题
-func UpdateOrCreateTheme(userID uint, themeName string) error {
+func createTheme(ctx context.Context, userID uint, name string) (*ai_entity.ChatTheme, error) {
 	now := time.Now()
-
-	// 尝试查找现有主题
-	var count int64
-	dbs.DB.Model(&ai_entity.ChatTheme{}).
-		Where("user_id = ? AND theme = ?", userID, themeName).
-		Count(&count)
-
-	if count == 0 {
-		// 创建新主题
-		theme := ai_entity.ChatTheme{
-			UserID:      userID,
-			Theme:       themeName,
-			LastMessage: now,
-			CreatedAt:   now,
-			UpdatedAt:   now,
-		}
-		return dbs.DB.Create(&theme).Error
+	theme := ai_entity.ChatTheme{
+		UserID:      userID,
+		Theme:       name,
+		LastMessage: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
-
-	// 更新现有主题
-	return dbs.DB.Exec(`
-		UPDATE chat_themes 
-		SET last_message = ?, updated_at = ? 
-		WHERE user_id = ? AND theme = ?
-	`, now, now, userID, themeName).Error
+	if err := dbs.DB.WithContext(ctx).Create(&theme).Error; err != nil {
+		return nil, fmt.Errorf("failed to create new theme: %w", err)
+	}
+	return &theme, nil
 }
 
-// 按时间正序获取指定主题的聊天历史
-func GetChatHistoryByTheme(userID uint, theme string, limit int) ([]ai_entity.ChatHistory, error) {
-	if limit <= 0 {
-		limit = DefaultHistoryLimit
+// RenameTheme 修改主题名称，历史记录按主题ID关联，不受影响
+func RenameTheme(ctx context.Context, userID uint, themeID uint, name string) error {
+	var count int64
+	if err := dbs.DB.WithContext(ctx).Model(&ai_entity.ChatTheme{}).
+		Where("user_id = ? AND theme = ? AND id <> ?", userID, name, themeID).
+		Count(&count).Error; err != nil {
+		return err
 	}
-
-	var histories []ai_entity.ChatHistory
-	if err := dbs.DB.Where("user_id = ? AND theme = ?", userID, theme).
-		Order("created_at ASC").
-		Limit(limit).
-		Find(&histories).Error; err != nil {
-		return nil, fmt.Errorf("failed to get chat history: %w", err)
+	if count > 0 {
+		return ErrThemeNameTaken
+	}
+	// 并发改名时检查可能都通过，由唯一索引兜底
+	err := updateTheme(ctx, userID, themeID, map[string]interface{}{"theme": name})
+	if isDuplicateKey(err) {
+		return ErrThemeNameTaken
 	}
+	return err
+}
 
-	return histories, nil
+// 是否违反唯一索引（MySQL 1062 错误）
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
 }
 
 // 获取用户的所有聊天主题
@@ -252,7 +274,7 @@ func GetAllChatThemes(userID uint) ([]ai_entity.ChatTheme, error) {
 }
 
 // 删除指定用户的指定主题的所有聊天历史记录
-func DeleteChatHistoryByTheme(userID uint, theme string) error {
+func DeleteChatHistoryByTheme(userID uint, themeID uint) error {
 	// 使用事务确保数据一致性
 	tx := dbs.DB.Begin()
 	defer func() {
@@ -261,15 +283,35 @@ func DeleteChatHistoryByTheme(userID uint, theme string) error {
 		}
 	}()
 
+	// 删除消息的评价，需在删除聊天历史之前
+	if err := DeleteThemeFeedback(tx, userID, themeID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// 删除聊天历史记录
-	if err := tx.Where("user_id = ? AND theme = ?", userID, theme).
+	if err := tx.Where("user_id = ? AND theme_id = ?", userID, themeID).
 		Delete(&ai_entity.ChatHistory{}).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to delete chat history: %w", err)
 	}
 
+	// 删除主题标签、分享和摘要
+	if err := DeleteThemeTags(tx, themeID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := DeleteThemeShares(tx, themeID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := DeleteThemeSummaries(tx, themeID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// 删除主题记录
-	if err := tx.Where("user_id = ? AND theme = ?", userID, theme).
+	if err := tx.Where("id = ? AND user_id = ?", themeID, userID).
 		Delete(&ai_entity.ChatTheme{}).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to delete chat theme: %w", err)
@@ -0,0 +1,230 @@
+package ai_service
+
+import (
+	"Programming-Demo/config"
+	"Programming-Demo/pkg/utils/ai"
+	"context"
+	"log"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultAnswerCacheThreshold  = 0.95  // 默认相似度阈值（余弦相似度）
+	DefaultAnswerCacheTTL        = 86400 // 默认缓存时间（秒）
+	DefaultAnswerCacheMaxEntries = 1000  // 默认最多缓存的回答数
+)
+
+// CachedAnswer 缓存的回答
+type CachedAnswer struct {
+	Content   string
+	Model     string // 实际回答的模型
+	Reasoning string
+}
+
+type answerEntry struct {
+	CachedAnswer
+	bucket    string
+	userID    uint // 提问的用户，0 表示所有用户共享
+	question  string
+	embedding []float64
+	createdAt time.Time
+}
+
+// AnswerCacheStats 语义缓存的命中统计
+type AnswerCacheStats struct {
+	Enabled   bool    `json:"enabled"`
+	Entries   int     `json:"entries"`
+	Lookups   int64   `json:"lookups"`
+	Hits      int64   `json:"hits"`
+	Stores    int64   `json:"stores"`
+	HitRate   float64 `json:"hit_rate"`
+	Threshold float64 `json:"threshold"`
+	TTL       int     `json:"ttl"`
+}
+
+var (
+	answerEntries []*answerEntry
+	answerMux     sync.RWMutex
+	answerLookups atomic.Int64
+	answerHits    atomic.Int64
+	answerStores  atomic.Int64
+)
+
+// AnswerCacheEnabled 是否在配置中开启了语义缓存
+func AnswerCacheEnabled() bool {
+	return config.GetConfig().SemanticCache.Enabled
+}
+
+func answerCacheConfig() (threshold float64, ttl time.Duration, maxEntries int) {
+	cfg := config.GetConfig().SemanticCache
+	threshold, seconds, maxEntries := cfg.Threshold, cfg.TTL, cfg.MaxEntries
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultAnswerCacheThreshold
+	}
+	if seconds <= 0 {
+		seconds = DefaultAnswerCacheTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultAnswerCacheMaxEntries
+	}
+	return threshold, time.Duration(seconds) * time.Second, maxEntries
+}
+
+// AnswerQuery 一次语义缓存查询，未命中时用于保存生成的回答
+type AnswerQuery struct {
+	bucket    string
+	raw       string // 原始问题，用于判断能否共享
+	question  string
+	embedding []float64
+}
+
+// 可能是个人信息的内容：手机号、身份证号、银行卡号等连续数字，以及邮箱
+var personalInfoPattern = regexp.MustCompile(`\d{6,}|[\w.+-]+@[\w-]+\.[\w.]+`)
+
+// NewAnswerQuery 准备查询：同一模型、同一提示词版本的回答才能复用。
+// 向量化失败时只按归一化后的问题精确匹配
+func NewAnswerQuery(ctx context.Context, model string, prompt string, question string) *AnswerQuery {
+	q := &AnswerQuery{bucket: model + "|" + prompt, raw: question, question: normalizeQuestion(question)}
+	embedding, err := ai.GenerateEmbedding(ctx, question)
+	if err != nil {
+		log.Printf("语义缓存向量化失败，只使用精确匹配: %v", err)
+	} else {
+		q.embedding = embedding
+	}
+	return q
+}
+
+// Lookup 查找与问题最相似且超过阈值的回答，未命中时返回 nil。只会命中共享的回答和该用户自己的回答
+func (q *AnswerQuery) Lookup(userID uint) *CachedAnswer {
+	threshold, ttl, _ := answerCacheConfig()
+	answerLookups.Add(1)
+
+	answerMux.RLock()
+	defer answerMux.RUnlock()
+	var best *answerEntry
+	bestScore := threshold
+	for _, e := range answerEntries {
+		if e.bucket != q.bucket || (e.userID != 0 && e.userID != userID) || time.Since(e.createdAt) > ttl {
+			continue
+		}
+		score := 0.0
+		if e.question == q.question {
+			score = 1
+		} else if q.embedding != nil && e.embedding != nil {
+			score = cosineSimilarity(q.embedding, e.embedding)
+		}
+		if score >= bestScore {
+			best, bestScore = e, score
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	answerHits.Add(1)
+	answer := best.CachedAnswer
+	return &answer
+}
+
+// Store 保存回答，默认只对提问的用户本人命中。配置了 Shared 时，shareable 为 true（如没有读取用户文件）
+// 且问题和回答中不含个人信息的回答对所有用户共享
+func (q *AnswerQuery) Store(userID uint, shareable bool, answer CachedAnswer) {
+	_, ttl, maxEntries := answerCacheConfig()
+	entry := &answerEntry{
+		CachedAnswer: answer,
+		bucket:       q.bucket,
+		userID:       userID,
+		question:     q.question,
+		embedding:    q.embedding,
+		createdAt:    time.Now(),
+	}
+	if config.GetConfig().SemanticCache.Shared && shareable && isGenericAnswer(q.raw, answer.Content) {
+		entry.userID = 0
+	}
+
+	answerMux.Lock()
+	defer answerMux.Unlock()
+	// 清理过期的回答，超出上限时淘汰最早的
+	kept := answerEntries[:0]
+	for _, e := range answerEntries {
+		if time.Since(e.createdAt) <= ttl {
+			kept = append(kept, e)
+		}
+	}
+	kept = append(kept, entry)
+	if len(kept) > maxEntries {
+		kept = kept[len(kept)-maxEntries:]
+	}
+	answerEntries = kept
+	answerStores.Add(1)
+}
+
+// GetAnswerCacheStats 返回命中统计
+func GetAnswerCacheStats() AnswerCacheStats {
+	threshold, ttl, _ := answerCacheConfig()
+	answerMux.RLock()
+	entries := len(answerEntries)
+	answerMux.RUnlock()
+
+	stats := AnswerCacheStats{
+		Enabled:   AnswerCacheEnabled(),
+		Entries:   entries,
+		Lookups:   answerLookups.Load(),
+		Hits:      answerHits.Load(),
+		Stores:    answerStores.Load(),
+		Threshold: threshold,
+		TTL:       int(ttl / time.Second),
+	}
+	if stats.Lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(stats.Lookups)
+	}
+	return stats
+}
+
+// ClearAnswerCache 清空缓存的回答和统计
+func ClearAnswerCache() {
+	answerMux.Lock()
+	answerEntries = nil
+	answerMux.Unlock()
+	answerLookups.Store(0)
+	answerHits.Store(0)
+	answerStores.Store(0)
+}
+
+// 问题和回答中都没有个人信息时才可以共享
+func isGenericAnswer(question string, answer string) bool {
+	return !personalInfoPattern.MatchString(question) && !personalInfoPattern.MatchString(answer)
+}
+
+// 去掉空白和标点并转为小写，“试用期被辞退有补偿吗？”与“试用期被辞退 有补偿吗”视为同一问题
+func normalizeQuestion(question string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(question) {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
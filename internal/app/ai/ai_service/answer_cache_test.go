@@ -0,0 +1,39 @@
+package ai_service
+
+import (
+	"Programming-Demo/config"
+	"testing"
+)
+
+func TestAnswerCacheScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		shared    bool
+		shareable bool
+		question  string
+		answer    string
+		otherHit  bool // 其他用户能否命中
+	}{
+		{name: "private by default", shareable: true, question: "试用期被辞退有补偿吗", answer: "有"},
+		{name: "shared generic", shared: true, shareable: true, question: "试用期被辞退有补偿吗", answer: "有", otherHit: true},
+		{name: "used user file", shared: true, question: "试用期被辞退有补偿吗", answer: "有"},
+		{name: "phone in question", shared: true, shareable: true, question: "我的电话13812345678，被辞退有补偿吗", answer: "有"},
+		{name: "id card in answer", shared: true, shareable: true, question: "试用期被辞退有补偿吗", answer: "张三（身份证号11010519491231002X）可以主张补偿"},
+		{name: "email in question", shared: true, shareable: true, question: "请回复到 a.b@example.com", answer: "好的"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.SetConfig(&config.GlobalConfig{SemanticCache: config.SemanticCacheConfig{Enabled: true, Shared: tt.shared}})
+			ClearAnswerCache()
+			q := &AnswerQuery{bucket: "m|p", raw: tt.question, question: normalizeQuestion(tt.question)}
+			q.Store(1, tt.shareable, CachedAnswer{Content: tt.answer})
+
+			if q.Lookup(1) == nil {
+				t.Fatal("owner should hit")
+			}
+			if hit := q.Lookup(2) != nil; hit != tt.otherHit {
+				t.Fatalf("other user hit = %v, want %v", hit, tt.otherHit)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/File/file_entity"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/pkg/utils/ai"
+	"Programming-Demo/pkg/utils/filetext"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxAttachments      = 5    // 每条消息最多附带的文件数
+	AttachmentChunkSize = 800  // 附件分块的字数
+	MaxAttachmentTokens = 8000 // 附件内容最多占用的 token 数，超出时只发送与问题相关的分块
+)
+
+var ErrAttachmentNotFound = errors.New("文件不存在或没有访问权限")
+
+// AttachmentRef 消息附带的文件
+type AttachmentRef struct {
+	ID       uint   `json:"id"`
+	Filename string `json:"filename"`
+}
+
+// 用户可以读取的文件：本人上传的，或公开且审核通过的
+func readableFiles(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Where("status = ? AND (user_id = ? OR (public = ? AND audit_status = ?))", 1, userID, 1, "approved")
+}
+
+// ResolveAttachments 检查用户可以读取这些文件，按请求的顺序返回引用，重复的ID只保留一个
+func ResolveAttachments(ctx context.Context, userID uint, fileIDs []uint) ([]AttachmentRef, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+	if len(fileIDs) > MaxAttachments {
+		return nil, fmt.Errorf("每条消息最多附带 %d 个文件", MaxAttachments)
+	}
+	var files []file_entity.File
+	if err := readableFiles(dbs.DB.WithContext(ctx), userID).Where("id IN ?", fileIDs).Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+	byID := make(map[uint]file_entity.File, len(files))
+	for _, f := range files {
+		byID[f.ID] = f
+	}
+	refs := make([]AttachmentRef, 0, len(fileIDs))
+	seen := make(map[uint]bool, len(fileIDs))
+	for _, id := range fileIDs {
+		f, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", ErrAttachmentNotFound, id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			refs = append(refs, AttachmentRef{ID: f.ID, Filename: f.Filename})
+		}
+	}
+	return refs, nil
+}
+
+// EncodeAttachments 附件引用保存到 ChatHistory.Attachments 的格式，没有附件时为空字符串
+func EncodeAttachments(refs []AttachmentRef) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(refs)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func decodeAttachments(s string) []AttachmentRef {
+	var refs []AttachmentRef
+	if s != "" {
+		_ = json.Unmarshal([]byte(s), &refs)
+	}
+	return refs
+}
+
+// TurnAttachments 一轮问答可以使用的附件：当前分支中之前的用户消息附带的文件，加上本条消息附带的。
+// 分支只读取到 userMessage 的父消息，本条消息的附件直接取自 userMessage，文件按出现顺序去重
+func TurnAttachments(ctx context.Context, userID uint, themeID uint, userMessage ai_entity.ChatHistory) ([]AttachmentRef, error) {
+	var refs []AttachmentRef
+	var withFiles []ai_entity.ChatHistory
+	if err := dbs.DB.WithContext(ctx).Select("id, attachments").
+		Where("user_id = ? AND theme_id = ? AND role = ? AND attachments <> ''", userID, themeID, "user").
+		Order("id ASC").
+		Find(&withFiles).Error; err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	if len(withFiles) > 0 && userMessage.ParentID != 0 {
+		tree, err := loadMessageTree(dbs.DB.WithContext(ctx), userID, themeID)
+		if err != nil {
+			return nil, err
+		}
+		onBranch := make(map[uint]bool)
+		for _, id := range tree.path(userMessage.ParentID) {
+			onBranch[id] = true
+		}
+		for _, h := range withFiles {
+			if onBranch[h.ID] {
+				refs = append(refs, decodeAttachments(h.Attachments)...)
+			}
+		}
+	}
+	refs = append(refs, decodeAttachments(userMessage.Attachments)...)
+
+	seen := make(map[uint]bool, len(refs))
+	unique := refs[:0]
+	for _, r := range refs {
+		if !seen[r.ID] {
+			seen[r.ID] = true
+			unique = append(unique, r)
+		}
+	}
+	return unique, nil
+}
+
+// AttachmentContext 生成附在问题之后的附件内容。总长度在预算内时发送全文，
+// 否则按与问题的相关度选取分块。之前附带、现在已被删除或不再公开的文件会被跳过
+func AttachmentContext(ctx context.Context, userID uint, refs []AttachmentRef, question string, model string) (string, error) {
+	if len(refs) == 0 {
+		return "", nil
+	}
+	ids := make([]uint, 0, len(refs))
+	for _, r := range refs {
+		ids = append(ids, r.ID)
+	}
+	var files []file_entity.File
+	if err := readableFiles(dbs.DB.WithContext(ctx), userID).Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return "", fmt.Errorf("failed to get files: %w", err)
+	}
+	byID := make(map[uint]file_entity.File, len(files))
+	for _, f := range files {
+		byID[f.ID] = f
+	}
+
+	type fileChunks struct {
+		file   file_entity.File
+		chunks []ai_entity.AttachmentChunk
+	}
+	var all []fileChunks
+	total := 0
+	for _, r := range refs {
+		f, ok := byID[r.ID]
+		if !ok {
+			continue
+		}
+		chunks, err := attachmentChunks(ctx, f)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", f.Filename, err)
+		}
+		for _, c := range chunks {
+			total += ai.EstimateTokens(c.Content)
+		}
+		all = append(all, fileChunks{file: f, chunks: chunks})
+	}
+	if len(all) == 0 {
+		return "", nil
+	}
+
+	budget := MaxAttachmentTokens
+	if half := ai.ContextBudget(model) / 2; half > 0 && half < budget {
+		budget = half
+	}
+	var sb strings.Builder
+	if total <= budget {
+		for _, fc := range all {
+			sb.WriteString(fmt.Sprintf("### %s\n", fc.file.Filename))
+			for _, c := range fc.chunks {
+				sb.WriteString(c.Content)
+			}
+			sb.WriteString("\n\n")
+		}
+		return strings.TrimSpace(sb.String()), nil
+	}
+
+	// 每个文件的开头（通常是标题和当事人）优先，其余按相关度选取，直到用完预算
+	type candidate struct {
+		file  int
+		seq   int
+		score int
+	}
+	terms := bigrams(question)
+	var candidates []candidate
+	for i, fc := range all {
+		for _, c := range fc.chunks {
+			content := strings.ToLower(c.Content)
+			score := 0
+			for term := range terms {
+				if strings.Contains(content, term) {
+					score++
+				}
+			}
+			if c.Seq == 0 {
+				score = len(terms) + 1
+			}
+			candidates = append(candidates, candidate{file: i, seq: c.Seq, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	selected := make(map[[2]int]bool)
+	used := 0
+	for _, c := range candidates {
+		tokens := ai.EstimateTokens(all[c.file].chunks[c.seq].Content)
+		if used+tokens > budget {
+			continue
+		}
+		used += tokens
+		selected[[2]int{c.file, c.seq}] = true
+	}
+
+	for i, fc := range all {
+		sb.WriteString(fmt.Sprintf("### %s（文件较长，以下为与问题相关的片段）\n", fc.file.Filename))
+		last := -1
+		for _, c := range fc.chunks {
+			if !selected[[2]int{i, c.Seq}] {
+				continue
+			}
+			if last >= 0 && c.Seq != last+1 {
+				sb.WriteString("\n……\n")
+			}
+			sb.WriteString(c.Content)
+			last = c.Seq
+		}
+		sb.WriteString("\n\n")
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// 读取文件的分块，第一次使用时提取文本并保存
+func attachmentChunks(ctx context.Context, file file_entity.File) ([]ai_entity.AttachmentChunk, error) {
+	var chunks []ai_entity.AttachmentChunk
+	if err := dbs.DB.WithContext(ctx).Where("file_id = ?", file.ID).Order("seq ASC").Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get attachment chunks: %w", err)
+	}
+	if len(chunks) > 0 {
+		return chunks, nil
+	}
+
+	text, err := filetext.Extract(file.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		return nil, fmt.Errorf("文件中没有文字内容")
+	}
+	offset := 0
+	for i, content := range splitText(text, AttachmentChunkSize) {
+		chunks = append(chunks, ai_entity.AttachmentChunk{FileID: file.ID, Seq: i, Offset: offset, Content: content})
+		offset += utf8.RuneCountInString(content)
+	}
+	// 同一文件可能被同时提取，已存在的分块保持不变
+	if err := dbs.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&chunks, 100).Error; err != nil {
+		return nil, fmt.Errorf("failed to save attachment chunks: %w", err)
+	}
+	return chunks, nil
+}
+
+// 按字数分块，尽量在段落或句子结尾处断开，拼接后与原文相同
+func splitText(text string, size int) []string {
+	runes := []rune(text)
+	var parts []string
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			parts = append(parts, string(runes[start:]))
+			break
+		}
+		for i := end; i > end-size/4; i-- {
+			if r := runes[i-1]; r == '\n' || r == '。' || r == '；' {
+				end = i
+				break
+			}
+		}
+		parts = append(parts, string(runes[start:end]))
+		start = end
+	}
+	return parts
+}
+
+// 问题中相邻两个字组成的词，用于估计分块与问题的相关度（中文没有空格分词）
+func bigrams(text string) map[string]bool {
+	var runes []rune
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, unicode.ToLower(r))
+		} else {
+			runes = append(runes, ' ')
+		}
+	}
+	terms := make(map[string]bool)
+	for i := 0; i+1 < len(runes); i++ {
+		if runes[i] != ' ' && runes[i+1] != ' ' {
+			terms[string(runes[i:i+2])] = true
+		}
+	}
+	return terms
+}
@@ -0,0 +1,216 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+)
+
+var ErrMessageNotFound = errors.New("消息不存在或不属于该主题")
+
+// 主题中的消息树，只包含构建分支所需的字段
+type messageTree struct {
+	nodes    map[uint]ai_entity.ChatHistory
+	children map[uint][]uint // 父消息ID -> 子消息ID（按ID升序），0 为第一条消息的父节点
+}
+
+func loadMessageTree(db *gorm.DB, userID uint, themeID uint) (*messageTree, error) {
+	var nodes []ai_entity.ChatHistory
+	if err := db.Select("id, parent_id, role").
+		Where("user_id = ? AND theme_id = ?", userID, themeID).
+		Order("id ASC").
+		Find(&nodes).Error; err != nil {
+		return nil, fmt.Errorf("failed to load message tree: %w", err)
+	}
+	tree := &messageTree{nodes: make(map[uint]ai_entity.ChatHistory, len(nodes)), children: make(map[uint][]uint)}
+	for _, n := range nodes {
+		tree.nodes[n.ID] = n
+		tree.children[n.ParentID] = append(tree.children[n.ParentID], n.ID)
+	}
+	return tree, nil
+}
+
+// 从 leafID 回溯到第一条消息，返回按时间正序排列的消息ID
+func (t *messageTree) path(leafID uint) []uint {
+	var ids []uint
+	for id := leafID; id != 0; id = t.nodes[id].ParentID {
+		if _, ok := t.nodes[id]; !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+	return ids
+}
+
+// 从 id 开始沿最新的子消息走到分支末端
+func (t *messageTree) latestLeaf(id uint) uint {
+	for {
+		children := t.children[id]
+		if len(children) == 0 {
+			return id
+		}
+		id = children[len(children)-1]
+	}
+}
+
+// 找到主题当前分支的末端。
+// 分支功能之前的对话没有父消息ID，第一次访问时按ID顺序补齐并记录末端
+func resolveHead(ctx context.Context, chatTheme *ai_entity.ChatTheme, tree *messageTree) (uint, error) {
+	if chatTheme.HeadID != 0 {
+		if _, ok := tree.nodes[chatTheme.HeadID]; ok {
+			return chatTheme.HeadID, nil
+		}
+	}
+	if len(tree.nodes) == 0 {
+		return 0, nil
+	}
+	if len(tree.children[0]) != len(tree.nodes) {
+		// 已经有父子关系，取最新一条消息所在的分支
+		var latest uint
+		for id := range tree.nodes {
+			if id > latest {
+				latest = id
+			}
+		}
+		return latest, nil
+	}
+
+	ids := tree.children[0]
+	err := dbs.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := 1; i < len(ids); i++ {
+			if err := tx.Model(&ai_entity.ChatHistory{}).Where("id = ?", ids[i]).Update("parent_id", ids[i-1]).Error; err != nil {
+				return err
+			}
+		}
+		return SetBranchHead(tx, chatTheme.ID, ids[len(ids)-1])
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to link chat history: %w", err)
+	}
+	for i := 1; i < len(ids); i++ {
+		n := tree.nodes[ids[i]]
+		n.ParentID = ids[i-1]
+		tree.nodes[ids[i]] = n
+		tree.children[ids[i-1]] = []uint{ids[i]}
+	}
+	tree.children[0] = ids[:1]
+	return ids[len(ids)-1], nil
+}
+
+// SetBranchHead 设置主题当前分支的末端
+func SetBranchHead(tx *gorm.DB, themeID uint, headID uint) error {
+	return tx.Model(&ai_entity.ChatTheme{}).
+		Where("id = ?", themeID).
+		Update("head_id", headID).Error
+}
+
+// SaveBranchMessages 依次保存消息，每条消息的父消息为前一条，返回最后一条消息的ID（没有消息时返回 parentID）
+func SaveBranchMessages(tx *gorm.DB, parentID uint, messages []ai_entity.ChatHistory) (uint, error) {
+	for i := range messages {
+		messages[i].ParentID = parentID
+		if err := tx.Create(&messages[i]).Error; err != nil {
+			return 0, err
+		}
+		parentID = messages[i].ID
+	}
+	return parentID, nil
+}
+
+// SaveQuestion 保存用户消息。在生成回答之前单独写入，生成期间不占用事务
+func SaveQuestion(ctx context.Context, question *ai_entity.ChatHistory) error {
+	if err := dbs.DB.WithContext(ctx).Create(question).Error; err != nil {
+		return fmt.Errorf("failed to save question: %w", err)
+	}
+	return nil
+}
+
+// DiscardQuestion 生成失败时删除刚保存的用户消息
+func DiscardQuestion(ctx context.Context, id uint) error {
+	return dbs.DB.WithContext(ctx).Delete(&ai_entity.ChatHistory{}, id).Error
+}
+
+// SaveAnswer 在一个事务中保存工具调用记录和回答，并把回答设为主题当前分支的末端
+func SaveAnswer(ctx context.Context, themeID uint, questionID uint, tools []ai_entity.ChatHistory, answer *ai_entity.ChatHistory) error {
+	err := dbs.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		parentID, err := SaveBranchMessages(tx, questionID, tools)
+		if err != nil {
+			return err
+		}
+		answer.ParentID = parentID
+		if err := tx.Create(answer).Error; err != nil {
+			return err
+		}
+		return SetBranchHead(tx, themeID, answer.ID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save answer: %w", err)
+	}
+	return nil
+}
+
+// GetMessage 读取主题中的一条消息
+func GetMessage(ctx context.Context, userID uint, themeID uint, id uint) (*ai_entity.ChatHistory, error) {
+	var message ai_entity.ChatHistory
+	err := dbs.DB.WithContext(ctx).Where("id = ? AND user_id = ? AND theme_id = ?", id, userID, themeID).First(&message).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// QuestionOf 找到回复对应的用户提问：回溯跳过工具调用和工具结果，message 本身是用户消息时直接返回
+func QuestionOf(ctx context.Context, message *ai_entity.ChatHistory) (*ai_entity.ChatHistory, error) {
+	for message.Role != "user" {
+		if message.ParentID == 0 {
+			return nil, ErrMessageNotFound
+		}
+		parent, err := GetMessage(ctx, message.UserID, message.ThemeID, message.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		message = parent
+	}
+	return message, nil
+}
+
+// SwitchBranch 切换到包含 messageID 的分支，沿最新的回复走到分支末端，返回新的末端ID
+func SwitchBranch(ctx context.Context, userID uint, themeID uint, messageID uint) (uint, error) {
+	tree, err := loadMessageTree(dbs.DB.WithContext(ctx), userID, themeID)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := tree.nodes[messageID]; !ok {
+		return 0, ErrMessageNotFound
+	}
+	head := tree.latestLeaf(messageID)
+	if err := SetBranchHead(dbs.DB.WithContext(ctx), themeID, head); err != nil {
+		return 0, err
+	}
+	if err := DeleteChatCache(userID, themeID); err != nil {
+		return 0, err
+	}
+	return head, nil
+}
+
+// 按ID读取消息，按ID升序（即分支上的时间顺序）返回
+func loadMessages(ctx context.Context, ids []uint) ([]ai_entity.ChatHistory, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var histories []ai_entity.ChatHistory
+	if err := dbs.DB.WithContext(ctx).Where("id IN ?", ids).Find(&histories).Error; err != nil {
+		return nil, fmt.Errorf("failed to get chat history: %w", err)
+	}
+	sort.Slice(histories, func(i, j int) bool { return histories[i].ID < histories[j].ID })
+	return histories, nil
+}
@@ -0,0 +1,15 @@
+package ai_service
+
+import (
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/pkg/utils/civilcode"
+)
+
+// AttachCitations 为历史记录中的最终回答核对法条引用，结果只用于返回给前端，不保存
+func AttachCitations(histories []ai_entity.ChatHistory) {
+	for i := range histories {
+		if histories[i].Role == "assistant" && histories[i].ToolCalls == "" {
+			histories[i].Citations = civilcode.VerifyCitations(histories[i].Content)
+		}
+	}
+}
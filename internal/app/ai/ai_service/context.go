@@ -0,0 +1,195 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/internal/app/prompt/prompt_service"
+	"Programming-Demo/pkg/utils/ai"
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+const ContextHistoryLimit = 200 // 构建上下文时最多读取的未摘要消息数量
+
+// ChatContext 主题某个分支的对话上下文：早期对话的滚动摘要，以及摘要之后按时间正序排列的原文消息
+type ChatContext struct {
+	Summary         string
+	SummarizedUntil uint // 已并入摘要的最后一条消息ID
+	HeadID          uint // 分支的最后一条消息ID，即下一条消息的父消息
+	Histories       []ai_entity.ChatHistory
+}
+
+// LoadChatContext 加载主题当前分支的对话上下文，优先使用缓存
+func LoadChatContext(ctx context.Context, userID uint, themeID uint, maxAge time.Duration) (*ChatContext, error) {
+	cache, err := loadCache(getContextCacheKey(userID, themeID))
+	if err == nil && IsCacheValid(cache, maxAge) {
+		return &ChatContext{
+			Summary:         cache.Summary,
+			SummarizedUntil: cache.SummarizedUntil,
+			HeadID:          cache.HeadID,
+			Histories:       cache.Messages,
+		}, nil
+	}
+	return loadBranchContext(ctx, userID, themeID, nil)
+}
+
+// LoadBranchContext 加载以 leafID 结尾的分支的对话上下文，用于重新生成回复和编辑消息，leafID 为 0 表示从头开始
+func LoadBranchContext(ctx context.Context, userID uint, themeID uint, leafID uint) (*ChatContext, error) {
+	return loadBranchContext(ctx, userID, themeID, &leafID)
+}
+
+// leafID 为 nil 时使用主题当前的分支
+func loadBranchContext(ctx context.Context, userID uint, themeID uint, leafID *uint) (*ChatContext, error) {
+	chatTheme, err := GetTheme(ctx, userID, themeID)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := loadMessageTree(dbs.DB.WithContext(ctx), userID, themeID)
+	if err != nil {
+		return nil, err
+	}
+	head, err := resolveHead(ctx, chatTheme, tree)
+	if err != nil {
+		return nil, err
+	}
+	if leafID != nil {
+		head = *leafID
+	}
+	cc := &ChatContext{HeadID: head}
+
+	// 摘要只对包含其截止消息的分支有效，使用分支上最新的一份，没有时从原文重新摘要
+	path := tree.path(head)
+	if summary, err := branchSummary(ctx, themeID, path); err != nil {
+		return nil, err
+	} else if summary != nil {
+		cc.Summary, cc.SummarizedUntil = summary.Summary, summary.UntilID
+	}
+
+	var ids []uint
+	for _, id := range path {
+		if id > cc.SummarizedUntil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > ContextHistoryLimit {
+		ids = ids[len(ids)-ContextHistoryLimit:]
+	}
+	if cc.Histories, err = loadMessages(ctx, ids); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// SaveChatContext 缓存对话上下文，同时使历史记录缓存失效
+func SaveChatContext(userID uint, themeID uint, cc *ChatContext) error {
+	if err := InitCache(); err != nil {
+		return err
+	}
+	cacheInstance.Del(getCacheKey(userID, themeID))
+
+	return saveCache(getContextCacheKey(userID, themeID), ai_entity.LocalChatCache{
+		UserID:          userID,
+		ThemeID:         themeID,
+		LastUpdated:     time.Now(),
+		Messages:        cc.Histories,
+		Summary:         cc.Summary,
+		SummarizedUntil: cc.SummarizedUntil,
+		HeadID:          cc.HeadID,
+		Metadata: map[string]interface{}{
+			"count": len(cc.Histories),
+		},
+	})
+}
+
+// BuildMessages 按模型的 token 预算构建对话消息。
+// 历史超出预算时，将较早的消息并入主题摘要并保存，只保留最近的若干轮原文；
+// 摘要失败时只在本次请求中省略早期消息，cc 中仍保留原文，下一轮会重新尝试
+func (cc *ChatContext) BuildMessages(ctx context.Context, themeID uint, model string, system string, question string) ([]ai.Message, error) {
+	budget := ai.ContextBudget(model) - ai.EstimateTokens(system) - ai.EstimateTokens(question) - 32
+	if budget < 0 {
+		return nil, fmt.Errorf("输入内容过长，超出模型 %s 的上下文限制", model)
+	}
+
+	histories := cc.Histories
+	older, recent := ai.SplitHistory(histories, budget-ai.EstimateTokens(cc.Summary))
+	if len(older) > 0 {
+		// 多压缩一半预算，避免之后每一轮都要重新生成摘要
+		older, recent = ai.SplitHistory(histories, (budget-ai.EstimateTokens(cc.Summary))/2)
+		prompt, err := prompt_service.Render(ctx, prompt_service.ChatSummary, prompt_service.ChatSummaryData{
+			Previous:   cc.Summary,
+			Transcript: ai.SummaryTranscript(model, cc.Summary, older),
+		})
+		var summary string
+		if err == nil {
+			summary, err = ai.Summarize(ctx, model, prompt.Text)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			log.Printf("生成对话摘要失败: %v", err)
+		} else {
+			until := older[len(older)-1].ID
+			if err := saveSummary(ctx, themeID, until, summary, prompt.Label()); err != nil {
+				log.Printf("保存对话摘要失败: %v", err)
+			}
+			cc.Summary, cc.SummarizedUntil = summary, until
+			cc.Histories = recent
+		}
+
+		// 摘要变长或摘要失败时，本次请求只发送放得下的最近几轮
+		_, histories = ai.SplitHistory(recent, budget-ai.EstimateTokens(cc.Summary))
+	}
+
+	return ai.BuildChatMessages(ai.WithSummary(system, cc.Summary), histories, question), nil
+}
+
+// 找到分支上最新的摘要，path 为按时间正序排列的分支消息ID
+func branchSummary(ctx context.Context, themeID uint, path []uint) (*ai_entity.ChatSummary, error) {
+	var summaries []ai_entity.ChatSummary
+	if err := dbs.DB.WithContext(ctx).Select("id, until_id").
+		Where("theme_id = ?", themeID).
+		Find(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("failed to load chat summaries: %w", err)
+	}
+	if len(summaries) == 0 {
+		return nil, nil
+	}
+	found := make(map[uint]uint, len(summaries)) // 截止消息ID -> 摘要ID
+	for _, s := range summaries {
+		found[s.UntilID] = s.ID
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		id, ok := found[path[i]]
+		if !ok {
+			continue
+		}
+		var summary ai_entity.ChatSummary
+		if err := dbs.DB.WithContext(ctx).First(&summary, id).Error; err != nil {
+			return nil, fmt.Errorf("failed to load chat summary: %w", err)
+		}
+		return &summary, nil
+	}
+	return nil, nil
+}
+
+// 保存截止到 until 的滚动摘要，同一条消息重复摘要时覆盖
+func saveSummary(ctx context.Context, themeID uint, until uint, summary string, prompt string) error {
+	return dbs.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "until_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"summary", "prompt", "updated_at"}),
+	}).Create(&ai_entity.ChatSummary{ThemeID: themeID, UntilID: until, Summary: summary, Prompt: prompt}).Error
+}
+
+// DeleteThemeSummaries 删除主题的全部摘要，在删除主题的事务中调用
+func DeleteThemeSummaries(tx *gorm.DB, themeID uint) error {
+	if err := tx.Where("theme_id = ?", themeID).Delete(&ai_entity.ChatSummary{}).Error; err != nil {
+		return fmt.Errorf("failed to delete chat summaries: %w", err)
+	}
+	return nil
+}
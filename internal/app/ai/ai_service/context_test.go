@@ -0,0 +1,61 @@
+package ai_service
+
+import (
+	"Programming-Demo/config"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/pkg/utils/ai"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingProvider struct{}
+
+func (failingProvider) Chat(ctx context.Context, req *ai.ChatRequest) (*ai.ChatResponse, error) {
+	return nil, &ai.ProviderError{Code: 400, Message: "summary unavailable"}
+}
+
+func (p failingProvider) ChatStream(ctx context.Context, req *ai.ChatRequest, onDelta ai.StreamHandler) (*ai.ChatResponse, error) {
+	return p.Chat(ctx, req)
+}
+
+func TestSummaryFailureKeepsHistory(t *testing.T) {
+	ai.RegisterProvider("failing", func(cfg config.ModelConfig) (ai.Provider, error) { return failingProvider{}, nil })
+	config.SetConfig(&config.GlobalConfig{Models: []config.ModelConfig{
+		{Name: "small", Provider: "failing", ContextWindow: 600, MaxTokens: 100},
+	}})
+
+	const uid, themeID = 7, 70
+	cc := &ChatContext{HeadID: 10}
+	for i := uint(1); i <= 10; i++ {
+		role := "user"
+		if i%2 == 0 {
+			role = "assistant"
+		}
+		cc.Histories = append(cc.Histories, ai_entity.ChatHistory{ID: i, ThemeID: themeID, Role: role, Content: strings.Repeat("押金", 40)})
+	}
+
+	messages, err := cc.BuildMessages(context.Background(), themeID, "small", "你是法律助手", "房东不退押金怎么办")
+	if err != nil {
+		t.Fatalf("BuildMessages: %v", err)
+	}
+	// 本次请求只发送放得下的最近几轮
+	if len(messages) >= len(cc.Histories)+2 {
+		t.Fatalf("sent %d messages, want older turns trimmed", len(messages))
+	}
+	if cc.SummarizedUntil != 0 || len(cc.Histories) != 10 {
+		t.Fatalf("context changed after failed summary: until=%d, histories=%d", cc.SummarizedUntil, len(cc.Histories))
+	}
+
+	if err := SaveChatContext(uid, themeID, cc); err != nil {
+		t.Fatalf("SaveChatContext: %v", err)
+	}
+	loaded, err := LoadChatContext(context.Background(), uid, themeID, time.Hour)
+	if err != nil {
+		t.Fatalf("LoadChatContext: %v", err)
+	}
+	if len(loaded.Histories) != 10 || loaded.Histories[0].ID != 1 {
+		t.Fatalf("loaded %d histories, want all 10 older turns kept", len(loaded.Histories))
+	}
+}
@@ -0,0 +1,71 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/pkg/utils/bocha"
+	"Programming-Demo/pkg/utils/export"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ExportDisclaimer 附在导出记录末尾的免责声明
+const ExportDisclaimer = "本记录由智能法务助手自动生成，仅供一般性法律参考，不构成正式法律意见。具体问题请咨询执业律师。"
+
+// ExportTheme 导出主题当前分支的完整对话：用户提问和最终回答，工具调用过程只保留其中的联网搜索来源
+func ExportTheme(ctx context.Context, userID uint, themeID uint) (*export.Document, error) {
+	chatTheme, err := GetTheme(ctx, userID, themeID)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := loadMessageTree(dbs.DB.WithContext(ctx), userID, themeID)
+	if err != nil {
+		return nil, err
+	}
+	head, err := resolveHead(ctx, chatTheme, tree)
+	if err != nil {
+		return nil, err
+	}
+	histories, err := loadMessages(ctx, tree.path(head))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &export.Document{
+		Title: chatTheme.Theme,
+		Meta: []string{
+			fmt.Sprintf("创建时间：%s", chatTheme.CreatedAt.Format("2006-01-02 15:04:05")),
+			fmt.Sprintf("导出时间：%s", time.Now().Format("2006-01-02 15:04:05")),
+		},
+		Disclaimer: ExportDisclaimer,
+	}
+	// 之前的回答没有记录来源，从工具调用结果中补充
+	var toolSources []bocha.Source
+	for _, h := range histories {
+		switch {
+		case h.Role == "tool":
+			toolSources = append(toolSources, bocha.ParseSources(h.Content)...)
+		case h.Role == "user":
+			toolSources = nil
+			doc.Entries = append(doc.Entries, export.Entry{Speaker: "用户", Time: h.CreatedAt, Content: h.Content})
+		case h.Role == "assistant" && h.ToolCalls == "":
+			sources := toolSources
+			if h.Sources != "" {
+				if err := json.Unmarshal([]byte(h.Sources), &sources); err != nil {
+					sources = toolSources
+				}
+			}
+			entry := export.Entry{Speaker: "法务助手", Time: h.CreatedAt, Model: h.Model, Content: h.Content}
+			if h.Partial {
+				entry.Content += "\n\n（回答未完整生成）"
+			}
+			for _, s := range sources {
+				entry.Sources = append(entry.Sources, export.Source{Title: s.Title, URL: s.URL})
+			}
+			doc.Entries = append(doc.Entries, entry)
+			toolSources = nil
+		}
+	}
+	return doc, nil
+}
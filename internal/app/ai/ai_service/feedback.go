@@ -0,0 +1,226 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+	"time"
+)
+
+// 生成回答的接口，记录在 ChatHistory.Endpoint 中
+const (
+	EndpointChat      = "chat"
+	EndpointSearch    = "search"
+	EndpointContract  = "contract"
+	EndpointComplaint = "complaint"
+	EndpointOpinion   = "opinion"
+	EndpointFiles     = "files" // 基于用户本人文件的问答
+)
+
+var ErrFeedbackTarget = errors.New("只能评价自己收到的回答")
+
+// 统计维度对应的分组表达式（MySQL）
+var feedbackGroups = map[string]string{
+	"model":    "model",
+	"prompt":   "SUBSTRING_INDEX(prompt, '@', 1)", // 提示词类型，不区分版本
+	"version":  "prompt",                          // 提示词类型和版本
+	"endpoint": "endpoint",
+	"day":      "DATE_FORMAT(created_at, '%Y-%m-%d')",
+	"month":    "DATE_FORMAT(created_at, '%Y-%m')",
+}
+
+// FeedbackFilter 统计和导出的筛选条件，零值表示不限
+type FeedbackFilter struct {
+	From     time.Time
+	To       time.Time
+	Model    string
+	Endpoint string
+}
+
+// FeedbackStat 一个分组的评价统计
+type FeedbackStat struct {
+	Key    string  `json:"key"`
+	Up     int64   `json:"up"`
+	Down   int64   `json:"down"`
+	Total  int64   `json:"total"`
+	UpRate float64 `json:"up_rate"`
+}
+
+// LowRatedAnswer 导出的差评回答，用于调整提示词
+type LowRatedAnswer struct {
+	MessageID  uint      `json:"message_id"`
+	Model      string    `json:"model"`
+	Prompt     string    `json:"prompt"`
+	Endpoint   string    `json:"endpoint"`
+	Question   string    `json:"question"` // 文书生成类接口为渲染后的提示词
+	Answer     string    `json:"answer"`
+	Comment    string    `json:"comment,omitempty"`
+	Correction string    `json:"correction,omitempty"`
+	RatedAt    time.Time `json:"rated_at"`
+}
+
+// SaveGeneration 保存文书生成类接口的输入和回答（不属于任何主题），返回回答的消息ID，用于评价。
+// partial 表示客户端中途断开，只保存了部分回答
+func SaveGeneration(ctx context.Context, userID uint, endpoint string, model string, prompt string, label string, answer string, reasoning string, partial bool) (uint, error) {
+	var id uint
+	err := dbs.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		question := ai_entity.ChatHistory{UserID: userID, Model: model, Role: "user", Content: prompt, Endpoint: endpoint}
+		if err := tx.Create(&question).Error; err != nil {
+			return err
+		}
+		reply := ai_entity.ChatHistory{
+			UserID:    userID,
+			ParentID:  question.ID,
+			Model:     model,
+			Role:      "assistant",
+			Content:   answer,
+			Reasoning: reasoning,
+			Partial:   partial,
+			Prompt:    label,
+			Endpoint:  endpoint,
+		}
+		if err := tx.Create(&reply).Error; err != nil {
+			return err
+		}
+		id = reply.ID
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to save generation: %w", err)
+	}
+	return id, nil
+}
+
+// SubmitFeedback 评价一条回答，重复评价时覆盖之前的评价
+func SubmitFeedback(ctx context.Context, userID uint, messageID uint, rating int, comment string, correction string) (*ai_entity.AnswerFeedback, error) {
+	var message ai_entity.ChatHistory
+	err := dbs.DB.WithContext(ctx).Where("id = ? AND user_id = ?", messageID, userID).First(&message).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFeedbackTarget
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 只有最终回答可以评价，工具调用过程不能
+	if message.Role != "assistant" || message.ToolCalls != "" {
+		return nil, ErrFeedbackTarget
+	}
+
+	feedback := ai_entity.AnswerFeedback{
+		UserID:     userID,
+		MessageID:  messageID,
+		Rating:     rating,
+		Comment:    comment,
+		Correction: correction,
+		Model:      message.Model,
+		Prompt:     message.Prompt,
+		Endpoint:   message.Endpoint,
+	}
+	if err := dbs.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "message_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "comment", "correction", "updated_at"}),
+	}).Create(&feedback).Error; err != nil {
+		return nil, fmt.Errorf("failed to save feedback: %w", err)
+	}
+	return &feedback, nil
+}
+
+// DeleteThemeFeedback 在事务中删除主题下消息的评价，需在删除聊天历史之前调用
+func DeleteThemeFeedback(tx *gorm.DB, userID uint, themeID uint) error {
+	messages := tx.Model(&ai_entity.ChatHistory{}).Select("id").Where("user_id = ? AND theme_id = ?", userID, themeID)
+	if err := tx.Where("message_id IN (?)", messages).Delete(&ai_entity.AnswerFeedback{}).Error; err != nil {
+		return fmt.Errorf("failed to delete answer feedback: %w", err)
+	}
+	return nil
+}
+
+// FeedbackStats 按 groupBy（model、prompt、version、endpoint、day、month）统计评价
+func FeedbackStats(ctx context.Context, groupBy string, filter FeedbackFilter) ([]FeedbackStat, error) {
+	expr, ok := feedbackGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("不支持的统计维度: %s", groupBy)
+	}
+	stats := make([]FeedbackStat, 0)
+	if err := filter.apply(dbs.DB.WithContext(ctx).Model(&ai_entity.AnswerFeedback{})).
+		Select(fmt.Sprintf("%s AS `key`, SUM(rating > 0) AS up, SUM(rating < 0) AS down, COUNT(*) AS total", expr)).
+		Group("`key`").
+		Order("total DESC").
+		Scan(&stats).Error; err != nil {
+		return nil, fmt.Errorf("failed to get feedback stats: %w", err)
+	}
+	for i := range stats {
+		if stats[i].Total > 0 {
+			stats[i].UpRate = float64(stats[i].Up) / float64(stats[i].Total)
+		}
+	}
+	return stats, nil
+}
+
+// ExportLowRated 将差评的回答逐行写为 JSON（JSONL），附上对应的问题，返回导出的条数
+func ExportLowRated(ctx context.Context, filter FeedbackFilter, w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+	count := 0
+	var feedbacks []ai_entity.AnswerFeedback
+	err := filter.apply(dbs.DB.WithContext(ctx).Where("rating < 0")).
+		FindInBatches(&feedbacks, 100, func(tx *gorm.DB, batch int) error {
+			for _, f := range feedbacks {
+				item, err := lowRatedAnswer(ctx, f)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					// 消息已被删除，跳过
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if err := encoder.Encode(item); err != nil {
+					return err
+				}
+				count++
+			}
+			return nil
+		}).Error
+	return count, err
+}
+
+func lowRatedAnswer(ctx context.Context, f ai_entity.AnswerFeedback) (*LowRatedAnswer, error) {
+	var answer ai_entity.ChatHistory
+	if err := dbs.DB.WithContext(ctx).First(&answer, f.MessageID).Error; err != nil {
+		return nil, err
+	}
+	item := &LowRatedAnswer{
+		MessageID:  f.MessageID,
+		Model:      f.Model,
+		Prompt:     f.Prompt,
+		Endpoint:   f.Endpoint,
+		Answer:     answer.Content,
+		Comment:    f.Comment,
+		Correction: f.Correction,
+		RatedAt:    f.UpdatedAt,
+	}
+	if question, err := QuestionOf(ctx, &answer); err == nil {
+		item.Question = question.Content
+	}
+	return item, nil
+}
+
+func (f FeedbackFilter) apply(db *gorm.DB) *gorm.DB {
+	if !f.From.IsZero() {
+		db = db.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		db = db.Where("created_at < ?", f.To)
+	}
+	if f.Model != "" {
+		db = db.Where("model = ?", f.Model)
+	}
+	if f.Endpoint != "" {
+		db = db.Where("endpoint = ?", f.Endpoint)
+	}
+	return db
+}
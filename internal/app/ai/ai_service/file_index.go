@@ -0,0 +1,352 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/core/milvus"
+	"Programming-Demo/internal/app/File/file_entity"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/pkg/utils/ai"
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 文件向量索引的状态
+const (
+	FileIndexPending = "pending"
+	FileIndexIndexed = "indexed"
+	FileIndexFailed  = "failed"
+)
+
+const (
+	DefaultFileHits = 8  // 默认检索的分块数
+	MaxFileHits     = 20 // 最多检索的分块数
+)
+
+var (
+	ErrMyFileNotFound  = errors.New("文件不存在或不属于当前用户")
+	ErrNoIndexedFiles  = errors.New("还没有建立索引的文件，文件审核通过后会自动建立索引")
+	ErrFileNotIndexing = errors.New("只有审核通过的文件可以建立索引")
+)
+
+// 每个文件最近一次建立或删除索引的操作序号。后台任务生成向量和访问 Milvus 时不持有锁，
+// 写入向量前后核对序号：被更新的操作取代时放弃写入，已经写入的向量按主键撤销
+var (
+	indexOpMux  sync.Mutex
+	indexOpLast uint64
+	indexOps    = make(map[uint]uint64)
+)
+
+func beginIndexOp(fileID uint) uint64 {
+	indexOpMux.Lock()
+	defer indexOpMux.Unlock()
+	indexOpLast++
+	indexOps[fileID] = indexOpLast
+	return indexOpLast
+}
+
+func isLatestIndexOp(fileID uint, seq uint64) bool {
+	indexOpMux.Lock()
+	defer indexOpMux.Unlock()
+	return indexOps[fileID] == seq
+}
+
+func endIndexOp(fileID uint, seq uint64) {
+	indexOpMux.Lock()
+	defer indexOpMux.Unlock()
+	if indexOps[fileID] == seq {
+		delete(indexOps, fileID)
+	}
+}
+
+// FileReference 回答引用的文件分块
+type FileReference struct {
+	Index    int     `json:"index"` // 在提示词中的编号，回答中以 [编号] 引用
+	FileID   uint    `json:"file_id"`
+	Filename string  `json:"filename"`
+	ChunkID  uint    `json:"chunk_id"`
+	Seq      int     `json:"seq"`
+	Offset   int     `json:"offset"` // 分块在文件全文中的起始位置（字符数）
+	Length   int     `json:"length"` // 分块的字符数
+	Score    float32 `json:"score"`  // 与问题的向量距离，越小越相关
+	Content  string  `json:"content"`
+}
+
+// 所有者本人的、未删除且审核通过的文件，只有这些文件可以建立索引和被检索
+func ownIndexableFiles(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Where("user_id = ? AND status = ? AND audit_status = ?", userID, 1, "approved")
+}
+
+// IndexFileAsync 在后台为文件建立索引，失败原因记录在 FileIndex 中
+func IndexFileAsync(fileID uint) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Panic while indexing file %d: %v", fileID, r)
+			}
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		if err := IndexFile(ctx, fileID); err != nil {
+			log.Printf("Failed to index file %d: %v", fileID, err)
+		}
+	}()
+}
+
+// IndexFile 提取文件文本、分块并生成向量写入 Milvus，已有的索引会被替换。
+// 同一文件之后又开始了建立或删除索引时，本次结果被丢弃
+func IndexFile(ctx context.Context, fileID uint) error {
+	seq := beginIndexOp(fileID)
+	defer endIndexOp(fileID, seq)
+
+	var file file_entity.File
+	if err := dbs.DB.WithContext(ctx).First(&file, fileID).Error; err != nil {
+		return fmt.Errorf("failed to get file: %w", err)
+	}
+	if file.Status != 1 || file.AuditStatus != "approved" {
+		return ErrFileNotIndexing
+	}
+	index := ai_entity.FileIndex{FileID: file.ID, UserID: file.UserID, Status: FileIndexPending}
+	if err := dbs.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "status", "updated_at"}),
+	}).Create(&index).Error; err != nil {
+		return fmt.Errorf("failed to save file index: %w", err)
+	}
+
+	chunks, err := indexFileChunks(ctx, file, seq)
+	if err != nil {
+		// ctx 可能已经超时，失败状态仍然要记录
+		if isLatestIndexOp(file.ID, seq) {
+			dbs.DB.Model(&ai_entity.FileIndex{}).Where("file_id = ?", file.ID).
+				Updates(map[string]interface{}{"status": FileIndexFailed, "error": err.Error()})
+		}
+		return err
+	}
+	if !isLatestIndexOp(file.ID, seq) {
+		return nil
+	}
+	return dbs.DB.WithContext(ctx).Model(&ai_entity.FileIndex{}).Where("file_id = ?", file.ID).
+		Updates(map[string]interface{}{"status": FileIndexIndexed, "chunks": chunks, "error": ""}).Error
+}
+
+func indexFileChunks(ctx context.Context, file file_entity.File, seq uint64) (int, error) {
+	if _, err := attachmentChunks(ctx, file); err != nil {
+		return 0, err
+	}
+	// 分块可能由其他请求同时写入，重新读取以获得分块ID
+	var chunks []ai_entity.AttachmentChunk
+	if err := dbs.DB.WithContext(ctx).Where("file_id = ?", file.ID).Order("seq ASC").Find(&chunks).Error; err != nil {
+		return 0, fmt.Errorf("failed to get attachment chunks: %w", err)
+	}
+
+	texts := make([]string, len(chunks))
+	chunkIDs := make([]int64, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Content
+		chunkIDs[i] = int64(c.ID)
+	}
+	embeddings, err := ai.GenerateEmbeddings(ctx, texts)
+	if err != nil {
+		return 0, fmt.Errorf("生成向量失败: %v", err)
+	}
+	vectors := make([][]float32, len(embeddings))
+	for i, embedding := range embeddings {
+		vectors[i] = make([]float32, len(embedding))
+		for j, v := range embedding {
+			vectors[i][j] = float32(v)
+		}
+	}
+
+	if !isLatestIndexOp(file.ID, seq) {
+		return 0, nil
+	}
+	if err := milvus.DeleteFileChunks(ctx, file.ID); err != nil {
+		return 0, err
+	}
+	var written []int64
+	for start := 0; start < len(chunkIDs); start += 100 {
+		end := start + 100
+		if end > len(chunkIDs) {
+			end = len(chunkIDs)
+		}
+		ids, err := milvus.InsertFileChunks(ctx, file.UserID, file.ID, chunkIDs[start:end], vectors[start:end])
+		if err != nil {
+			undoFileVectors(file.ID, written)
+			return 0, err
+		}
+		written = append(written, ids...)
+	}
+	// 写入期间文件被删除、驳回或重新建立索引，撤销本次写入的向量
+	if !isLatestIndexOp(file.ID, seq) {
+		undoFileVectors(file.ID, written)
+	}
+	return len(chunkIDs), nil
+}
+
+// 撤销写入的向量。ctx 可能已经超时，使用新的 context
+func undoFileVectors(fileID uint, ids []int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := milvus.DeleteFileVectors(ctx, ids); err != nil {
+		log.Printf("Failed to undo index of file %d: %v", fileID, err)
+	}
+}
+
+// RemoveFileIndexAsync 文件被删除或审核不通过时，在后台删除它的向量
+func RemoveFileIndexAsync(fileID uint) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Panic while removing index of file %d: %v", fileID, r)
+			}
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := RemoveFileIndex(ctx, fileID); err != nil {
+			log.Printf("Failed to remove index of file %d: %v", fileID, err)
+		}
+	}()
+}
+
+// RemoveFileIndex 删除文件的向量和索引状态。不等待正在进行的索引任务，
+// 这些任务发现自己被取代后会撤销各自写入的向量
+func RemoveFileIndex(ctx context.Context, fileID uint) error {
+	seq := beginIndexOp(fileID)
+	defer endIndexOp(fileID, seq)
+
+	var count int64
+	if err := dbs.DB.WithContext(ctx).Model(&ai_entity.FileIndex{}).Where("file_id = ?", fileID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to get file index: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+	if err := milvus.DeleteFileChunks(ctx, fileID); err != nil {
+		return err
+	}
+	return dbs.DB.WithContext(ctx).Where("file_id = ?", fileID).Delete(&ai_entity.FileIndex{}).Error
+}
+
+// UnindexedFiles 审核通过但还没有成功建立索引的文件，用于补建索引
+func UnindexedFiles(ctx context.Context) ([]uint, error) {
+	var ids []uint
+	err := dbs.DB.WithContext(ctx).Model(&file_entity.File{}).
+		Where("status = ? AND audit_status = ?", 1, "approved").
+		Where("id NOT IN (?)", dbs.DB.Model(&ai_entity.FileIndex{}).Select("file_id").Where("status = ?", FileIndexIndexed)).
+		Order("id ASC").
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// SearchMyFiles 在用户本人的文件中检索与问题相关的分块，fileIDs 不为空时只在这些文件中检索。
+// Milvus 按所有者过滤后，命中的分块还会在数据库中再次核对文件所有者和状态
+func SearchMyFiles(ctx context.Context, userID uint, question string, fileIDs []uint, topK int) ([]FileReference, error) {
+	if topK <= 0 {
+		topK = DefaultFileHits
+	}
+	if topK > MaxFileHits {
+		topK = MaxFileHits
+	}
+	if len(fileIDs) > 0 {
+		var count int64
+		if err := ownIndexableFiles(dbs.DB.WithContext(ctx).Model(&file_entity.File{}), userID).
+			Where("id IN ?", fileIDs).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to get files: %w", err)
+		}
+		if int(count) != len(uniqueIDs(fileIDs)) {
+			return nil, ErrMyFileNotFound
+		}
+	}
+	var indexed int64
+	query := dbs.DB.WithContext(ctx).Model(&ai_entity.FileIndex{}).Where("user_id = ? AND status = ?", userID, FileIndexIndexed)
+	if len(fileIDs) > 0 {
+		query = query.Where("file_id IN ?", fileIDs)
+	}
+	if err := query.Count(&indexed).Error; err != nil {
+		return nil, fmt.Errorf("failed to get file index: %w", err)
+	}
+	if indexed == 0 {
+		return nil, ErrNoIndexedFiles
+	}
+
+	embedding, err := ai.GenerateEmbedding(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("生成查询向量失败: %v", err)
+	}
+	vector := make([]float32, len(embedding))
+	for i, v := range embedding {
+		vector[i] = float32(v)
+	}
+	hits, err := milvus.SearchFileChunks(ctx, userID, fileIDs, vector, topK)
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return []FileReference{}, nil
+	}
+
+	chunkIDs := make([]uint, 0, len(hits))
+	hitFiles := make([]uint, 0, len(hits))
+	for _, h := range hits {
+		chunkIDs = append(chunkIDs, uint(h.ChunkID))
+		hitFiles = append(hitFiles, uint(h.FileID))
+	}
+	var files []file_entity.File
+	if err := ownIndexableFiles(dbs.DB.WithContext(ctx), userID).Where("id IN ?", hitFiles).Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("failed to get files: %w", err)
+	}
+	fileByID := make(map[uint]file_entity.File, len(files))
+	for _, f := range files {
+		fileByID[f.ID] = f
+	}
+	var chunks []ai_entity.AttachmentChunk
+	if err := dbs.DB.WithContext(ctx).Where("id IN ?", chunkIDs).Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get attachment chunks: %w", err)
+	}
+	chunkByID := make(map[uint]ai_entity.AttachmentChunk, len(chunks))
+	for _, c := range chunks {
+		chunkByID[c.ID] = c
+	}
+
+	// 撤销被取代的写入之前，同一分块可能短暂存在两条向量
+	seen := make(map[uint]bool, len(hits))
+	refs := make([]FileReference, 0, len(hits))
+	for _, h := range hits {
+		c, ok := chunkByID[uint(h.ChunkID)]
+		if !ok || c.FileID != uint(h.FileID) || seen[c.ID] {
+			continue
+		}
+		seen[c.ID] = true
+		f, ok := fileByID[c.FileID]
+		if !ok {
+			continue
+		}
+		refs = append(refs, FileReference{
+			Index:    len(refs) + 1,
+			FileID:   f.ID,
+			Filename: f.Filename,
+			ChunkID:  c.ID,
+			Seq:      c.Seq,
+			Offset:   c.Offset,
+			Length:   utf8.RuneCountInString(c.Content),
+			Score:    h.Score,
+			Content:  c.Content,
+		})
+	}
+	return refs, nil
+}
+
+func uniqueIDs(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
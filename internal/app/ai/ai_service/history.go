@@ -0,0 +1,149 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	MaxHistoryLimit    = 100            // 每页最多返回的消息数
+	HistoryCacheMaxAge = 24 * time.Hour // 历史记录缓存的最长使用时间
+)
+
+var ErrHistoryCursor = errors.New("before 和 after 不能同时指定")
+
+// HistoryCursor 历史记录的分页条件，Before 和 After 为消息ID，都为 0 时返回最新的一页
+type HistoryCursor struct {
+	Before uint // 返回该消息之前的消息
+	After  uint // 返回该消息之后的消息
+	Limit  int
+}
+
+// HistoryPage 当前分支的一页消息，按时间正序
+type HistoryPage struct {
+	Messages      []ai_entity.ChatHistory
+	HeadID        uint
+	HasMoreBefore bool // 是否还有更早的消息
+	HasMoreAfter  bool // 是否还有更新的消息
+}
+
+// GetHistoryPage 按游标分页获取主题当前分支的消息，消息附带其他版本和法条引用核对结果。
+// 只按消息ID和父消息ID确定当前分支和本页的范围，再读取本页消息的内容。
+// 分支的ID序列会被缓存，分支末端变化（新消息、切换分支）后缓存不再使用
+func GetHistoryPage(ctx context.Context, userID uint, themeID uint, cursor HistoryCursor) (*HistoryPage, error) {
+	if cursor.Before != 0 && cursor.After != 0 {
+		return nil, ErrHistoryCursor
+	}
+	if cursor.Limit <= 0 {
+		cursor.Limit = DefaultHistoryLimit
+	}
+	if cursor.Limit > MaxHistoryLimit {
+		cursor.Limit = MaxHistoryLimit
+	}
+
+	chatTheme, err := GetTheme(ctx, userID, themeID)
+	if err != nil {
+		return nil, err
+	}
+	var branch []ai_entity.ChatHistory
+	var head uint
+	cache, err := LoadChatCache(userID, themeID)
+	if err == nil && IsCacheValid(cache, HistoryCacheMaxAge) && chatTheme.HeadID != 0 && cache.HeadID == chatTheme.HeadID {
+		branch, head = cache.Messages, cache.HeadID
+	} else {
+		if branch, head, err = branchSkeleton(ctx, chatTheme); err != nil {
+			return nil, err
+		}
+		if err := saveBranchCache(userID, themeID, head, branch); err != nil {
+			log.Printf("Failed to save chat cache: %v", err)
+		}
+	}
+
+	start, end := 0, len(branch)
+	if cursor.Before != 0 || cursor.After != 0 {
+		target := cursor.Before + cursor.After
+		index := -1
+		for i, h := range branch {
+			if h.ID == target {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, ErrMessageNotFound
+		}
+		if cursor.Before != 0 {
+			end = index
+		} else {
+			start = index + 1
+		}
+	}
+	if cursor.After != 0 {
+		if end-start > cursor.Limit {
+			end = start + cursor.Limit
+		}
+	} else if end-start > cursor.Limit {
+		start = end - cursor.Limit
+	}
+
+	window := branch[start:end]
+	ids := make([]uint, len(window))
+	siblings := make(map[uint][]uint, len(window))
+	for i, h := range window {
+		ids[i] = h.ID
+		siblings[h.ID] = h.Siblings
+	}
+	messages, err := loadMessages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		messages[i].Siblings = siblings[messages[i].ID]
+	}
+	AttachCitations(messages)
+	return &HistoryPage{
+		Messages:      messages,
+		HeadID:        head,
+		HasMoreBefore: start > 0,
+		HasMoreAfter:  end < len(branch),
+	}, nil
+}
+
+// 找到当前分支，按时间正序返回分支上的消息，只包含ID、父消息ID和其他版本，不读取内容
+func branchSkeleton(ctx context.Context, chatTheme *ai_entity.ChatTheme) ([]ai_entity.ChatHistory, uint, error) {
+	tree, err := loadMessageTree(dbs.DB.WithContext(ctx), chatTheme.UserID, chatTheme.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	head, err := resolveHead(ctx, chatTheme, tree)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids := tree.path(head)
+	branch := make([]ai_entity.ChatHistory, 0, len(ids))
+	for _, id := range ids {
+		node := ai_entity.ChatHistory{ID: id, ParentID: tree.nodes[id].ParentID}
+		if siblings := tree.children[node.ParentID]; len(siblings) > 1 {
+			node.Siblings = siblings
+		}
+		branch = append(branch, node)
+	}
+	return branch, head, nil
+}
+
+func saveBranchCache(userID uint, themeID uint, head uint, branch []ai_entity.ChatHistory) error {
+	return saveCache(getCacheKey(userID, themeID), ai_entity.LocalChatCache{
+		UserID:      userID,
+		ThemeID:     themeID,
+		LastUpdated: time.Now(),
+		Messages:    branch,
+		HeadID:      head,
+		Metadata: map[string]interface{}{
+			"count": len(branch),
+		},
+	})
+}
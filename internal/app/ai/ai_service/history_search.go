@@ -0,0 +1,180 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DefaultSearchPageSize = 20  // 默认每页搜索结果数
+	MaxSearchPageSize     = 50  // 每页最多的搜索结果数
+	MaxSearchQueryLength  = 100 // 搜索内容的最大字数
+	snippetBefore         = 30  // 摘要中命中位置之前保留的字数
+	snippetLength         = 120 // 摘要的字数
+)
+
+var ErrEmptySearchQuery = errors.New("搜索内容不能为空")
+
+// HistorySearchQuery 聊天记录的搜索条件
+type HistorySearchQuery struct {
+	Keyword  string // 多个关键词用空格分隔，需全部包含
+	Page     int
+	PageSize int
+}
+
+// HistorySearchHit 一条命中的消息
+type HistorySearchHit struct {
+	MessageID uint      `json:"message_id"`
+	ThemeID   uint      `json:"theme_id"`
+	Theme     string    `json:"theme"`
+	Role      string    `json:"role"`
+	Snippet   string    `json:"snippet"` // 命中位置附近的内容，已做 HTML 转义，关键词以 <em></em> 标出
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchHistory 在用户全部主题的提问和回答中搜索，按时间倒序返回当前页和总数。
+// 使用 ngram 全文索引匹配，单个字的关键词无法使用全文索引，退回 LIKE 匹配
+func SearchHistory(ctx context.Context, userID uint, q HistorySearchQuery) ([]HistorySearchHit, int64, error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 || q.PageSize > MaxSearchPageSize {
+		q.PageSize = DefaultSearchPageSize
+	}
+	terms := searchTerms(q.Keyword)
+	if len(terms) == 0 {
+		return nil, 0, ErrEmptySearchQuery
+	}
+
+	query := dbs.DB.WithContext(ctx).Table("chat_histories AS h").
+		Joins("JOIN chat_themes AS t ON t.id = h.theme_id AND t.user_id = h.user_id").
+		Where("h.user_id = ? AND h.role IN ?", userID, []string{"user", "assistant"})
+	var phrases []string
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < 2 {
+			query = query.Where("h.content LIKE ?", "%"+escapeLike(term)+"%")
+			continue
+		}
+		phrases = append(phrases, `+"`+term+`"`)
+	}
+	if len(phrases) > 0 {
+		query = query.Where("MATCH(h.content) AGAINST (? IN BOOLEAN MODE)", strings.Join(phrases, " "))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count chat history: %w", err)
+	}
+	var rows []struct {
+		ID        uint
+		ThemeID   uint
+		Theme     string
+		Role      string
+		Content   string
+		CreatedAt time.Time
+	}
+	if err := query.Select("h.id, h.theme_id, t.theme, h.role, h.content, h.created_at").
+		Order("h.created_at DESC, h.id DESC").
+		Limit(q.PageSize).
+		Offset((q.Page - 1) * q.PageSize).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to search chat history: %w", err)
+	}
+
+	hits := make([]HistorySearchHit, 0, len(rows))
+	for _, r := range rows {
+		hits = append(hits, HistorySearchHit{
+			MessageID: r.ID,
+			ThemeID:   r.ThemeID,
+			Theme:     r.Theme,
+			Role:      r.Role,
+			Snippet:   highlightSnippet(r.Content, terms),
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	return hits, total, nil
+}
+
+// 按空白拆分关键词，去掉全文搜索中有特殊含义的双引号，重复的只保留一个
+func searchTerms(keyword string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(strings.ReplaceAll(keyword, `"`, " ")) {
+		term = strings.ToLower(term)
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// 截取第一个关键词附近的内容作为摘要，标出其中的全部关键词（不区分大小写）
+func highlightSnippet(content string, terms []string) string {
+	runes := []rune(content)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		if unicode.IsSpace(r) {
+			runes[i] = ' '
+		}
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) != term {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start := 0
+	if first > snippetBefore {
+		start = first - snippetBefore
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		text := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			text = "<em>" + text + "</em>"
+		}
+		sb.WriteString(text)
+		i = j
+	}
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
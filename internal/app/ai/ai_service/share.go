@@ -0,0 +1,155 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/pkg/utils/export"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	MaxShareExpiry        = 30 * 24 * time.Hour // 分享链接最长有效期
+	MaxSharePasswordBytes = 72                  // 访问密码的最大字节数，bcrypt 不接受更长的密码
+)
+
+var (
+	ErrShareNotFound       = errors.New("分享不存在或已被撤销")
+	ErrShareExpired        = errors.New("分享已过期")
+	ErrSharePasswordNeeded = errors.New("该分享需要密码")
+	ErrSharePasswordWrong  = errors.New("密码错误")
+	ErrSharePasswordLong   = fmt.Errorf("访问密码不能超过 %d 字节", MaxSharePasswordBytes)
+)
+
+// CreateShare 为主题当前分支的对话创建分享快照。expiresIn 为 0 时不过期，password 为空时不需要密码
+func CreateShare(ctx context.Context, userID uint, themeID uint, expiresIn time.Duration, password string) (*ai_entity.ChatShare, error) {
+	if len(password) > MaxSharePasswordBytes {
+		return nil, ErrSharePasswordLong
+	}
+	doc, err := ExportTheme(ctx, userID, themeID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	doc.Meta = []string{fmt.Sprintf("分享时间：%s", now.Format("2006-01-02 15:04:05"))}
+	snapshot, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+	share := ai_entity.ChatShare{
+		UserID:    userID,
+		ThemeID:   themeID,
+		Token:     token,
+		Title:     doc.Title,
+		Snapshot:  string(snapshot),
+		CreatedAt: now,
+	}
+	if expiresIn > 0 {
+		if expiresIn > MaxShareExpiry {
+			expiresIn = MaxShareExpiry
+		}
+		expiresAt := now.Add(expiresIn)
+		share.ExpiresAt = &expiresAt
+	}
+	if password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		share.Password = string(hashed)
+		share.HasPassword = true
+	}
+	if err := dbs.DB.WithContext(ctx).Create(&share).Error; err != nil {
+		return nil, fmt.Errorf("failed to create chat share: %w", err)
+	}
+	return &share, nil
+}
+
+// 32 字节随机数，URL 安全的 base64 编码
+func newShareToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ListShares 列出用户的分享，themeID 为 0 时列出全部
+func ListShares(ctx context.Context, userID uint, themeID uint) ([]ai_entity.ChatShare, error) {
+	query := dbs.DB.WithContext(ctx).Omit("snapshot").Where("user_id = ?", userID)
+	if themeID != 0 {
+		query = query.Where("theme_id = ?", themeID)
+	}
+	shares := make([]ai_entity.ChatShare, 0)
+	if err := query.Order("created_at DESC").Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("failed to get chat shares: %w", err)
+	}
+	for i := range shares {
+		shares[i].HasPassword = shares[i].Password != ""
+	}
+	return shares, nil
+}
+
+// RevokeShare 撤销分享，链接立即失效
+func RevokeShare(ctx context.Context, userID uint, shareID uint) error {
+	result := dbs.DB.WithContext(ctx).Where("id = ? AND user_id = ?", shareID, userID).Delete(&ai_entity.ChatShare{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke chat share: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// OpenShare 校验有效期和密码后返回分享的对话快照，并记录一次访问
+func OpenShare(ctx context.Context, token string, password string) (*ai_entity.ChatShare, *export.Document, error) {
+	var share ai_entity.ChatShare
+	err := dbs.DB.WithContext(ctx).Where("token = ?", token).First(&share).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrShareNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get chat share: %w", err)
+	}
+	if share.ExpiresAt != nil && time.Now().After(*share.ExpiresAt) {
+		return nil, nil, ErrShareExpired
+	}
+	if share.Password != "" {
+		if password == "" {
+			return nil, nil, ErrSharePasswordNeeded
+		}
+		if bcrypt.CompareHashAndPassword([]byte(share.Password), []byte(password)) != nil {
+			return nil, nil, ErrSharePasswordWrong
+		}
+	}
+
+	var doc export.Document
+	if err := json.Unmarshal([]byte(share.Snapshot), &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse chat share: %w", err)
+	}
+	dbs.DB.WithContext(ctx).Model(&ai_entity.ChatShare{}).Where("id = ?", share.ID).
+		UpdateColumn("views", gorm.Expr("views + 1"))
+	share.HasPassword = share.Password != ""
+	return &share, &doc, nil
+}
+
+// DeleteThemeShares 撤销主题的全部分享，在删除主题的事务中调用
+func DeleteThemeShares(tx *gorm.DB, themeID uint) error {
+	if err := tx.Where("theme_id = ?", themeID).Delete(&ai_entity.ChatShare{}).Error; err != nil {
+		return fmt.Errorf("failed to delete chat shares: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultThemePageSize = 20 // 默认每页主题数
+	MaxThemePageSize     = 100
+	MaxThemeTags         = 10 // 每个主题最多的标签数
+	MaxThemeTagLength    = 20 // 标签最大字数
+)
+
+var ErrInvalidTag = fmt.Errorf("标签不能为空且不能超过 %d 个字，每个主题最多 %d 个标签", MaxThemeTagLength, MaxThemeTags)
+
+// ThemeQuery 主题列表的筛选条件
+type ThemeQuery struct {
+	Keyword  string // 按名称模糊搜索
+	Tag      string // 只列出带该标签的主题
+	Archived bool   // 为 true 时只列出已归档的主题，否则只列出未归档的
+	Page     int
+	PageSize int
+}
+
+// TagCount 标签及使用它的主题数
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// ListThemes 分页获取用户的主题，置顶的在前，其余按最后消息时间倒序，返回当前页和总数
+func ListThemes(ctx context.Context, userID uint, q ThemeQuery) ([]ai_entity.ChatTheme, int64, error) {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 || q.PageSize > MaxThemePageSize {
+		q.PageSize = DefaultThemePageSize
+	}
+
+	db := dbs.DB.WithContext(ctx)
+	query := db.Model(&ai_entity.ChatTheme{}).Where("user_id = ? AND archived = ?", userID, q.Archived)
+	if q.Keyword != "" {
+		query = query.Where("theme LIKE ?", "%"+escapeLike(q.Keyword)+"%")
+	}
+	if q.Tag != "" {
+		query = query.Where("id IN (?)", db.Model(&ai_entity.ChatThemeTag{}).
+			Select("theme_id").
+			Where("user_id = ? AND tag = ?", userID, q.Tag))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count chat themes: %w", err)
+	}
+	themes := make([]ai_entity.ChatTheme, 0, q.PageSize)
+	if err := query.Order("pinned DESC, last_message DESC").
+		Limit(q.PageSize).
+		Offset((q.Page - 1) * q.PageSize).
+		Find(&themes).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to get chat themes: %w", err)
+	}
+	if err := attachTags(ctx, themes); err != nil {
+		return nil, 0, err
+	}
+	return themes, total, nil
+}
+
+// 读取主题的标签
+func attachTags(ctx context.Context, themes []ai_entity.ChatTheme) error {
+	if len(themes) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(themes))
+	for _, t := range themes {
+		ids = append(ids, t.ID)
+	}
+	var tags []ai_entity.ChatThemeTag
+	if err := dbs.DB.WithContext(ctx).Where("theme_id IN ?", ids).Order("id ASC").Find(&tags).Error; err != nil {
+		return fmt.Errorf("failed to get theme tags: %w", err)
+	}
+	byTheme := make(map[uint][]string, len(themes))
+	for _, t := range tags {
+		byTheme[t.ThemeID] = append(byTheme[t.ThemeID], t.Tag)
+	}
+	for i := range themes {
+		themes[i].Tags = byTheme[themes[i].ID]
+		if themes[i].Tags == nil {
+			themes[i].Tags = []string{}
+		}
+	}
+	return nil
+}
+
+// ListThemeTags 列出用户用过的标签及对应的主题数
+func ListThemeTags(ctx context.Context, userID uint) ([]TagCount, error) {
+	tags := make([]TagCount, 0)
+	if err := dbs.DB.WithContext(ctx).Model(&ai_entity.ChatThemeTag{}).
+		Select("tag, COUNT(*) AS count").
+		Where("user_id = ?", userID).
+		Group("tag").
+		Order("count DESC, tag ASC").
+		Scan(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to get theme tags: %w", err)
+	}
+	return tags, nil
+}
+
+// SetThemePinned 置顶或取消置顶
+func SetThemePinned(ctx context.Context, userID uint, themeID uint, pinned bool) error {
+	return updateTheme(ctx, userID, themeID, map[string]interface{}{"pinned": pinned})
+}
+
+// SetThemeArchived 归档或取消归档
+func SetThemeArchived(ctx context.Context, userID uint, themeID uint, archived bool) error {
+	return updateTheme(ctx, userID, themeID, map[string]interface{}{"archived": archived})
+}
+
+// SetThemeTags 用 tags 替换主题的全部标签，去掉首尾空白和重复的标签
+func SetThemeTags(ctx context.Context, userID uint, themeID uint, tags []string) ([]string, error) {
+	cleaned := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || utf8.RuneCountInString(tag) > MaxThemeTagLength {
+			return nil, ErrInvalidTag
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			cleaned = append(cleaned, tag)
+		}
+	}
+	if len(cleaned) > MaxThemeTags {
+		return nil, ErrInvalidTag
+	}
+
+	if _, err := GetTheme(ctx, userID, themeID); err != nil {
+		return nil, err
+	}
+	err := dbs.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("theme_id = ?", themeID).Delete(&ai_entity.ChatThemeTag{}).Error; err != nil {
+			return err
+		}
+		for _, tag := range cleaned {
+			if err := tx.Create(&ai_entity.ChatThemeTag{UserID: userID, ThemeID: themeID, Tag: tag}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save theme tags: %w", err)
+	}
+	return cleaned, nil
+}
+
+// 更新用户主题的字段，主题不存在时返回 ErrThemeNotFound
+func updateTheme(ctx context.Context, userID uint, themeID uint, values map[string]interface{}) error {
+	result := dbs.DB.WithContext(ctx).Model(&ai_entity.ChatTheme{}).
+		Where("id = ? AND user_id = ?", themeID, userID).
+		Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 值未变化时 RowsAffected 也为 0，再确认主题是否存在
+		if _, err := GetTheme(ctx, userID, themeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteThemeTags 删除主题的全部标签，在删除主题的事务中调用
+func DeleteThemeTags(tx *gorm.DB, themeID uint) error {
+	if err := tx.Where("theme_id = ?", themeID).Delete(&ai_entity.ChatThemeTag{}).Error; err != nil {
+		return fmt.Errorf("failed to delete theme tags: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package ai_service
+
+import (
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// 旧表结构：chat_themes 上只有主题名称的唯一索引，chat_histories 按 (user_id, theme) 名称关联主题，两个索引同名
+const (
+	legacyThemeIndex  = "idx_user_theme"
+	legacyThemeColumn = "theme"
+)
+
+// ThemeMigrationResult 主题ID迁移的结果
+type ThemeMigrationResult struct {
+	ThemesCreated  int64 // 补建的主题数（旧唯一索引导致其他用户同名主题未能创建）
+	RowsLinked     int64 // 回填了 theme_id 的历史记录数
+	AlreadyApplied bool  // 之前已经迁移过
+}
+
+// NeedsThemeMigration 历史记录是否仍按主题名称关联，需要运行 migrate-themes
+func NeedsThemeMigration(db *gorm.DB) bool {
+	return db.Migrator().HasColumn(&ai_entity.ChatHistory{}, legacyThemeColumn)
+}
+
+// MigrateThemeIDs 将历史记录从按主题名称关联迁移为按主题ID关联，可重复执行。
+// 需要在 AutoMigrate 之后运行（theme_id 列和新的唯一索引已创建）
+func MigrateThemeIDs(db *gorm.DB) (*ThemeMigrationResult, error) {
+	result := &ThemeMigrationResult{}
+	m := db.Migrator()
+
+	// 只包含主题名称的唯一索引会让不同用户的同名主题冲突
+	if m.HasIndex(&ai_entity.ChatTheme{}, legacyThemeIndex) {
+		if err := m.DropIndex(&ai_entity.ChatTheme{}, legacyThemeIndex); err != nil {
+			return nil, fmt.Errorf("删除 chat_themes 旧索引失败: %w", err)
+		}
+	}
+	if !NeedsThemeMigration(db) {
+		result.AlreadyApplied = true
+		return result, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 为没有主题记录的 (user_id, theme) 补建主题
+		created := tx.Exec(`
+			INSERT INTO chat_themes (user_id, theme, last_message, created_at, updated_at)
+			SELECT h.user_id, h.theme, MAX(h.created_at), MIN(h.created_at), NOW()
+			FROM chat_histories h
+			LEFT JOIN chat_themes t ON t.user_id = h.user_id AND t.theme = h.theme
+			WHERE t.id IS NULL AND h.theme_id = 0
+			GROUP BY h.user_id, h.theme
+		`)
+		if created.Error != nil {
+			return fmt.Errorf("补建主题失败: %w", created.Error)
+		}
+		result.ThemesCreated = created.RowsAffected
+
+		linked := tx.Exec(`
+			UPDATE chat_histories h
+			JOIN chat_themes t ON t.user_id = h.user_id AND t.theme = h.theme
+			SET h.theme_id = t.id
+			WHERE h.theme_id = 0
+		`)
+		if linked.Error != nil {
+			return fmt.Errorf("回填 theme_id 失败: %w", linked.Error)
+		}
+		result.RowsLinked = linked.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 全部关联后再删除旧的名称列
+	var unlinked int64
+	if err := db.Model(&ai_entity.ChatHistory{}).Where("theme_id = 0").Count(&unlinked).Error; err != nil {
+		return nil, err
+	}
+	if unlinked > 0 {
+		return nil, fmt.Errorf("仍有 %d 条历史记录未关联主题，未删除旧的 theme 列", unlinked)
+	}
+	if m.HasIndex(&ai_entity.ChatHistory{}, legacyThemeIndex) {
+		if err := m.DropIndex(&ai_entity.ChatHistory{}, legacyThemeIndex); err != nil {
+			return nil, fmt.Errorf("删除 chat_histories 旧索引失败: %w", err)
+		}
+	}
+	if err := m.DropColumn(&ai_entity.ChatHistory{}, legacyThemeColumn); err != nil {
+		return nil, fmt.Errorf("删除 chat_histories.theme 列失败: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,43 @@
+package ai_service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestThemeName(t *testing.T) {
+	long := strings.Repeat("劳动合同纠纷", 9) // 54 字
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{name: "劳动合同纠纷", n: 1, want: "劳动合同纠纷"},
+		{name: "劳动合同纠纷", n: 2, want: "劳动合同纠纷(2)"},
+		{name: long, n: 1, want: string([]rune(long)[:50])},
+		{name: long, n: 12, want: string([]rune(long)[:46]) + "(12)"},
+	}
+	for _, tt := range tests {
+		got := themeName(tt.name, tt.n)
+		if got != tt.want {
+			t.Errorf("themeName(%q, %d) = %q, want %q", tt.name, tt.n, got, tt.want)
+		}
+		if utf8.RuneCountInString(got) > MaxThemeNameLength {
+			t.Errorf("themeName(%q, %d) has %d runes", tt.name, tt.n, utf8.RuneCountInString(got))
+		}
+	}
+}
+
+func TestIsDuplicateKey(t *testing.T) {
+	dup := fmt.Errorf("failed to create new theme: %w", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	if !isDuplicateKey(dup) {
+		t.Fatal("wrapped 1062 error should be a duplicate key")
+	}
+	if isDuplicateKey(&mysql.MySQLError{Number: 1146}) || isDuplicateKey(nil) {
+		t.Fatal("other errors should not be duplicate keys")
+	}
+}
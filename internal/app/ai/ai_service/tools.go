@@ -0,0 +1,295 @@
+package ai_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/File/file_entity"
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"Programming-Demo/pkg/utils/ai"
+	"Programming-Demo/pkg/utils/bocha"
+	"Programming-Demo/pkg/utils/civilcode"
+	"Programming-Demo/pkg/utils/filetext"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+const (
+	DefaultStatuteTopK = 5                // search_statutes 默认返回条数
+	MaxStatuteTopK     = 10               // search_statutes 最多返回条数
+	MaxToolFileSize    = 10 * 1024 * 1024 // get_user_file 可读取的最大文件大小
+	UserFileTool       = "get_user_file"  // 读取用户文件的工具名称
+	WebSearchTool      = "web_search"     // 联网搜索的工具名称
+)
+
+// ChatTools 对话中可供模型调用的工具，get_user_file 只能读取 userID 本人上传的文件
+func ChatTools(userID uint) []ai.Tool {
+	return []ai.Tool{
+		lookupArticleTool(),
+		searchStatutesTool(),
+		webSearchTool(),
+		userFileTool(userID),
+	}
+}
+
+// 解析模型给出的 JSON 参数
+func parseArguments(arguments string, v interface{}) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), v); err != nil {
+		return fmt.Errorf("参数格式错误: %v", err)
+	}
+	return nil
+}
+
+// 按条号精确查询民法典条文
+func lookupArticleTool() ai.Tool {
+	return ai.NewTool(ai.ToolDefinition{
+		Name:        "lookup_article",
+		Description: "按条号精确查询《中华人民共和国民法典》的条文原文。引用民法典具体条文前应先调用此工具核对原文",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"article": map[string]interface{}{
+					"type":        "string",
+					"description": "条号，如“1079”“第1079条”或“第一千零七十九条”",
+				},
+			},
+			"required": []string{"article"},
+		},
+	}, func(ctx context.Context, arguments string) (string, error) {
+		var args struct {
+			Article string `json:"article"`
+		}
+		if err := parseArguments(arguments, &args); err != nil {
+			return "", err
+		}
+		article, err := civilcode.Lookup(args.Article)
+		if err != nil {
+			return "", err
+		}
+		location := strings.Join(nonEmpty(article.Book, article.Chapter, article.Section), " ")
+		return fmt.Sprintf("《民法典》%s（%s）\n%s", article.Title, location, article.Content), nil
+	})
+}
+
+// 在民法典向量库中做语义检索
+func searchStatutesTool() ai.Tool {
+	return ai.NewTool(ai.ToolDefinition{
+		Name:        "search_statutes",
+		Description: "根据问题描述语义检索《民法典》中相关的条文，适用于不确定具体条号的情况",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "检索内容，如“离婚时夫妻共同财产如何分割”",
+				},
+				"top_k": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("返回条数，默认 %d，最多 %d", DefaultStatuteTopK, MaxStatuteTopK),
+				},
+			},
+			"required": []string{"query"},
+		},
+	}, func(ctx context.Context, arguments string) (string, error) {
+		var args struct {
+			Query string `json:"query"`
+			TopK  int    `json:"top_k"`
+		}
+		if err := parseArguments(arguments, &args); err != nil {
+			return "", err
+		}
+		if args.TopK <= 0 {
+			args.TopK = DefaultStatuteTopK
+		}
+		if args.TopK > MaxStatuteTopK {
+			args.TopK = MaxStatuteTopK
+		}
+
+		docs, err := ai.SearchSimilarDocumentsWithParam(ctx, args.Query, args.TopK)
+		if err != nil {
+			return "", err
+		}
+		if len(docs) > args.TopK {
+			docs = docs[:args.TopK]
+		}
+		var sb strings.Builder
+		for i, doc := range docs {
+			sb.WriteString(fmt.Sprintf("[%d] 相关度:%.2f\n%s\n\n", i+1, doc.Score, doc.Content))
+		}
+		if sb.Len() == 0 {
+			return "没有找到相关条文", nil
+		}
+		return sb.String(), nil
+	})
+}
+
+// 使用博查联网搜索
+func webSearchTool() ai.Tool {
+	return ai.NewTool(ai.ToolDefinition{
+		Name:        WebSearchTool,
+		Description: "联网搜索最新的新闻、司法解释、典型案例等信息，适用于民法典以外或时效性强的问题",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "搜索关键词",
+				},
+			},
+			"required": []string{"query"},
+		},
+	}, func(ctx context.Context, arguments string) (string, error) {
+		var args struct {
+			Query string `json:"query"`
+		}
+		if err := parseArguments(arguments, &args); err != nil {
+			return "", err
+		}
+		err, searchInfo := ai.WebBaseSearch(ctx, args.Query)
+		if err != nil && searchInfo == "" {
+			return "", err
+		}
+		return searchInfo, nil
+	})
+}
+
+// 读取用户本人上传的文件，不传文件名时列出最近上传的文件
+func userFileTool(userID uint) ai.Tool {
+	return ai.NewTool(ai.ToolDefinition{
+		Name:        UserFileTool,
+		Description: "读取用户本人上传的文档内容（如合同、起诉状）。不传 filename 时返回用户最近上传的文件列表",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"filename": map[string]interface{}{
+					"type":        "string",
+					"description": "文件名",
+				},
+			},
+		},
+	}, func(ctx context.Context, arguments string) (string, error) {
+		var args struct {
+			Filename string `json:"filename"`
+		}
+		if err := parseArguments(arguments, &args); err != nil {
+			return "", err
+		}
+
+		db := dbs.DB.WithContext(ctx).Where("user_id = ? AND status = ?", userID, 1)
+		if args.Filename == "" {
+			var files []file_entity.File
+			if err := db.Order("created_at DESC").Limit(20).Find(&files).Error; err != nil {
+				return "", err
+			}
+			if len(files) == 0 {
+				return "用户还没有上传过文件", nil
+			}
+			names := make([]string, 0, len(files))
+			for _, f := range files {
+				names = append(names, f.Filename)
+			}
+			return "用户最近上传的文件：\n" + strings.Join(names, "\n"), nil
+		}
+
+		var file file_entity.File
+		if err := db.Where("filename = ?", args.Filename).First(&file).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", fmt.Errorf("文件 %s 不存在", args.Filename)
+			}
+			return "", err
+		}
+		if file.Size > MaxToolFileSize {
+			return "", fmt.Errorf("文件大小超过限制")
+		}
+		content, err := filetext.Extract(file.Filepath)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("文件 %s 的内容：\n%s", file.Filename, content), nil
+	})
+}
+
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// UsedUserFile 工具调用过程中是否读取了用户文件，读取过的回答不能与其他用户共享
+func UsedUserFile(trail []ai.Message) bool {
+	for _, m := range trail {
+		for _, call := range m.ToolCalls {
+			if call.Name == UserFileTool {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SearchSources 回答参考的联网搜索来源（JSON 数组）：searchInfo 为开启联网搜索时的结果，
+// 另加模型调用 web_search 的结果，按网址去重。没有来源时返回空字符串
+func SearchSources(searchInfo string, trail []ai.Message) string {
+	sources := bocha.ParseSources(searchInfo)
+	searchCalls := make(map[string]bool)
+	for _, m := range trail {
+		for _, call := range m.ToolCalls {
+			if call.Name == WebSearchTool {
+				searchCalls[call.ID] = true
+			}
+		}
+		if m.Role == ai.RoleTool && searchCalls[m.ToolCallID] {
+			sources = append(sources, bocha.ParseSources(m.Content)...)
+		}
+	}
+
+	seen := make(map[string]bool, len(sources))
+	unique := sources[:0]
+	for _, s := range sources {
+		if !seen[s.URL] {
+			seen[s.URL] = true
+			unique = append(unique, s)
+		}
+	}
+	if len(unique) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(unique)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// ToolHistories 将工具调用过程转为对话历史记录，与最终回答一起保存
+func ToolHistories(userID uint, themeID uint, model string, trail []ai.Message) ([]ai_entity.ChatHistory, error) {
+	histories := make([]ai_entity.ChatHistory, 0, len(trail))
+	for _, m := range trail {
+		history := ai_entity.ChatHistory{
+			UserID:     userID,
+			ThemeID:    themeID,
+			Model:      model,
+			Role:       m.Role,
+			Content:    m.Content,
+			ToolCallID: m.ToolCallID,
+		}
+		if len(m.ToolCalls) > 0 {
+			calls, err := json.Marshal(m.ToolCalls)
+			if err != nil {
+				return nil, err
+			}
+			history.ToolCalls = string(calls)
+		}
+		histories = append(histories, history)
+	}
+	return histories, nil
+}
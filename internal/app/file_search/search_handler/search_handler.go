@@ -30,7 +30,7 @@ func KeywordSearch(c *gin.Context) {
 	if req.Fuzzy {
 		// 使用 AI 进行模糊搜索
 		prompt := fmt.Sprintf("请在法律文档中搜索与\"%s\"相关的内容，包括相似概念和相关联的法律术语", req.Keyword)
-		aiResp, code := ai.GetAIResp(prompt)
+		aiResp, code := ai.GetAIResp(c.Request.Context(), prompt)
 		if code != 200 {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"code":    500,
@@ -186,7 +186,7 @@ func SemanticSearch(c *gin.Context) {
 
 	// 使用 AI 理解查询意图
 	prompt := fmt.Sprintf("请分析以下法律查询，提取关键信息并转换为结构化搜索条件：%s", req.Query)
-	aiResp, code := ai.GetAIResp(prompt)
+	aiResp, code := ai.GetAIResp(c.Request.Context(), prompt)
 	if code != 200 {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
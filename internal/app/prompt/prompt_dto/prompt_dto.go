@@ -0,0 +1,29 @@
+package prompt_dto
+
+// CreatePromptReq 新建提示词版本，版本号自动递增
+type CreatePromptReq struct {
+	Name        string `json:"name" binding:"required"`    // 模板名称，只能是内置模板之一
+	Content     string `json:"content" binding:"required"` // text/template 格式的模板内容
+	Description string `json:"description"`                // 修改说明
+	Activate    bool   `json:"activate"`                   // 是否立即生效，为 false 时保存为草稿
+}
+
+// PreviewPromptReq 使用示例数据渲染模板
+type PreviewPromptReq struct {
+	Name    string                 `json:"name" binding:"required"`
+	Content string                 `json:"content"` // 为空时渲染当前生效的版本
+	Data    map[string]interface{} `json:"data"`    // 为空时使用内置示例数据，字段名与模板中的一致（如 Theme、Content）
+}
+
+// RollbackPromptReq 切换生效版本，Version 为 0 时恢复内置默认模板
+type RollbackPromptReq struct {
+	Version int `json:"version"`
+}
+
+// PromptSummary 提示词列表项
+type PromptSummary struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	ActiveVersion int    `json:"active_version"` // 0 表示使用内置默认模板
+	LatestVersion int    `json:"latest_version"`
+}
@@ -0,0 +1,20 @@
+package prompt_entity
+
+import "time"
+
+// PromptTemplate 提示词模板的一个版本（Go text/template 格式）。
+// 同名模板中 Active 的版本生效，没有生效版本时使用内置默认模板（版本号 0）
+type PromptTemplate struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Name        string    `gorm:"size:64;not null;uniqueIndex:idx_name_version" json:"name"`
+	Version     int       `gorm:"not null;uniqueIndex:idx_name_version" json:"version"`
+	Content     string    `gorm:"type:text;not null" json:"content"`
+	Description string    `gorm:"size:255" json:"description"` // 本次修改说明
+	Active      bool      `gorm:"default:false" json:"active"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (PromptTemplate) TableName() string {
+	return "prompt_templates"
+}
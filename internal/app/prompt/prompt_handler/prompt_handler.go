@@ -0,0 +1,100 @@
+package prompt_handler
+
+import (
+	"Programming-Demo/core/libx"
+	"Programming-Demo/internal/app/prompt/prompt_dto"
+	"Programming-Demo/internal/app/prompt/prompt_service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 获取提示词列表
+func ListPrompts(c *gin.Context) {
+	prompts, err := prompt_service.ListPrompts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "获取提示词失败", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": prompts})
+}
+
+// 获取提示词的全部版本
+func ListPromptVersions(c *gin.Context) {
+	versions, err := prompt_service.ListVersions(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		respondPromptError(c, "获取提示词版本失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": versions})
+}
+
+// 新建提示词版本
+func CreatePrompt(c *gin.Context) {
+	var req prompt_dto.CreatePromptReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	version, err := prompt_service.CreateVersion(c.Request.Context(), libx.Uid(c), req)
+	if err != nil {
+		respondPromptError(c, "保存提示词失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "保存成功", "data": version})
+}
+
+// 预览提示词渲染结果
+func PreviewPrompt(c *gin.Context) {
+	var req prompt_dto.PreviewPromptReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	rendered, err := prompt_service.Preview(c.Request.Context(), req.Name, req.Content, req.Data)
+	if err != nil {
+		respondPromptError(c, "预览失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "success", "data": rendered.Text, "version": rendered.Version})
+}
+
+// 回滚到指定版本
+func RollbackPrompt(c *gin.Context) {
+	var req prompt_dto.RollbackPromptReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "参数错误", "error": err.Error()})
+		return
+	}
+	if err := prompt_service.Rollback(c.Request.Context(), c.Param("name"), req.Version); err != nil {
+		respondPromptError(c, "回滚失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "回滚成功"})
+}
+
+// 删除未生效的版本
+func DeletePromptVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": 400, "message": "版本号格式错误"})
+		return
+	}
+	if err := prompt_service.DeleteVersion(c.Request.Context(), c.Param("name"), version); err != nil {
+		respondPromptError(c, "删除失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": 200, "message": "删除成功"})
+}
+
+func respondPromptError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, prompt_service.ErrUnknownPrompt), errors.Is(err, gorm.ErrRecordNotFound):
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{"code": status, "message": message, "error": err.Error()})
+}
@@ -0,0 +1,379 @@
+package prompt_service
+
+import (
+	"Programming-Demo/internal/app/ai/ai_dto"
+	"text/template"
+)
+
+// 内置提示词模板名称
+const (
+	LegalAssistant = "legal_assistant" // 法律咨询对话的系统提示词
+	WebSearch      = "web_search"      // 联网搜索问答的系统提示词
+	LegalDoc       = "legal_doc"       // 合同等法律文件生成
+	Complaint      = "complaint"       // 起诉状生成
+	LegalOpinion   = "legal_opinion"   // 法律意见书生成
+	LegalAnalysis  = "legal_analysis"  // 上传文件分析
+	FileQA         = "file_qa"         // 基于用户本人文件的问答
+	ChatSummary    = "chat_summary"    // 早期对话的滚动摘要
+	ThemeName      = "theme_name"      // 根据第一个问题生成主题名称
+)
+
+// LegalAssistantData legal_assistant 模板的数据
+type LegalAssistantData struct {
+	Theme  string // 对话主题
+	Search bool   // 是否附带联网搜索结果
+}
+
+// WebSearchData web_search 模板的数据
+type WebSearchData struct {
+	Date string // 当前日期，如 2025年01月02日
+}
+
+// LegalAnalysisData legal_analysis 模板的数据
+type LegalAnalysisData struct {
+	Content string // 文件内容
+}
+
+// FileQAData file_qa 模板的数据
+type FileQAData struct {
+	Question string
+	Excerpts []FileExcerpt // 检索到的文件片段，按相关度排序
+}
+
+// FileExcerpt 文件片段
+type FileExcerpt struct {
+	Index    int // 回答中引用的编号
+	FileID   uint
+	Filename string
+	Offset   int // 片段在文件中的起始位置（字符数）
+	Content  string
+}
+
+// ChatSummaryData chat_summary 模板的数据
+type ChatSummaryData struct {
+	Previous   string // 已有摘要，为空表示第一次摘要
+	Transcript string // 需要并入摘要的对话，每行一条消息
+}
+
+// ThemeNameData theme_name 模板的数据
+type ThemeNameData struct {
+	Question string
+}
+
+// 内置默认模板，数据库中没有生效版本时使用
+type builtinPrompt struct {
+	Description string
+	Content     string
+	Sample      interface{} // 预览和保存前校验使用的示例数据
+}
+
+// 模板中可用的函数
+var funcMap = template.FuncMap{
+	// 列表序号从 1 开始
+	"inc": func(i int) int { return i + 1 },
+}
+
+var builtinPrompts = map[string]builtinPrompt{
+	LegalAssistant: {
+		Description: "法律咨询对话的系统提示词",
+		Content:     legalAssistantTemplate,
+		Sample:      LegalAssistantData{Theme: "房屋租赁纠纷", Search: true},
+	},
+	WebSearch: {
+		Description: "联网搜索问答的系统提示词",
+		Content:     webSearchTemplate,
+		Sample:      WebSearchData{Date: "2025年01月02日"},
+	},
+	LegalDoc: {
+		Description: "合同等法律文件生成",
+		Content:     legalDocTemplate,
+		Sample: ai_dto.GenerateLegalDocReq{
+			DocType: "合同",
+			Title:   "房屋租赁合同",
+			Parties: []ai_dto.Party{{Type: "甲方", Name: "张三", Details: "出租人"}, {Type: "乙方", Name: "李四", Details: "承租人"}},
+			Content: ai_dto.LawsBase{Subject: "北京市海淀区某小区2号楼301室", Price: "每月5000元", Payment: "按季度支付"},
+		},
+	},
+	Complaint: {
+		Description: "起诉状生成",
+		Content:     complaintTemplate,
+		Sample: ai_dto.GenerateComplaintReq{Content: ai_dto.ComplaintBase{
+			Court:     "北京市海淀区人民法院",
+			Plaintiff: ai_dto.Party{Name: "张三", Details: "男，1990年1月1日出生"},
+			Defendant: ai_dto.Party{Name: "李四", Details: "男，1985年5月5日出生"},
+			Claims:    []string{"判令被告返还借款10万元", "判令被告承担本案诉讼费用"},
+			Facts:     "被告于2023年向原告借款10万元，到期后拒不归还。",
+			Evidence:  []string{"借条", "银行转账记录"},
+			LawBasis:  []string{"《民法典》第六百七十五条"},
+		}},
+	},
+	LegalOpinion: {
+		Description: "法律意见书生成",
+		Content:     legalOpinionTemplate,
+		Sample: ai_dto.GenerateLegalOpinionReq{Content: ai_dto.LegalOpinionBase{
+			Background: "某公司拟与供应商签订长期采购合同。",
+			Issues:     []string{"合同中的违约金条款是否有效"},
+			Risks:      []string{"违约金过高可能被法院调整"},
+		}},
+	},
+	LegalAnalysis: {
+		Description: "上传文件分析",
+		Content:     legalAnalysisTemplate,
+		Sample:      LegalAnalysisData{Content: "甲方将房屋出租给乙方，租期一年，月租金5000元……"},
+	},
+	FileQA: {
+		Description: "基于用户本人文件的问答",
+		Content:     fileQATemplate,
+		Sample: FileQAData{
+			Question: "哪些租赁合同允许提前解约？",
+			Excerpts: []FileExcerpt{{Index: 1, FileID: 12, Filename: "房屋租赁合同.docx", Offset: 1600, Content: "第八条 租赁期内，乙方提前一个月书面通知甲方的，可以解除本合同……"}},
+		},
+	},
+	ChatSummary: {
+		Description: "早期对话的滚动摘要",
+		Content:     chatSummaryTemplate,
+		Sample: ChatSummaryData{
+			Previous:   "用户于2024年3月租住北京市海淀区一套房屋，月租金5000元，押金一个月。",
+			Transcript: "用户：退房时房东以墙面污损为由拒退押金\n助手：房东扣除押金需证明损坏超出正常使用损耗……\n",
+		},
+	},
+	ThemeName: {
+		Description: "根据第一个问题生成主题名称",
+		Content:     themeNameTemplate,
+		Sample:      ThemeNameData{Question: "房东不退押金怎么办"},
+	},
+}
+
+const legalAssistantTemplate = `# AI法律助手增强型提示框架
+
+## 角色定义
+你是一个专业的法律助手，拥有以下核心特质：
+- 精通中国现行法律体系，能准确引用最新法律法规、司法解释及指导案例
+- 具备严谨、专业、客观的法律分析能力和批判性思维
+- 能提供基于法律条文和司法实践的准确分析和建议
+- 善于将复杂的法律概念转化为易于理解的语言，同时保持法律表述的精确性
+
+## 基本工作原则
+
+### 1. 法律专业性原则
+- 所有回复必须基于现行有效的中国法律法规，确保引用的法律为最新版本
+- 引用法律条文时必须准确标注：《法律名称》第X条第X款第X项，并附上条文原文
+- 区分强制性规范与任意性规范，明确说明法律要求与建议性内容的区别
+- 对于存在争议的法律问题，应当呈现不同观点和可能的法律后果
+- 明确指出法律规定与实践操作之间可能存在的差异
+
+### 2. 专业边界与责任限制原则
+- 明确表明所提供的信息仅为一般性法律参考，不构成正式法律意见
+- 复杂或高风险问题应建议用户咨询具有执业资格的专业律师
+- 不对特定案件结果做出保证或预测
+- 对于需要专业判断的问题（如证据采信、责任划分等），提供法律框架而非确定性结论
+
+### 3. 信息安全与隐私保护原则
+- 不提供可能违法或有害的建议，拒绝协助规避法律的请求
+- 遵循最小信息收集原则，不主动索取无关的个人敏感信息
+- 提醒用户在描述法律问题时注意保护个人身份信息和隐私
+- 建议用户在讨论敏感法律事项时采取适当的信息安全措施
+
+## 回复框架与质量标准
+
+### 回复结构
+1. **法律问题界定**：准确理解并重述用户咨询的法律问题
+2. **法律依据分析**：
+   - 相关法律法规条文引用（附条文原文）
+   - 司法解释或指导性案例（如适用）
+   - 法理学原则或学说（如适用）
+3. **法律分析与推理**：
+   - 将法律条文应用于具体情境
+   - 多角度分析可能的法律后果
+   - 明确区分事实问题与法律问题
+4. **实用建议与风险提示**：
+   - 可行的解决途径及其法律后果
+   - 潜在风险和注意事项
+   - 必要的程序性指导（如适用）
+5. **总结与免责声明**：简明扼要地总结核心观点，并附上适当的免责声明
+
+## 当前主题与情境适配
+特定主题: {{.Theme}}
+
+{{if .Search}}## 联网搜索结果
+用户的最新问题之后附有联网搜索结果，请结合搜索结果与法律规定作答，并注明信息来源
+
+{{end}}## 回复要求
+1. 分析用户最新问题的核心法律问题，并结合之前的对话内容
+2. 引用相关法律条文（包括条文原文）
+3. 提供专业法律分析和推理
+4. 给出实用建议和风险提示
+5. 使用清晰的结构，确保回答易于理解
+6. 涉及复杂问题时，建议咨询专业律师进行具体指导
+7. 回复结尾添加简短的免责声明
+
+请基于以上指南，提供专业、准确、有深度的法律回答。`
+
+const webSearchTemplate = `现在是{{.Date}}，你是一位专业的AI助手，用户每次提问时会附上我为你提供的最新网络搜索结果。
+请根据这些搜索结果回答用户问题。注意以下几点：
+1. 如果搜索结果提供了足够信息，请直接回答问题，并引用搜索结果中的相关信息
+2. 如果搜索结果包含多个来源，请综合各个来源的信息进行回答
+3. 如果搜索结果不足以回答问题，请诚实告知用户，并尽可能提供相关信息
+4. 回答中应引用信息来源(例如网站名称)，以便用户验证
+5. 保持客观、准确，不要添加搜索结果中没有的信息`
+
+const legalDocTemplate = `请帮我生成一份专业的法律文件，要求如下：
+1. 文件类型：{{.DocType}}
+2. 文件标题：{{.Title}}
+3. 相关方信息：
+{{range .Parties}}   - {{.Type}}：{{.Name}}
+     详细信息：{{.Details}}
+{{end}}4. 合同基本信息：
+   - 合同标的：{{.Content.Subject}}
+   - 合同目的：{{.Content.Purpose}}
+   - 签订地点：{{.Content.Location}}
+   - 签订日期：{{.Content.SignDate}}
+5. 权利义务：
+   - 权利内容：{{.Content.Rights}}
+   - 义务内容：{{.Content.Obligations}}
+6. 履行相关：
+   - 开始日期：{{.Content.StartDate}}
+   - 结束日期：{{.Content.EndDate}}
+   - 履行方式：{{.Content.Performance}}
+7. 价格和支付：
+   - 价格/报酬：{{.Content.Price}}
+   - 支付方式：{{.Content.Payment}}
+8. 违约和争议解决：
+   - 违约责任：{{.Content.Breach}}
+   - 争议解决：{{.Content.Dispute}}
+9. 其他重要条款：
+   - 保密条款：{{.Content.Confidential}}
+   - 不可抗力：{{.Content.Force}}
+   - 终止条件：{{.Content.Termination}}
+   - 补充条款：{{.Content.Additional}}
+{{if .Additional}}10. 特殊要求：{{.Additional}}
+{{end}}
+请按照以下要求生成内容：
+1. 使用规范的法律文书格式
+2. 确保条款的完整性和专业性
+3. 使用清晰的条款编号和层次结构
+4. 语言表述准确、严谨
+`
+
+const complaintTemplate = `请帮我生成一份专业的起诉状，要求如下：
+1. 基本信息：
+   - 受理法院：{{.Content.Court}}
+2. 当事人信息：
+   - 原告信息：
+     姓名：{{.Content.Plaintiff.Name}}
+     详细信息：{{.Content.Plaintiff.Details}}
+   - 被告信息：
+     姓名：{{.Content.Defendant.Name}}
+     详细信息：{{.Content.Defendant.Details}}
+3. 诉讼请求：
+{{range $i, $v := .Content.Claims}}   {{inc $i}}. {{$v}}
+{{end}}4. 事实与理由：
+{{.Content.Facts}}
+5. 证据列表：
+{{range $i, $v := .Content.Evidence}}   {{inc $i}}. {{$v}}
+{{end}}6. 法律依据：
+{{range $i, $v := .Content.LawBasis}}   {{inc $i}}. {{$v}}
+{{end}}7. 附件清单：
+{{range $i, $v := .Content.Attachments}}   {{inc $i}}. {{$v}}
+{{end}}
+请按照以下要求生成起诉状：
+1. 使用规范的起诉状格式
+2. 确保文书格式符合法院要求
+3. 语言表述准确、严谨
+4. 包含必要的落款和日期
+`
+
+const legalOpinionTemplate = `请帮我生成一份专业的法律意见书，要求如下：
+1. 案件背景：
+{{.Content.Background}}
+2. 需要解决的法律问题：
+{{range $i, $v := .Content.Issues}}   {{inc $i}}. {{$v}}
+{{end}}3. 法律分析：
+{{.Content.Analysis}}
+4. 法律风险：
+{{range $i, $v := .Content.Risks}}   {{inc $i}}. {{$v}}
+{{end}}5. 法律建议：
+{{range $i, $v := .Content.Suggestions}}   {{inc $i}}. {{$v}}
+{{end}}6. 法律依据：
+{{range $i, $v := .Content.References}}   {{inc $i}}. {{$v}}
+{{end}}
+请按照以下要求生成法律意见书：
+1. 使用规范的法律意见书格式
+2. 分析论述要客观、专业
+3. 建议要具体、可操作
+4. 引用法律依据要准确
+`
+
+const legalAnalysisTemplate = `请对以下法律文件进行专业分析，要求如下：
+
+1. 文件基本信息提取：
+   - 文件类型和性质
+   - 文件签署日期和生效时间
+   - 涉及的主体方
+   - 文件的主要目的
+
+2. 关键信息提取：
+   - 重要条款内容
+   - 关键日期节点
+   - 金额和支付条件
+   - 权利义务关系
+
+3. 法律术语解释：
+   - 识别文件中的专业法律术语
+   - 提供通俗易懂的解释
+   - 说明术语在文件中的具体含义和作用
+
+4. 条款分类分析：
+   - 主要条款分类（如基本条款、履行条款、违约条款等）
+   - 各类条款的主要内容概述
+   - 条款之间的关联性分析
+
+5. 风险提示：
+   - 潜在的法律风险点
+   - 条款中的不明确或争议之处
+   - 建议重点关注的内容
+
+待分析的法律文件内容如下：
+{{.Content}}
+
+请按照以下格式输出分析结果：
+1. 使用清晰的层级结构
+2. 重要内容需要突出显示
+3. 专业术语解释要通俗易懂
+4. 风险提示要具体明确
+`
+
+const fileQATemplate = `请根据用户本人上传的文件片段回答问题。片段按相关度排序，每个片段前标有编号、文件名、文件ID和在文件中的位置。
+
+{{range .Excerpts}}[{{.Index}}] 《{{.Filename}}》（文件ID：{{.FileID}}，位置：第{{.Offset}}字起）
+{{.Content}}
+
+{{end}}用户的问题：{{.Question}}
+
+回答要求：
+1. 只依据上述片段回答，片段中没有的信息不要推测，如果片段不足以回答，请明确说明
+2. 每个结论后用 [编号] 标注所依据的片段，涉及多份文件时按文件分别说明
+3. 需要结合法律规定分析时，引用法律条文要准确，并与文件内容区分开
+4. 使用清晰的结构，语言准确、简洁
+`
+
+const chatSummaryTemplate = `你负责为一次法律咨询维护对话摘要。请将“新增对话”中的信息合并进“已有摘要”，输出更新后的完整摘要。
+要求：
+1. 保留当事人身份、时间、地点、金额、合同条款、证据、争议焦点等关键事实，以及已经给出的法律意见和结论
+2. 删除寒暄和重复内容，不要编造对话中没有的信息
+3. 使用第三人称陈述，不超过800字，直接输出摘要正文
+
+已有摘要：
+{{if .Previous}}{{.Previous}}{{else}}（无）{{end}}
+
+新增对话：
+{{.Transcript}}`
+
+const themeNameTemplate = `请为以下法律咨询问题生成一个简短的主题名称（不超过15个字），主题名称应该概括问题的核心法律领域和关键事项。
+    
+问题内容：
+"""
+{{.Question}}
+"""
+
+请只返回主题名称，不需要任何解释或额外内容。`
@@ -0,0 +1,280 @@
+package prompt_service
+
+import (
+	"Programming-Demo/core/gin/dbs"
+	"Programming-Demo/internal/app/prompt/prompt_dto"
+	"Programming-Demo/internal/app/prompt/prompt_entity"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const CacheTTL = time.Minute // 生效版本的缓存时间，多实例部署时修改最多延迟这么久生效
+
+var ErrUnknownPrompt = errors.New("未知的提示词名称")
+
+// Rendered 渲染后的提示词及其来源版本
+type Rendered struct {
+	Name    string
+	Version int // 0 表示内置默认模板
+	Text    string
+}
+
+// Label 记录到生成结果中的版本标识，如 legal_assistant@3
+func (r Rendered) Label() string {
+	return fmt.Sprintf("%s@%d", r.Name, r.Version)
+}
+
+type cachedTemplate struct {
+	tmpl     *template.Template
+	version  int
+	loadedAt time.Time
+}
+
+var (
+	cache    = make(map[string]cachedTemplate)
+	cacheMux sync.RWMutex
+)
+
+// Render 使用当前生效的版本渲染提示词
+func Render(ctx context.Context, name string, data interface{}) (Rendered, error) {
+	tmpl, version, err := activeTemplate(ctx, name)
+	if err != nil {
+		return Rendered{}, err
+	}
+	text, err := execute(tmpl, data)
+	if err != nil {
+		return Rendered{}, fmt.Errorf("渲染提示词 %s@%d 失败: %w", name, version, err)
+	}
+	return Rendered{Name: name, Version: version, Text: text}, nil
+}
+
+// 读取生效版本，数据库不可用时退回内置默认模板
+func activeTemplate(ctx context.Context, name string) (*template.Template, int, error) {
+	builtin, ok := builtinPrompts[name]
+	if !ok {
+		return nil, 0, ErrUnknownPrompt
+	}
+
+	cacheMux.RLock()
+	cached, ok := cache[name]
+	cacheMux.RUnlock()
+	if ok && time.Since(cached.loadedAt) < CacheTTL {
+		return cached.tmpl, cached.version, nil
+	}
+
+	content, version := builtin.Content, 0
+	// 未连接数据库时（如离线评测）直接使用内置模板
+	if dbs.DB != nil {
+		var active prompt_entity.PromptTemplate
+		err := dbs.DB.WithContext(ctx).Where("name = ? AND active = ?", name, true).First(&active).Error
+		switch {
+		case err == nil:
+			content, version = active.Content, active.Version
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			log.Printf("读取提示词 %s 失败，使用内置模板: %v", name, err)
+		}
+	}
+
+	tmpl, err := parse(name, content)
+	if err != nil {
+		// 保存时已校验过，这里只可能是数据库被直接修改
+		log.Printf("提示词 %s@%d 解析失败，使用内置模板: %v", name, version, err)
+		tmpl, version = template.Must(parse(name, builtin.Content)), 0
+	}
+
+	cacheMux.Lock()
+	cache[name] = cachedTemplate{tmpl: tmpl, version: version, loadedAt: time.Now()}
+	cacheMux.Unlock()
+	return tmpl, version, nil
+}
+
+func invalidate(name string) {
+	cacheMux.Lock()
+	delete(cache, name)
+	cacheMux.Unlock()
+}
+
+func parse(name string, content string) (*template.Template, error) {
+	return template.New(name).Funcs(funcMap).Option("missingkey=zero").Parse(content)
+}
+
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Preview 使用示例数据渲染模板，content 为空时渲染当前生效的版本，data 为空时使用内置示例数据
+func Preview(ctx context.Context, name string, content string, data map[string]interface{}) (Rendered, error) {
+	builtin, ok := builtinPrompts[name]
+	if !ok {
+		return Rendered{}, ErrUnknownPrompt
+	}
+	var sample interface{} = builtin.Sample
+	if data != nil {
+		sample = data
+	}
+	if content == "" {
+		return Render(ctx, name, sample)
+	}
+
+	tmpl, err := parse(name, content)
+	if err != nil {
+		return Rendered{}, fmt.Errorf("模板语法错误: %w", err)
+	}
+	text, err := execute(tmpl, sample)
+	if err != nil {
+		return Rendered{}, fmt.Errorf("模板渲染失败: %w", err)
+	}
+	return Rendered{Name: name, Text: text}, nil
+}
+
+// ListPrompts 列出全部内置提示词及其生效版本
+func ListPrompts(ctx context.Context) ([]prompt_dto.PromptSummary, error) {
+	var rows []struct {
+		Name    string
+		Latest  int
+		Current int
+	}
+	err := dbs.DB.WithContext(ctx).Model(&prompt_entity.PromptTemplate{}).
+		Select("name, MAX(version) AS latest, MAX(CASE WHEN active THEN version ELSE 0 END) AS current").
+		Group("name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string][2]int, len(rows))
+	for _, r := range rows {
+		versions[r.Name] = [2]int{r.Current, r.Latest}
+	}
+
+	summaries := make([]prompt_dto.PromptSummary, 0, len(builtinPrompts))
+	for name, builtin := range builtinPrompts {
+		v := versions[name]
+		summaries = append(summaries, prompt_dto.PromptSummary{
+			Name:          name,
+			Description:   builtin.Description,
+			ActiveVersion: v[0],
+			LatestVersion: v[1],
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries, nil
+}
+
+// ListVersions 列出提示词的全部版本，按版本号倒序，最后附上内置默认模板（版本 0）
+func ListVersions(ctx context.Context, name string) ([]prompt_entity.PromptTemplate, error) {
+	builtin, ok := builtinPrompts[name]
+	if !ok {
+		return nil, ErrUnknownPrompt
+	}
+	var versions []prompt_entity.PromptTemplate
+	if err := dbs.DB.WithContext(ctx).Where("name = ?", name).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	active := false
+	for _, v := range versions {
+		active = active || v.Active
+	}
+	versions = append(versions, prompt_entity.PromptTemplate{
+		Name:        name,
+		Content:     builtin.Content,
+		Description: "内置默认模板",
+		Active:      !active,
+	})
+	return versions, nil
+}
+
+// CreateVersion 保存新版本，保存前用示例数据试渲染一次
+func CreateVersion(ctx context.Context, userID uint, req prompt_dto.CreatePromptReq) (*prompt_entity.PromptTemplate, error) {
+	if _, err := Preview(ctx, req.Name, req.Content, nil); err != nil {
+		return nil, err
+	}
+
+	version := &prompt_entity.PromptTemplate{
+		Name:        req.Name,
+		Content:     req.Content,
+		Description: req.Description,
+		Active:      req.Activate,
+		CreatedBy:   userID,
+	}
+	err := dbs.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&prompt_entity.PromptTemplate{}).
+			Where("name = ?", req.Name).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		version.Version = latest + 1
+		if req.Activate {
+			if err := deactivate(tx, req.Name); err != nil {
+				return err
+			}
+		}
+		return tx.Create(version).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidate(req.Name)
+	return version, nil
+}
+
+// Rollback 切换生效版本，version 为 0 时恢复内置默认模板
+func Rollback(ctx context.Context, name string, version int) error {
+	if _, ok := builtinPrompts[name]; !ok {
+		return ErrUnknownPrompt
+	}
+	err := dbs.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := deactivate(tx, name); err != nil {
+			return err
+		}
+		if version == 0 {
+			return nil
+		}
+		result := tx.Model(&prompt_entity.PromptTemplate{}).
+			Where("name = ? AND version = ?", name, version).
+			Update("active", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("提示词 %s 没有版本 %d", name, version)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	invalidate(name)
+	return nil
+}
+
+// DeleteVersion 删除一个未生效的版本
+func DeleteVersion(ctx context.Context, name string, version int) error {
+	var target prompt_entity.PromptTemplate
+	if err := dbs.DB.WithContext(ctx).Where("name = ? AND version = ?", name, version).First(&target).Error; err != nil {
+		return err
+	}
+	if target.Active {
+		return fmt.Errorf("不能删除生效中的版本，请先回滚到其他版本")
+	}
+	return dbs.DB.WithContext(ctx).Delete(&target).Error
+}
+
+func deactivate(tx *gorm.DB, name string) error {
+	return tx.Model(&prompt_entity.PromptTemplate{}).
+		Where("name = ? AND active = ?", name, true).
+		Update("active", false).Error
+}
@@ -1,16 +1,15 @@
 package template_handler
 
 import (
-	"Programming-Demo/core/client"
 	"Programming-Demo/core/gin/dbs"
 	"Programming-Demo/internal/app/template/template_dto"
 	"Programming-Demo/internal/app/template/template_entity"
+	"Programming-Demo/pkg/utils/ai"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/northes/go-moonshot"
 	"net/http"
 	"strings"
 )
@@ -299,7 +298,7 @@ func CreateTemplateHandler(c *gin.Context) {
 	docTypeID := c.Query("docTypeId")
 
 	// 调用服务创建模板
-	template, err := CreateTemplate(req, subCategoryID, docTypeID)
+	template, err := CreateTemplate(c.Request.Context(), req, subCategoryID, docTypeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -506,7 +505,7 @@ func convertTemplateToMarkdown(content template_entity.TemplateContent, template
 
 // 初始化系统模板
 func InitializeTemplatesHandler(c *gin.Context) {
-	count, err := InitializeTemplates()
+	count, err := InitializeTemplates(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -518,7 +517,7 @@ func InitializeTemplatesHandler(c *gin.Context) {
 }
 
 // 初始化系统默认法律模板
-func InitializeTemplates() (int, error) {
+func InitializeTemplates(ctx context.Context) (int, error) {
 	successCount := 0
 
 	// 定义不同类型模板的环境
@@ -578,7 +577,7 @@ func InitializeTemplates() (int, error) {
 				}
 
 				// 使用现有函数创建模板
-				_, err := CreateTemplate(templateReq, subCategory.ID, docType.ID)
+				_, err := CreateTemplate(ctx, templateReq, subCategory.ID, docType.ID)
 				if err != nil {
 					return successCount, fmt.Errorf("初始化模板 %s 失败: %v", docType.Name, err)
 				}
@@ -593,10 +592,10 @@ func InitializeTemplates() (int, error) {
 }
 
 // 创建新的法律文档模板
-func CreateTemplate(req template_dto.CreateTemplatereq, subCategoryID, docTypeID string) (*template_entity.LegalTemplate, error) {
-	// 确保 MoonClient 已初始化
-	if client.MoonClient == nil || client.MoonClient.GetClient() == nil {
-		return nil, errors.New("Moonshot 客户端未初始化")
+func CreateTemplate(ctx context.Context, req template_dto.CreateTemplatereq, subCategoryID, docTypeID string) (*template_entity.LegalTemplate, error) {
+	// 确保默认模型可用
+	if !ai.IsModelEnabled(ai.DefaultModel()) {
+		return nil, errors.New("默认模型未配置或未启用")
 	}
 
 	// 验证分类是否存在
@@ -619,7 +618,7 @@ func CreateTemplate(req template_dto.CreateTemplatereq, subCategoryID, docTypeID
 	}
 
 	// 调用 AI 生成模板内容
-	content, err := generateTemplateFromMoonshot(req.Type, req.Name, req.Environment, categoryName, subCategoryName, docTypeName, metadata)
+	content, err := generateTemplateFromMoonshot(ctx, req.Type, req.Name, req.Environment, categoryName, subCategoryName, docTypeName, metadata)
 	if err != nil {
 		return nil, err
 	}
@@ -768,10 +767,7 @@ func normalizeTemplateContent(rawContent map[string]interface{}, templateType st
 }
 
 // 生成模板内容
-func generateTemplateFromMoonshot(templateType, name, environment, categoryName, subCategoryName, docTypeName string, metadata template_entity.TemplateMetadata) (json.RawMessage, error) {
-	// 获取 Moonshot 客户端
-	chatClient := client.MoonClient.GetClient().Chat()
-
+func generateTemplateFromMoonshot(ctx context.Context, templateType, name, environment, categoryName, subCategoryName, docTypeName string, metadata template_entity.TemplateMetadata) (json.RawMessage, error) {
 	// 构造 Prompt
 	var prompt string
 	switch templateType {
@@ -882,54 +878,16 @@ func generateTemplateFromMoonshot(templateType, name, environment, categoryName,
 		}
 	}
 
-	// 生成请求
-	chatReq := moonshot.ChatCompletionsRequest{
-		Model: moonshot.ModelMoonshotV1Auto,
-		Messages: []*moonshot.ChatCompletionsMessage{
-			{Role: moonshot.RoleSystem, Content: "你是一个专业的法律 AI 助手，擅长生成法律文档模板。请严格按照JSON格式输出。"},
-			{Role: moonshot.RoleUser, Content: prompt},
-		},
-		MaxTokens:   4096,
-		Temperature: 0.2,
-	}
-
-	// 发送请求
-	resp, err := chatClient.Completions(context.Background(), &chatReq)
-	if err != nil {
-		return nil, fmt.Errorf("Moonshot API 调用失败: %v", err)
-	}
-
-	// 解析 AI 响应
-	if len(resp.Choices) == 0 {
-		return nil, errors.New("Moonshot 没有返回任何结果")
+	// 生成请求，使用默认模型
+	messages := []ai.Message{
+		{Role: ai.RoleSystem, Content: "你是一个专业的法律 AI 助手，擅长生成法律文档模板。请严格按照JSON格式输出。"},
+		{Role: ai.RoleUser, Content: prompt},
 	}
 
-	// 解析返回的 JSON
-	aiResponse := resp.Choices[0].Message.Content
-
-	// 从 AI 返回的文本中提取 JSON 部分
+	// 以 JSON 模式发送请求，输出无法解析时由模型修正后重试
 	var jsonContent map[string]interface{}
-
-	// 尝试多种方式解析JSON
-	err = json.Unmarshal([]byte(aiResponse), &jsonContent)
-	if err != nil {
-		// 尝试查找 JSON 部分
-		jsonStart := strings.Index(aiResponse, "{")
-		jsonEnd := strings.LastIndex(aiResponse, "}")
-
-		if jsonStart >= 0 && jsonEnd > jsonStart {
-			jsonText := aiResponse[jsonStart : jsonEnd+1]
-			err = json.Unmarshal([]byte(jsonText), &jsonContent)
-			if err != nil {
-				// 记录原始响应以便调试
-				fmt.Printf("AI 原始响应: %s\n", aiResponse)
-				fmt.Printf("尝试提取的JSON: %s\n", jsonText)
-				return nil, fmt.Errorf("无法解析 AI 返回的 JSON: %v", err)
-			}
-		} else {
-			fmt.Printf("AI 原始响应: %s\n", aiResponse)
-			return nil, fmt.Errorf("AI 返回内容不是有效的 JSON 格式")
-		}
+	if _, err := ai.CompleteJSON(ctx, ai.DefaultModel(), messages, &jsonContent, ai.WithMaxTokens(4096), ai.WithTemperature(0.2)); err != nil {
+		return nil, fmt.Errorf("%s API 调用失败: %v", ai.DefaultModel(), err)
 	}
 
 	// 规范化模板内容
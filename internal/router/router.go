@@ -5,9 +5,11 @@ import (
 	"Programming-Demo/internal/app/File/file_handler"
 	"Programming-Demo/internal/app/ai/ai_handler"
 	"Programming-Demo/internal/app/file_search/search_handler"
+	"Programming-Demo/internal/app/prompt/prompt_handler"
 	"Programming-Demo/internal/app/story/story_handler"
 	"Programming-Demo/internal/app/template/template_handler"
 	"Programming-Demo/internal/app/user/user_handler"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,11 +29,33 @@ func GenerateRouters(r *gin.Engine) *gin.Engine {
 		aiGroup.POST("/complain", ai_handler.GenerateComplaint)
 		aiGroup.POST("/opinion", ai_handler.GenerateLegalOpinion)
 		aiGroup.POST("/chat", ai_handler.ChatWithAi)
+		aiGroup.POST("/chat/regenerate", ai_handler.RegenerateReply)
+		aiGroup.POST("/chat/edit", ai_handler.EditMessage)
+		aiGroup.POST("/chat/branch", ai_handler.SwitchBranch)
 		aiGroup.GET("/history", ai_handler.GetChatHistory)
+		aiGroup.GET("/history/search", ai_handler.SearchChatHistory)
 		aiGroup.GET("/theme", ai_handler.GetChatThemes)
+		aiGroup.PUT("/theme", ai_handler.RenameChatTheme)
+		aiGroup.PUT("/theme/pin", ai_handler.PinChatTheme)
+		aiGroup.PUT("/theme/archive", ai_handler.ArchiveChatTheme)
+		aiGroup.PUT("/theme/tags", ai_handler.SetChatThemeTags)
+		aiGroup.GET("/theme/tags", ai_handler.GetChatThemeTags)
+		aiGroup.GET("/theme/:id/export", ai_handler.ExportChatTheme)
+		aiGroup.POST("/theme/:id/share", ai_handler.CreateChatShare)
+		aiGroup.GET("/shares", ai_handler.ListChatShares)
+		aiGroup.DELETE("/shares/:id", ai_handler.RevokeChatShare)
 		aiGroup.DELETE("/delete", ai_handler.DeleteChatTheme)
+		aiGroup.POST("/feedback", ai_handler.SubmitFeedback)
+		aiGroup.POST("/ask-my-files", ai_handler.AskMyFiles)
 		aiGroup.GET("/search", ai_handler.AiSearch)
 		aiGroup.POST("/doc/more", ai_handler.GenerateLegalDocBetter)
+		aiGroup.GET("/models", ai_handler.ListModels)
+	}
+	// 对话分享链接，不需要登录，按 IP 限流
+	shareGroup := r.Group("/api/share", web.RateLimitMiddleware(30, time.Minute))
+	{
+		shareGroup.GET("/:token", ai_handler.GetSharedChat)
+		shareGroup.GET("/:token/export", ai_handler.ExportSharedChat)
 	}
 	// 管理员相关路由
 	adminGroup := r.Group("/api/admin", web.JWTAuthMiddleware(), web.AdminAuthMiddleware())
@@ -42,6 +66,19 @@ func GenerateRouters(r *gin.Engine) *gin.Engine {
 		adminGroup.POST("/audit/:id", file_handler.AuditFile)
 		adminGroup.POST("/audit", file_handler.ListPendingFiles)
 		adminGroup.GET("/audit/:id/get", file_handler.GetPendingFileHandler)
+		// 提示词模板管理
+		adminGroup.GET("/prompts", prompt_handler.ListPrompts)
+		adminGroup.POST("/prompts", prompt_handler.CreatePrompt)
+		adminGroup.POST("/prompts/preview", prompt_handler.PreviewPrompt)
+		adminGroup.GET("/prompts/:name", prompt_handler.ListPromptVersions)
+		adminGroup.POST("/prompts/:name/rollback", prompt_handler.RollbackPrompt)
+		adminGroup.DELETE("/prompts/:name/:version", prompt_handler.DeletePromptVersion)
+		// 法律咨询语义缓存
+		adminGroup.GET("/ai/cache", ai_handler.GetAnswerCacheStats)
+		adminGroup.DELETE("/ai/cache", ai_handler.ClearAnswerCache)
+		// 回答评价统计
+		adminGroup.GET("/ai/feedback", ai_handler.GetFeedbackStats)
+		adminGroup.GET("/ai/feedback/export", ai_handler.ExportLowRatedFeedback)
 	}
 	fileGroup := r.Group("/api/file", web.JWTAuthMiddleware())
 	{
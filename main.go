@@ -1,10 +1,19 @@
 package main
 
 import (
+	"Programming-Demo/cmd"
 	"Programming-Demo/cmd/server"
+	"os"
 )
 
 func main() {
+	// 带子命令（如 eval）时交给 cobra 处理，否则直接启动服务
+	if len(os.Args) > 1 {
+		if err := cmd.Execute(); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
 	server.SetUp()
 	server.Run()
 }
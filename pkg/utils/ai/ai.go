@@ -1,17 +1,15 @@
 package ai
 
 import (
+	"Programming-Demo/config"
 	bochalient "Programming-Demo/core/Bocha_client"
-	"Programming-Demo/core/client"
 	"Programming-Demo/core/milvus"
-	"Programming-Demo/internal/app/ai/ai_entity"
 	"Programming-Demo/pkg/aliyun"
 	"Programming-Demo/pkg/utils/bocha"
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
-	"github.com/northes/go-moonshot"
-	"io"
+	"strconv"
 	"time"
 )
 
@@ -22,17 +20,81 @@ type Document struct {
 	Score   float32
 }
 
-// GenerateEmbedding 生成文本向量
-func GenerateEmbedding(text string) ([]float64, error) {
+// GenerateEmbedding 生成文本向量，配置了 EmbeddingModel 时使用该模型，否则使用阿里云
+func GenerateEmbedding(ctx context.Context, text string) ([]float64, error) {
+	name := config.GetConfig().EmbeddingModel
+	return throughCassette(digest("embedding", name, text), func() ([]float64, error) {
+		return generateEmbedding(ctx, name, text)
+	})
+}
+
+func generateEmbedding(ctx context.Context, name string, text string) ([]float64, error) {
+	if name != "" {
+		vectors, err := Embed(ctx, name, []string{text})
+		if err != nil {
+			return nil, err
+		}
+		return vectors[0], nil
+	}
+	// 阿里云 SDK 不支持 context，调用前先检查请求是否已取消
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	time.Sleep(50 * time.Millisecond)
 	vectors := aliyun.NplApi(text)
 	return vectors, nil
 }
 
+// 每次向量化请求最多包含的文本数
+const embeddingBatchSize = 10
+
+// GenerateEmbeddings 批量生成文本向量，返回顺序与 texts 一致。配置了 EmbeddingModel 时每个请求包含多条文本，
+// 阿里云接口只能逐条生成
+func GenerateEmbeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	name := config.GetConfig().EmbeddingModel
+	vectors := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch := texts[start:end]
+		key := digest(append([]string{"embeddings", name}, batch...)...)
+		result, err := throughCassette(key, func() ([][]float64, error) {
+			if name != "" {
+				return Embed(ctx, name, batch)
+			}
+			result := make([][]float64, 0, len(batch))
+			for _, text := range batch {
+				vector, err := generateEmbedding(ctx, name, text)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, vector)
+			}
+			return result, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(result) != len(batch) {
+			return nil, fmt.Errorf("向量数量(%d)与输入数量(%d)不匹配", len(result), len(batch))
+		}
+		vectors = append(vectors, result...)
+	}
+	return vectors, nil
+}
+
 // SearchSimilarDocuments 搜索相似文档
-func SearchSimilarDocuments(query string, topK int) ([]Document, error) {
+func SearchSimilarDocuments(ctx context.Context, query string, topK int) ([]Document, error) {
+	return throughCassette(digest("milvus", query, strconv.Itoa(topK)), func() ([]Document, error) {
+		return searchSimilarDocuments(ctx, query, topK)
+	})
+}
+
+func searchSimilarDocuments(ctx context.Context, query string, topK int) ([]Document, error) {
 	// 生成查询的向量嵌入
-	embedding, err := GenerateEmbedding(query)
+	embedding, err := GenerateEmbedding(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("生成查询向量失败: %v", err)
 	}
@@ -44,7 +106,7 @@ func SearchSimilarDocuments(query string, topK int) ([]Document, error) {
 	}
 
 	// 搜索相似向量，同时获取内容
-	ids, scores, contents, err := milvus.SearchVectors(vector, topK)
+	ids, scores, contents, err := milvus.SearchVectors(ctx, vector, topK)
 	if err != nil {
 		return nil, fmt.Errorf("搜索相似向量失败: %v", err)
 	}
@@ -63,9 +125,15 @@ func SearchSimilarDocuments(query string, topK int) ([]Document, error) {
 }
 
 // SearchSimilarDocumentsWithParam 搜索相似文档
-func SearchSimilarDocumentsWithParam(query string, topK int) ([]Document, error) {
+func SearchSimilarDocumentsWithParam(ctx context.Context, query string, topK int) ([]Document, error) {
+	return throughCassette(digest("milvus_param", query, strconv.Itoa(topK)), func() ([]Document, error) {
+		return searchSimilarDocumentsWithParam(ctx, query, topK)
+	})
+}
+
+func searchSimilarDocumentsWithParam(ctx context.Context, query string, topK int) ([]Document, error) {
 	// 生成查询的向量嵌入
-	embedding, err := GenerateEmbedding(query)
+	embedding, err := GenerateEmbedding(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("生成查询向量失败: %v", err)
 	}
@@ -85,10 +153,10 @@ func SearchSimilarDocumentsWithParam(query string, topK int) ([]Document, error)
 	}
 
 	// 搜索相似向量，��用增强版本的搜索函数
-	ids, scores, contents, err := milvus.SearchVectorsWithParams(vector, topK*2, searchParams)
+	ids, scores, contents, err := milvus.SearchVectorsWithParams(ctx, vector, topK*2, searchParams)
 	if err != nil {
 		// 回退到基本搜索
-		ids, scores, contents, err = milvus.SearchVectors(vector, topK)
+		ids, scores, contents, err = milvus.SearchVectors(ctx, vector, topK)
 		if err != nil {
 			return nil, fmt.Errorf("搜索相似向量失败: %v", err)
 		}
@@ -107,37 +175,12 @@ func SearchSimilarDocumentsWithParam(query string, topK int) ([]Document, error)
 	return docs, nil
 }
 
-func GetAIResp(m string) (string, int) {
-	resp, err := client.MoonClient.GetClient().Chat().CompletionsStream(context.Background(), &moonshot.ChatCompletionsRequest{
-		Model: moonshot.ModelMoonshotV18K,
-		Messages: []*moonshot.ChatCompletionsMessage{
-			{
-				Role:    moonshot.RoleUser,
-				Content: m,
-			},
-		},
-		Temperature: 0.3,
-		Stream:      true,
-	})
-	var message string
-	if err != nil {
-		return "moonshot chat failed", 500
-	} else {
-		for receive := range resp.Receive() {
-			msg, err1 := receive.GetMessage()
-			if err1 != nil {
-				if errors.Is(err1, io.EOF) {
-					return message, 200
-				}
-				return err1.Error(), 500
-			}
-			message = message + msg.Content
-		}
-	}
-	return err.Error(), 500
+// GetAIResp 使用默认模型完成一次对话
+func GetAIResp(ctx context.Context, m string) (string, int) {
+	return ChatWithModel(ctx, DefaultModel(), m)
 }
 
-func WebBaseSearch(content string) (error, string) {
+func WebBaseSearch(ctx context.Context, content string) (error, string) {
 	// 检查博查客户端是否已初始化
 	if bochalient.BochaClient == nil {
 		return fmt.Errorf("联网搜索功能未配置，请设置BOCHA_API_KEY环境变量"), ""
@@ -152,8 +195,7 @@ func WebBaseSearch(content string) (error, string) {
 		Page:      1,
 	}
 
-	// 使用封装的bochalient.BochaClient获取客户端并执行搜索
-	searchResult, err := bochalient.BochaClient.GetClient().Search(searchReq)
+	searchResult, err := BochaSearch(ctx, searchReq)
 	if err != nil {
 		return err, ""
 	}
@@ -168,88 +210,21 @@ func WebBaseSearch(content string) (error, string) {
 	return nil, searchInfo
 }
 
-// GenerateWebSearchPrompt 生成增强型法律助手提示
-func GenerateWebSearchPrompt(theme string, histories []ai_entity.ChatHistory, currentQuestion string, searchInfo string) string {
-	basePrompt := `# AI法律助手增强型提示框架
-
-## 角色定义
-你是一个专业的法律助手，拥有以下核心特质：
-- 精通中国现行法律体系，能准确引用最新法律法规、司法解释及指导案例
-- 具备严谨、专业、客观的法律分析能力和批判性思维
-- 能提供基于法律条文和司法实践的准确分析和建议
-- 善于将复杂的法律概念转化为易于理解的语言，同时保持法律表述的精确性
-
-## 基本工作原则
-
-### 1. 法律专业性原则
-- 所有回复必须基于现行有效的中国法律法规，确保引用的法律为最新版本
-- 引用法律条文时必须准确标注：《法律名称》第X条第X款第X项，并附上条文原文
-- 区分强制性规范与任意性规范，明确说明法律要求与建议性内容的区别
-- 对于存在争议的法律问题，应当呈现不同观点和可能的法律后果
-- 明确指出法律规定与实践操作之间可能存在的差异
-
-### 2. 专业边界与责任限制原则
-- 明确表明所提供的信息仅为一般性法律参考，不构成正式法律意见
-- 复杂或高风险问题应建议用户咨询具有执业资格的专业律师
-- 不对特定案件结果做出保证或预测
-- 对于需要专业判断的问题（如证据采信、责任划分等），提供法律框架而非确定性结论
-
-### 3. 信息安全与隐私保护原则
-- 不提供可能违法或有害的建议，拒绝协助规避法律的请求
-- 遵循最小信息收集原则，不主动索取无关的个人敏感信息
-- 提醒用户在描述法律问题时注意保护个人身份信息和隐私
-- 建议用户在讨论敏感法律事项时采取适当的信息安全措施
-
-## 回复框架与质量标准
-
-### 回复结构
-1. **法律问题界定**：准确理解并重述用户咨询的法律问题
-2. **法律依据分析**：
-   - 相关法律法规条文引用（附条文原文）
-   - 司法解释或指导性案例（如适用）
-   - 法理学原则或学说（如适用）
-3. **法律分析与推理**：
-   - 将法律条文应用于具体情境
-   - 多角度分析可能的法律后果
-   - 明确区分事实问题与法律问题
-4. **实用建议与风险提示**：
-   - 可行的解决途径及其法律后果
-   - 潜在风险和注意事项
-   - 必要的程序性指导（如适用）
-5. **总结与免责声明**：简明扼要地总结核心观点，并附上适当的免责声明
-
-## 当前主题与情境适配
-特定主题: ` + theme
-
-	// 添加对话历史
-	basePrompt += "\n\n## 对话历史："
-	for _, history := range histories {
-		role := "用户"
-		if history.Role == "assistant" {
-			role = "法律助手"
-		}
-		basePrompt += fmt.Sprintf("\n%s: %s", role, history.Content)
-	}
-
-	// 添加当前问题
-	basePrompt += fmt.Sprintf("\n\n## 用户最新问题：\n%s", currentQuestion)
-
-	// 添加当前问题
-	basePrompt += fmt.Sprintf("\n\n## 联网搜索结果：\n%s", searchInfo)
-
-	// 添加回复指南
-	basePrompt += `
-
-## 回复要求
-1. 分析用户问题的核心法律问题
-2. 引用相关法律条文（包括条文原文）
-3. 提供专业法律分析和推理
-4. 给出实用建议和风险提示
-5. 使用清晰的结构，确保回答易于理解
-6. 涉及复杂问题时，建议咨询专业律师进行具体指导
-7. 回复结尾添加简短的免责声明
+// BochaSearch 调用博查搜索，返回原始结果
+func BochaSearch(ctx context.Context, req bocha.SearchRequest) (string, error) {
+	data, _ := json.Marshal(req)
+	return throughCassette(digest("bocha", string(data)), func() (string, error) {
+		// 使用封装的bochalient.BochaClient获取客户端并执行搜索
+		return bochalient.BochaClient.GetClient().Search(ctx, req)
+	})
+}
 
-请基于以上指南，提供专业、准确、有深度的法律回答。`
+// AppendSearchInfo 将联网搜索结果附加到用户问题之后
+func AppendSearchInfo(question string, searchInfo string) string {
+	return fmt.Sprintf("%s\n\n## 联网搜索结果：\n%s", question, searchInfo)
+}
 
-	return basePrompt
+// AppendAttachments 将用户附带文件的内容附在问题之后
+func AppendAttachments(question string, attachments string) string {
+	return fmt.Sprintf("%s\n\n## 用户附带的文件：\n%s", question, attachments)
 }
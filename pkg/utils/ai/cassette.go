@@ -0,0 +1,270 @@
+package ai
+
+import (
+	"Programming-Demo/config"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// ReplayProvider 回放录制结果的提供方名称
+const ReplayProvider = "replay"
+
+// ErrNotRecorded 回放模式下调用没有对应的录制结果
+var ErrNotRecorded = errors.New("没有录制该调用的结果，请重新录制")
+
+// Cassette 录制的模型回复和外部接口结果。
+// 录制模式下真实调用的结果会写入 Cassette，回放模式下直接返回录制的结果，不访问网络，用于离线评测、CI 和本地开发
+type Cassette struct {
+	Models    []config.ModelConfig       `json:"models"`    // 录制时的模型配置（不含密钥）
+	Responses map[string]*ChatResponse   `json:"responses"` // 请求摘要 -> 模型回复
+	Calls     map[string]json.RawMessage `json:"calls"`     // 向量化、向量检索、联网搜索的参数摘要 -> 结果
+
+	mu     sync.Mutex
+	saveMu sync.Mutex // 保证后写入文件的总是更新的内容
+	path   string     // 不为空时每录制一条结果就写入该文件
+}
+
+// 提示词中的当天日期（如联网搜索的系统提示词），计算请求摘要时替换为固定值，使录制结果在之后的日期仍能回放
+var datePattern = regexp.MustCompile(`\d{4}年\d{1,2}月\d{1,2}日`)
+
+// 当前生效的 Cassette，启动时由 Record 或 Replay 设置
+var (
+	activeCassette *Cassette
+	replaying      bool
+)
+
+// NewCassette 创建空的 Cassette
+func NewCassette() *Cassette {
+	return &Cassette{Responses: make(map[string]*ChatResponse), Calls: make(map[string]json.RawMessage)}
+}
+
+// LoadCassette 读取录制文件
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := NewCassette()
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("录制文件格式错误: %v", err)
+	}
+	return c, nil
+}
+
+// Save 保存录制文件，先写入临时文件再替换，写入中途退出不会损坏已有的录制文件
+func (c *Cassette) Save(path string) error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// AutoSave 之后每录制一条结果就保存到 path，服务异常退出时已录制的结果不会丢失
+func (c *Cassette) AutoSave(path string) {
+	c.mu.Lock()
+	c.path = path
+	c.mu.Unlock()
+}
+
+// 录制了新的结果，设置了 AutoSave 时写入文件
+func (c *Cassette) recorded() {
+	c.mu.Lock()
+	path := c.path
+	c.mu.Unlock()
+	if path == "" {
+		return
+	}
+	if err := c.Save(path); err != nil {
+		log.Printf("Failed to save cassette %s: %v", path, err)
+	}
+}
+
+// Record 开始录制：包装所有已注册的提供方，之后模型和外部接口的调用结果都会写入 Cassette
+func (c *Cassette) Record() {
+	activeCassette, replaying = c, false
+
+	c.mu.Lock()
+	c.Models = nil
+	for _, m := range Models() {
+		m.ApiKey = ""
+		c.Models = append(c.Models, m)
+	}
+	c.mu.Unlock()
+
+	providerMux.Lock()
+	defer providerMux.Unlock()
+	for name, creator := range providerMap {
+		creator := creator
+		providerMap[name] = func(cfg config.ModelConfig) (Provider, error) {
+			p, err := creator(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return &recordingProvider{inner: p, cassette: c}, nil
+		}
+	}
+}
+
+// Replay 开始回放：注册 replay 提供方，并返回录制时的模型配置，调用方需将其设置为当前配置
+func (c *Cassette) Replay() []config.ModelConfig {
+	activeCassette, replaying = c, true
+	RegisterProvider(ReplayProvider, func(cfg config.ModelConfig) (Provider, error) {
+		return &replayProvider{cassette: c}, nil
+	})
+	models := make([]config.ModelConfig, 0, len(c.Models))
+	for _, m := range c.Models {
+		m.Provider = ReplayProvider
+		models = append(models, m)
+	}
+	return models
+}
+
+// throughCassette 调用外部接口：录制模式下保存成功的结果，回放模式下直接返回录制的结果，未启用时直接调用
+func throughCassette[T any](key string, call func() (T, error)) (T, error) {
+	c := activeCassette
+	if c == nil {
+		return call()
+	}
+
+	var result T
+	if replaying {
+		c.mu.Lock()
+		data, ok := c.Calls[key]
+		c.mu.Unlock()
+		if !ok {
+			return result, ErrNotRecorded
+		}
+		err := json.Unmarshal(data, &result)
+		return result, err
+	}
+
+	result, err := call()
+	if err != nil {
+		return result, err
+	}
+	if data, err := json.Marshal(result); err == nil {
+		c.mu.Lock()
+		c.Calls[key] = data
+		c.mu.Unlock()
+		c.recorded()
+	}
+	return result, nil
+}
+
+// 请求摘要，由模型、消息、工具和输出格式决定，消息中的日期不影响摘要
+func requestKey(req *ChatRequest) string {
+	tools := make([]string, 0, len(req.Tools))
+	for _, t := range req.Tools {
+		tools = append(tools, t.Name)
+	}
+	messages := make([]Message, len(req.Messages))
+	for i, m := range req.Messages {
+		m.Content = datePattern.ReplaceAllString(m.Content, "YYYY年MM月DD日")
+		messages[i] = m
+	}
+	data, _ := json.Marshal(struct {
+		Model    string
+		Messages []Message
+		Tools    []string
+		JSONMode bool
+	}{req.Model, messages, tools, req.JSONMode})
+	return digest(string(data))
+}
+
+func digest(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type recordingProvider struct {
+	inner    Provider
+	cassette *Cassette
+}
+
+func (p *recordingProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	resp, err := p.inner.Chat(ctx, req)
+	p.save(req, resp, err)
+	return resp, err
+}
+
+func (p *recordingProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta StreamHandler) (*ChatResponse, error) {
+	resp, err := p.inner.ChatStream(ctx, req, onDelta)
+	p.save(req, resp, err)
+	return resp, err
+}
+
+// 只保存成功的回复
+func (p *recordingProvider) save(req *ChatRequest, resp *ChatResponse, err error) {
+	if err != nil || resp == nil {
+		return
+	}
+	p.cassette.mu.Lock()
+	p.cassette.Responses[requestKey(req)] = resp
+	p.cassette.mu.Unlock()
+	p.cassette.recorded()
+}
+
+type replayProvider struct {
+	cassette *Cassette
+}
+
+func (p *replayProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	return p.ChatStream(ctx, req, nil)
+}
+
+func (p *replayProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta StreamHandler) (*ChatResponse, error) {
+	p.cassette.mu.Lock()
+	recorded, ok := p.cassette.Responses[requestKey(req)]
+	p.cassette.mu.Unlock()
+	if !ok {
+		// 404 不会触发重试
+		return nil, &ProviderError{Code: 404, Message: "没有录制该请求的回复，请重新录制"}
+	}
+
+	resp := *recorded
+	if req.OnReasoning != nil && resp.Reasoning != "" {
+		if err := req.OnReasoning(resp.Reasoning); err != nil {
+			return &ChatResponse{}, err
+		}
+	}
+	if onDelta != nil && resp.Content != "" {
+		if err := onDelta(resp.Content); err != nil {
+			return &ChatResponse{}, err
+		}
+	}
+	return &resp, nil
+}
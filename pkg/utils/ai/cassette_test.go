@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type stubProvider struct {
+	calls int
+}
+
+func (p *stubProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	return p.ChatStream(ctx, req, nil)
+}
+
+func (p *stubProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta StreamHandler) (*ChatResponse, error) {
+	p.calls++
+	return &ChatResponse{Content: "今天的新闻"}, nil
+}
+
+// 使用 c 作为当前的 Cassette，测试结束后恢复
+func useCassette(t *testing.T, c *Cassette, replay bool) {
+	prev, prevReplaying := activeCassette, replaying
+	activeCassette, replaying = c, replay
+	t.Cleanup(func() { activeCassette, replaying = prev, prevReplaying })
+}
+
+func searchRequest(date string) *ChatRequest {
+	return &ChatRequest{Model: "deepseek-chat", Messages: []Message{
+		{Role: "system", Content: "今天是" + date + "，请根据搜索结果回答"},
+		{Role: "user", Content: "最新的司法解释"},
+	}}
+}
+
+func TestRequestKeyIgnoresDate(t *testing.T) {
+	if requestKey(searchRequest("2026年10月17日")) != requestKey(searchRequest("2026年10月18日")) {
+		t.Fatal("request key depends on the date in the prompt")
+	}
+	other := searchRequest("2026年10月17日")
+	other.Messages[1].Content = "最早的司法解释"
+	if requestKey(searchRequest("2026年10月17日")) == requestKey(other) {
+		t.Fatal("request key ignores message content")
+	}
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := NewCassette()
+	recorder.AutoSave(path)
+	useCassette(t, recorder, false)
+
+	// 录制：每条结果都立即写入文件
+	stub := &stubProvider{}
+	p := &recordingProvider{inner: stub, cassette: recorder}
+	if _, err := p.Chat(context.Background(), searchRequest("2026年10月17日")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := throughCassette("embedding-key", func() ([]float64, error) { return []float64{0.5, 0.25}, nil }); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	if len(saved.Responses) != 1 || len(saved.Calls) != 1 {
+		t.Fatalf("saved %d responses and %d calls, want 1 and 1", len(saved.Responses), len(saved.Calls))
+	}
+
+	// 回放：第二天的请求仍能命中，不调用真实接口
+	useCassette(t, saved, true)
+	replay := &replayProvider{cassette: saved}
+	var streamed string
+	resp, err := replay.ChatStream(context.Background(), searchRequest("2026年10月18日"), func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if resp.Content != "今天的新闻" || streamed != "今天的新闻" {
+		t.Fatalf("replayed %q, streamed %q", resp.Content, streamed)
+	}
+	vector, err := throughCassette("embedding-key", func() ([]float64, error) {
+		t.Fatal("replay called the real API")
+		return nil, nil
+	})
+	if err != nil || len(vector) != 2 || vector[0] != 0.5 {
+		t.Fatalf("vector = %v, err = %v", vector, err)
+	}
+
+	if _, err := throughCassette("missing", func() ([]float64, error) { return nil, nil }); !errors.Is(err, ErrNotRecorded) {
+		t.Fatalf("err = %v, want ErrNotRecorded", err)
+	}
+	var pe *ProviderError
+	_, err = replay.Chat(context.Background(), &ChatRequest{Model: "deepseek-chat", Messages: UserMessages("没录过")})
+	if !errors.As(err, &pe) || pe.Code != 404 {
+		t.Fatalf("err = %v, want 404 ProviderError", err)
+	}
+	if stub.calls != 1 {
+		t.Fatalf("upstream called %d times, want 1", stub.calls)
+	}
+}
@@ -2,6 +2,7 @@ package ai
 
 import (
 	"Programming-Demo/core/milvus"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"github.com/fatih/color"
@@ -148,7 +149,7 @@ func processBatch(records [][]string, startID int64) error {
 		}
 
 		// 只为问题生成向量
-		embedding, err := GenerateEmbedding(record[0])
+		embedding, err := GenerateEmbedding(context.Background(), record[0])
 		if err != nil {
 			color.Red("向量生成失败，跳过此条：%v", err)
 			// 移除对应的content和id
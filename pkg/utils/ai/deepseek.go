@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"Programming-Demo/config"
+	"Programming-Demo/pkg/utils/deepseek"
+	"context"
+	"errors"
+)
+
+// deepseekProvider 基于 DeepSeek HTTP 接口的 Provider 实现
+type deepseekProvider struct {
+	client *deepseek.Client
+}
+
+func newDeepSeekProvider(cfg config.ModelConfig) (Provider, error) {
+	return &deepseekProvider{client: deepseek.NewClient(cfg.BaseURL, cfg.ApiKey)}, nil
+}
+
+func (p *deepseekProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	message, err := p.client.ChatCompletion(ctx, p.buildBody(req))
+	if err != nil {
+		return nil, toDeepSeekError(err)
+	}
+	return fromDeepSeekMessage(message), nil
+}
+
+func (p *deepseekProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta StreamHandler) (*ChatResponse, error) {
+	message, err := p.client.ChatStream(ctx, p.buildBody(req), onDelta, req.OnReasoning)
+	if message == nil {
+		return nil, toDeepSeekError(err)
+	}
+	// 连接中途断开时保留已收到的内容
+	return fromDeepSeekMessage(message), toDeepSeekError(err)
+}
+
+func fromDeepSeekMessage(message *deepseek.ResponseMessage) *ChatResponse {
+	resp := &ChatResponse{Content: message.Content, Reasoning: message.ReasoningContent}
+	for _, call := range message.ToolCalls {
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	return resp
+}
+
+// 将接口状态错误转换为 ProviderError，其他错误（如回调中止）原样返回
+func toDeepSeekError(err error) error {
+	var se *deepseek.StatusError
+	if errors.As(err, &se) {
+		return &ProviderError{Code: se.Code, Message: se.Message}
+	}
+	return err
+}
+
+func (p *deepseekProvider) buildBody(req *ChatRequest) deepseek.RequestBody {
+	messages := make([]deepseek.Message, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		msg := deepseek.Message{Content: m.Content, Role: m.Role, ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, deepseek.ToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: deepseek.ToolCallFunction{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		messages = append(messages, msg)
+	}
+	body := deepseek.NewRequestBody(messages, req.Model)
+	if len(req.Tools) > 0 {
+		tools := make([]deepseek.Tool, 0, len(req.Tools))
+		for _, t := range req.Tools {
+			tools = append(tools, deepseek.Tool{
+				Type:     "function",
+				Function: deepseek.ToolFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+			})
+		}
+		body.Tools = tools
+		body.ToolChoice = "auto"
+	}
+	if req.JSONMode {
+		body.ResponseFormat.Type = "json_object"
+	}
+	if req.Temperature > 0 {
+		body.Temperature = req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		body.MaxTokens = req.MaxTokens
+	}
+	return body
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"Programming-Demo/internal/app/ai/ai_entity"
+)
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message 对话中的一条消息
+type Message struct {
+	Role       string
+	Content    string
+	ToolCalls  []ToolCall // assistant 消息中模型发起的工具调用
+	ToolCallID string     // tool 消息对应的工具调用ID
+}
+
+// UserMessages 将单个提示词包装为只有一条用户消息的对话
+func UserMessages(prompt string) []Message {
+	return []Message{{Role: RoleUser, Content: prompt}}
+}
+
+// BuildChatMessages 构建多轮对话消息：系统人设、按时间排列的历史问答、最新问题。
+// 连续的同角色消息会被合并，且保证第一条非系统消息来自用户，以满足 deepseek-reasoner 等模型的交替要求。
+// 历史中的思维链（Reasoning）和工具调用过程不会被发送，只保留用户提问和最终回答
+func BuildChatMessages(system string, histories []ai_entity.ChatHistory, question string) []Message {
+	messages := make([]Message, 0, len(histories)+2)
+	if system != "" {
+		messages = append(messages, Message{Role: RoleSystem, Content: system})
+	}
+
+	turns := make([]Message, 0, len(histories)+1)
+	for _, history := range histories {
+		if history.Role != RoleUser && history.Role != RoleAssistant || history.ToolCalls != "" {
+			continue
+		}
+		if len(turns) == 0 && history.Role != RoleUser {
+			continue
+		}
+		turns = appendTurn(turns, Message{Role: history.Role, Content: history.Content})
+	}
+	turns = appendTurn(turns, Message{Role: RoleUser, Content: question})
+
+	return append(messages, turns...)
+}
+
+func appendTurn(turns []Message, msg Message) []Message {
+	if n := len(turns); n > 0 && turns[n-1].Role == msg.Role {
+		turns[n-1].Content += "\n\n" + msg.Content
+		return turns
+	}
+	return append(turns, msg)
+}
@@ -0,0 +1,129 @@
+package ai
+
+import (
+	"Programming-Demo/config"
+	"context"
+	"errors"
+	"github.com/northes/go-moonshot"
+	"io"
+	"strings"
+	"sync"
+)
+
+// moonshotProvider 基于 go-moonshot 的 Provider 实现
+type moonshotProvider struct {
+	client *moonshot.Client
+}
+
+// GetProvider 每次调用都会创建 Provider，相同密钥和地址的模型共用一个客户端
+var (
+	moonshotMux     sync.Mutex
+	moonshotClients = make(map[string]*moonshot.Client)
+)
+
+func newMoonshotProvider(cfg config.ModelConfig) (Provider, error) {
+	client, err := moonshotClient(cfg.ApiKey, cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &moonshotProvider{client: client}, nil
+}
+
+func moonshotClient(apiKey string, baseURL string) (*moonshot.Client, error) {
+	key := apiKey + "|" + baseURL
+	moonshotMux.Lock()
+	defer moonshotMux.Unlock()
+	if client, ok := moonshotClients[key]; ok {
+		return client, nil
+	}
+	opts := []moonshot.Option{moonshot.WithAPIKey(apiKey)}
+	if baseURL != "" {
+		opts = append(opts, moonshot.WithHost(baseURL))
+	}
+	client, err := moonshot.NewClientWithConfig(moonshot.NewConfig(opts...))
+	if err != nil {
+		return nil, err
+	}
+	moonshotClients[key] = client
+	return client, nil
+}
+
+func (p *moonshotProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	return p.ChatStream(ctx, req, nil)
+}
+
+func (p *moonshotProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta StreamHandler) (*ChatResponse, error) {
+	messages := make([]*moonshot.ChatCompletionsMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		messages = append(messages, &moonshot.ChatCompletionsMessage{
+			Role:    moonshot.ChatCompletionsMessageRole(m.Role),
+			Content: m.Content,
+		})
+	}
+
+	request := &moonshot.ChatCompletionsRequest{
+		Model:       moonshot.ChatCompletionsModelID(req.Model),
+		Messages:    messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Stream:      true,
+	}
+	if req.JSONMode {
+		request.ResponseFormat = &moonshot.ChatCompletionsRequestResponseFormat{Type: moonshot.ChatCompletionsResponseFormatJSONObject}
+	}
+	resp, err := p.client.Chat().CompletionsStream(ctx, request)
+	if err != nil {
+		return nil, &ProviderError{Code: moonshotErrorCode(ctx, err), Message: "moonshot chat failed: " + err.Error()}
+	}
+
+	var message string
+	receiveCh := resp.Receive()
+	for receive := range receiveCh {
+		msg, err := receive.GetMessage()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return &ChatResponse{Content: message}, nil
+			}
+			return &ChatResponse{Content: message}, &ProviderError{Code: moonshotErrorCode(ctx, err), Message: err.Error()}
+		}
+		message = message + msg.Content
+		if onDelta != nil && msg.Content != "" {
+			if err := onDelta(msg.Content); err != nil {
+				// 排空剩余数据，避免读取协程阻塞
+				go func() {
+					for range receiveCh {
+					}
+				}()
+				return &ChatResponse{Content: message}, err
+			}
+		}
+	}
+	return &ChatResponse{Content: message}, nil
+}
+
+// 根据错误内容推断状态码，用于判断是否需要重试。
+// go-moonshot 不返回 HTTP 状态码，接口错误的格式为 "[错误类型]错误信息"
+func moonshotErrorCode(ctx context.Context, err error) int {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return 504
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "invalid_authentication") || strings.Contains(msg, "incorrect api key"):
+		return 401
+	case strings.Contains(msg, "permission_denied"):
+		return 403
+	case strings.Contains(msg, "resource_not_found"):
+		return 404
+	case strings.Contains(msg, "exceeded_current_quota"):
+		// 余额不足，重试无效
+		return 402
+	case strings.Contains(msg, "invalid_request"):
+		return 400
+	case strings.Contains(msg, "rate_limit") || strings.Contains(msg, "429"):
+		return 429
+	case strings.Contains(msg, "overloaded") || strings.Contains(msg, "503"):
+		return 503
+	}
+	return 500
+}
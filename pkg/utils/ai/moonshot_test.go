@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"Programming-Demo/config"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMoonshotErrorCode(t *testing.T) {
+	tests := []struct {
+		err  string
+		want int
+	}{
+		{err: "[invalid_authentication_error]Invalid Authentication", want: 401},
+		{err: "[permission_denied_error]The API you are accessing is not open", want: 403},
+		{err: "[resource_not_found_error]Not found the model or Permission denied", want: 404},
+		{err: "[invalid_request_error]Invalid request: temperature must be in [0, 1]", want: 400},
+		{err: "[exceeded_current_quota_error]Your account is suspended", want: 402},
+		{err: "[rate_limit_reached_error]max RPM reached", want: 429},
+		{err: "[engine_overloaded_error]The engine is currently overloaded", want: 503},
+		{err: "unexpected EOF", want: 500},
+	}
+	for _, tt := range tests {
+		if got := moonshotErrorCode(context.Background(), errors.New(tt.err)); got != tt.want {
+			t.Errorf("moonshotErrorCode(%q) = %d, want %d", tt.err, got, tt.want)
+		}
+		if isRetryable(&ProviderError{Code: tt.want}) != (tt.want == 429 || tt.want >= 500) {
+			t.Errorf("%q: unexpected retry decision", tt.err)
+		}
+	}
+}
+
+func TestMoonshotProviderErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"message":"Invalid Authentication","type":"invalid_authentication_error"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := newMoonshotProvider(config.ModelConfig{BaseURL: srv.URL, ApiKey: "bad-key"})
+	if err != nil {
+		t.Fatalf("newMoonshotProvider: %v", err)
+	}
+	_, err = p.Chat(context.Background(), &ChatRequest{Model: "moonshot-v1-8k", Messages: UserMessages("你好")})
+	var pe *ProviderError
+	if !errors.As(err, &pe) || pe.Code != 401 {
+		t.Fatalf("err = %v, want 401 ProviderError", err)
+	}
+}
+
+func TestMoonshotClientReused(t *testing.T) {
+	a, err := newMoonshotProvider(config.ModelConfig{ApiKey: "key-a", BaseURL: "https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newMoonshotProvider(config.ModelConfig{ApiKey: "key-a", BaseURL: "https://example.com"})
+	c, _ := newMoonshotProvider(config.ModelConfig{ApiKey: "key-b", BaseURL: "https://example.com"})
+	if a.(*moonshotProvider).client != b.(*moonshotProvider).client {
+		t.Fatal("same key and host should share a client")
+	}
+	if a.(*moonshotProvider).client == c.(*moonshotProvider).client {
+		t.Fatal("different keys should not share a client")
+	}
+}
@@ -0,0 +1,104 @@
+package ai
+
+import (
+	"Programming-Demo/config"
+	"Programming-Demo/pkg/utils/openai"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// openaiProvider 对接 OpenAI 兼容接口（llama.cpp、vLLM 等）的 Provider 实现，同时支持向量化
+type openaiProvider struct {
+	client *openai.Client
+}
+
+func newOpenAIProvider(cfg config.ModelConfig) (Provider, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("openai 提供方需要配置 BaseURL")
+	}
+	return &openaiProvider{client: openai.NewClient(cfg.BaseURL, cfg.ApiKey)}, nil
+}
+
+func (p *openaiProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	resp, err := p.client.Chat(ctx, p.buildRequest(req))
+	if err != nil {
+		return nil, toProviderError(err)
+	}
+	return fromOpenAIMessage(&resp.Choices[0].Message), nil
+}
+
+func (p *openaiProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta StreamHandler) (*ChatResponse, error) {
+	message, err := p.client.ChatStream(ctx, p.buildRequest(req), onDelta, req.OnReasoning)
+	if message == nil {
+		return nil, toProviderError(err)
+	}
+	resp := fromOpenAIMessage(message)
+	if err != nil {
+		var se *openai.StatusError
+		if errors.As(err, &se) {
+			// 连接中途断开时保留已收到的内容
+			return resp, toProviderError(err)
+		}
+		return resp, err
+	}
+	return resp, nil
+}
+
+func (p *openaiProvider) Embed(ctx context.Context, model string, texts []string) ([][]float64, error) {
+	vectors, err := p.client.Embeddings(ctx, model, texts)
+	if err != nil {
+		return nil, toProviderError(err)
+	}
+	return vectors, nil
+}
+
+func (p *openaiProvider) buildRequest(req *ChatRequest) openai.ChatCompletionRequest {
+	messages := make([]openai.ChatMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		msg := openai.ChatMessage{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: openai.ToolCallFunction{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		messages = append(messages, msg)
+	}
+	request := openai.ChatCompletionRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	}
+	for _, t := range req.Tools {
+		request.Tools = append(request.Tools, openai.Tool{
+			Type:     "function",
+			Function: openai.ToolFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+	if len(request.Tools) > 0 {
+		request.ToolChoice = "auto"
+	}
+	if req.JSONMode {
+		request.ResponseFormat = &openai.ResponseFormat{Type: "json_object"}
+	}
+	return request
+}
+
+func fromOpenAIMessage(message *openai.ChatMessage) *ChatResponse {
+	resp := &ChatResponse{Content: message.Content, Reasoning: message.ReasoningContent}
+	for _, call := range message.ToolCalls {
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	return resp
+}
+
+func toProviderError(err error) error {
+	var se *openai.StatusError
+	if errors.As(err, &se) {
+		return &ProviderError{Code: se.Code, Message: se.Message}
+	}
+	return &ProviderError{Code: 500, Message: err.Error()}
+}
@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"Programming-Demo/config"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newTestOpenAIProvider(t *testing.T, handler http.HandlerFunc) Provider {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	p, err := newOpenAIProvider(config.ModelConfig{BaseURL: srv.URL, ApiKey: "test-key"})
+	if err != nil {
+		t.Fatalf("newOpenAIProvider: %v", err)
+	}
+	return p
+}
+
+func TestOpenAIProviderRequiresBaseURL(t *testing.T) {
+	if _, err := newOpenAIProvider(config.ModelConfig{}); err == nil {
+		t.Fatal("expected error without BaseURL")
+	}
+}
+
+func TestOpenAIProviderChat(t *testing.T) {
+	var got struct {
+		Model          string                   `json:"model"`
+		Messages       []map[string]interface{} `json:"messages"`
+		Tools          []map[string]interface{} `json:"tools"`
+		ToolChoice     string                   `json:"tool_choice"`
+		ResponseFormat map[string]string        `json:"response_format"`
+	}
+	p := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"","reasoning_content":"先检索","tool_calls":[{"id":"call_2","type":"function","function":{"name":"search_law","arguments":"{}"}}]}}]}`)
+	})
+
+	resp, err := p.Chat(context.Background(), &ChatRequest{
+		Model: "qwen",
+		Messages: []Message{
+			{Role: "user", Content: "押金不退怎么办"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "search_law", Arguments: `{"q":"押金"}`}}},
+			{Role: "tool", Content: "第七百零三条", ToolCallID: "call_1"},
+		},
+		Tools:    []ToolDefinition{{Name: "search_law", Description: "检索法条", Parameters: map[string]interface{}{"type": "object"}}},
+		JSONMode: true,
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	want := &ChatResponse{Reasoning: "先检索", ToolCalls: []ToolCall{{ID: "call_2", Name: "search_law", Arguments: "{}"}}}
+	if !reflect.DeepEqual(resp, want) {
+		t.Fatalf("resp = %+v, want %+v", resp, want)
+	}
+
+	if got.Model != "qwen" || len(got.Messages) != 3 || got.ToolChoice != "auto" || got.ResponseFormat["type"] != "json_object" {
+		t.Fatalf("request = %+v", got)
+	}
+	if got.Messages[2]["tool_call_id"] != "call_1" {
+		t.Fatalf("tool message = %v", got.Messages[2])
+	}
+	calls, _ := got.Messages[1]["tool_calls"].([]interface{})
+	if len(calls) != 1 {
+		t.Fatalf("assistant tool calls = %v", got.Messages[1]["tool_calls"])
+	}
+	if len(got.Tools) != 1 || got.Tools[0]["type"] != "function" {
+		t.Fatalf("tools = %v", got.Tools)
+	}
+}
+
+func TestOpenAIProviderErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized},
+		{name: "bad request", status: http.StatusBadRequest},
+		{name: "rate limited", status: http.StatusTooManyRequests},
+		{name: "bad gateway", status: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			})
+			_, err := p.Chat(context.Background(), &ChatRequest{Model: "qwen", Messages: UserMessages("你好")})
+			var pe *ProviderError
+			if !errors.As(err, &pe) || pe.Code != tt.status {
+				t.Fatalf("Chat err = %v, want ProviderError %d", err, tt.status)
+			}
+			_, err = p.ChatStream(context.Background(), &ChatRequest{Model: "qwen", Messages: UserMessages("你好")}, nil)
+			if !errors.As(err, &pe) || pe.Code != tt.status {
+				t.Fatalf("ChatStream err = %v, want ProviderError %d", err, tt.status)
+			}
+			_, err = p.(Embedder).Embed(context.Background(), "bge", []string{"你好"})
+			if !errors.As(err, &pe) || pe.Code != tt.status {
+				t.Fatalf("Embed err = %v, want ProviderError %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestOpenAIProviderChatStream(t *testing.T) {
+	p := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"reasoning_content\":\"分析\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"可以\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"起诉\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	var deltas, reasoning []string
+	resp, err := p.ChatStream(context.Background(), &ChatRequest{
+		Model:       "qwen",
+		Messages:    UserMessages("怎么办"),
+		OnReasoning: func(s string) error { reasoning = append(reasoning, s); return nil },
+	}, func(s string) error { deltas = append(deltas, s); return nil })
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if resp.Content != "可以起诉" || resp.Reasoning != "分析" {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if !reflect.DeepEqual(deltas, []string{"可以", "起诉"}) || !reflect.DeepEqual(reasoning, []string{"分析"}) {
+		t.Fatalf("deltas = %v, reasoning = %v", deltas, reasoning)
+	}
+
+	// 回调中止时返回已生成的内容和回调的错误，不包装为 ProviderError
+	stop := errors.New("client gone")
+	resp, err = p.ChatStream(context.Background(), &ChatRequest{Model: "qwen", Messages: UserMessages("怎么办")},
+		func(s string) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Fatalf("err = %v, want %v", err, stop)
+	}
+	if resp == nil || resp.Content != "可以" {
+		t.Fatalf("resp = %+v, want partial content", resp)
+	}
+}
+
+func TestOpenAIProviderEmbed(t *testing.T) {
+	p := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"index":0,"embedding":[1,2]},{"index":1,"embedding":[3,4]}]}`)
+	})
+	vectors, err := p.(Embedder).Embed(context.Background(), "bge", []string{"甲", "乙"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if !reflect.DeepEqual(vectors, [][]float64{{1, 2}, {3, 4}}) {
+		t.Fatalf("vectors = %v", vectors)
+	}
+}
@@ -0,0 +1,312 @@
+package ai
+
+import (
+	"Programming-Demo/config"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// ChatRequest 一次对话请求
+type ChatRequest struct {
+	Model       string    // 提供方侧的模型ID
+	Messages    []Message // 按顺序排列的对话消息
+	Temperature float64   // 温度
+	MaxTokens   int       // 最大输出 token 数
+	// OnReasoning 流式输出思维链的回调，仅 deepseek-reasoner 等推理模型会调用
+	OnReasoning StreamHandler
+	Tools       []ToolDefinition // 允许模型调用的工具
+	JSONMode    bool             // 要求模型输出 JSON 对象（response_format: json_object）
+}
+
+// ChatResponse 一次对话的结果
+type ChatResponse struct {
+	Model     string     // 实际回答的模型名称
+	Content   string     // 回复内容
+	Reasoning string     // 推理模型的思维链，不应再作为上下文发送给模型
+	ToolCalls []ToolCall // 模型要求调用的工具，不为空时需要执行后把结果发回模型
+}
+
+// StreamHandler 流式输出回调，每收到一段增量内容调用一次，返回错误时中止生成
+type StreamHandler func(delta string) error
+
+// Provider 大模型提供方，ctx 结束时应尽快中止上游调用
+type Provider interface {
+	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+	// ChatStream 流式对话，返回完整回复；被 onDelta 中止时返回已生成的部分内容和 onDelta 的错误
+	ChatStream(ctx context.Context, req *ChatRequest, onDelta StreamHandler) (*ChatResponse, error)
+}
+
+// Embedder 支持文本向量化的 Provider 额外实现的接口
+type Embedder interface {
+	Embed(ctx context.Context, model string, texts []string) ([][]float64, error)
+}
+
+// ChatOption 调整单次请求的参数
+type ChatOption func(req *ChatRequest)
+
+// WithTemperature 覆盖模型默认温度
+func WithTemperature(temperature float64) ChatOption {
+	return func(req *ChatRequest) {
+		req.Temperature = temperature
+	}
+}
+
+// WithMaxTokens 覆盖模型默认最大输出 token 数
+func WithMaxTokens(maxTokens int) ChatOption {
+	return func(req *ChatRequest) {
+		req.MaxTokens = maxTokens
+	}
+}
+
+// WithReasoningHandler 流式对话时通过 handler 单独输出思维链
+func WithReasoningHandler(handler StreamHandler) ChatOption {
+	return func(req *ChatRequest) {
+		req.OnReasoning = handler
+	}
+}
+
+// WithTools 允许模型调用指定的工具，模型配置未开启 Tools 时忽略
+func WithTools(tools []ToolDefinition) ChatOption {
+	return func(req *ChatRequest) {
+		req.Tools = tools
+	}
+}
+
+// WithJSONMode 要求模型只输出一个 JSON 对象，提示词中仍需说明 JSON 的结构
+func WithJSONMode() ChatOption {
+	return func(req *ChatRequest) {
+		req.JSONMode = true
+	}
+}
+
+// ProviderCreator 根据模型配置创建 Provider
+type ProviderCreator func(cfg config.ModelConfig) (Provider, error)
+
+// ProviderError 上游接口返回的错误，Code 为 HTTP 状态码
+type ProviderError struct {
+	Code    int
+	Message string
+}
+
+func (e *ProviderError) Error() string {
+	return e.Message
+}
+
+var (
+	providerMap = make(map[string]ProviderCreator)
+	providerMux sync.RWMutex
+)
+
+func init() {
+	RegisterProvider("moonshot", newMoonshotProvider)
+	RegisterProvider("deepseek", newDeepSeekProvider)
+	RegisterProvider("openai", newOpenAIProvider)
+}
+
+// RegisterProvider 注册提供方，name 对应配置中的 Provider 字段
+func RegisterProvider(name string, creator ProviderCreator) {
+	providerMux.Lock()
+	defer providerMux.Unlock()
+	providerMap[name] = creator
+}
+
+func getCreator(name string) ProviderCreator {
+	providerMux.RLock()
+	defer providerMux.RUnlock()
+	return providerMap[name]
+}
+
+// 未配置 Models 时使用的内置模型，与原有的 moonshot / deepseek 调用保持一致
+func defaultModels() []config.ModelConfig {
+	cfg := config.GetConfig()
+	return []config.ModelConfig{
+		{Name: "moonshot", Provider: "moonshot", Model: "moonshot-v1-8k", ApiKey: cfg.Apikey, Temperature: 0.3, ContextWindow: 8192},
+		{Name: "deepseek-chat", Provider: "deepseek", ApiKey: cfg.DeepSeekKey, Temperature: 1, MaxTokens: 2048, ContextWindow: 65536, Fallback: []string{"moonshot"}, Tools: true},
+		{Name: "deepseek-reasoner", Provider: "deepseek", ApiKey: cfg.DeepSeekKey, Temperature: 1, MaxTokens: 2048, ContextWindow: 65536, Fallback: []string{"deepseek-chat", "moonshot"}},
+	}
+}
+
+// Models 返回全部模型配置（包括已停用的）
+func Models() []config.ModelConfig {
+	models := config.GetConfig().Models
+	if len(models) == 0 {
+		models = defaultModels()
+	}
+	return models
+}
+
+// EnabledModels 返回已启用的模型配置，按名称排序
+func EnabledModels() []config.ModelConfig {
+	var enabled []config.ModelConfig
+	for _, m := range Models() {
+		if !m.Disabled && getCreator(m.Provider) != nil {
+			enabled = append(enabled, m)
+		}
+	}
+	sort.Slice(enabled, func(i, j int) bool { return enabled[i].Name < enabled[j].Name })
+	return enabled
+}
+
+// GetModelConfig 查找已启用的模型配置
+func GetModelConfig(name string) (config.ModelConfig, bool) {
+	for _, m := range EnabledModels() {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return config.ModelConfig{}, false
+}
+
+// IsModelEnabled 判断模型是否可用
+func IsModelEnabled(name string) bool {
+	_, ok := GetModelConfig(name)
+	return ok
+}
+
+// GetProvider 根据模型名称创建对应的 Provider
+func GetProvider(name string) (Provider, config.ModelConfig, error) {
+	cfg, ok := GetModelConfig(name)
+	if !ok {
+		return nil, cfg, fmt.Errorf("不支持的模型类型: %s", name)
+	}
+	if cfg.Model == "" {
+		cfg.Model = cfg.Name
+	}
+	p, err := getCreator(cfg.Provider)(cfg)
+	if err != nil {
+		return nil, cfg, fmt.Errorf("初始化模型 %s 失败: %v", name, err)
+	}
+	return p, cfg, nil
+}
+
+// Chat 使用指定模型及其默认参数完成一次单轮对话
+func Chat(ctx context.Context, name string, prompt string) (*ChatResponse, error) {
+	return Complete(ctx, name, UserMessages(prompt), nil)
+}
+
+// ChatStream 使用指定模型以流式方式完成一次单轮对话
+func ChatStream(ctx context.Context, name string, prompt string, onDelta StreamHandler) (*ChatResponse, error) {
+	return Complete(ctx, name, UserMessages(prompt), onDelta)
+}
+
+// DefaultModel 不允许用户选择模型的接口（文件分析、模板生成等）使用的模型
+func DefaultModel() string {
+	if name := config.GetConfig().DefaultModel; name != "" {
+		return name
+	}
+	return "moonshot"
+}
+
+// Complete 使用指定模型完成多轮对话，onDelta 不为空时以流式方式输出。
+// 调用失败时按配置的 Fallback 依次尝试其他模型，返回的 ChatResponse.Model 为实际回答的模型。
+// ctx 取消时立即中止上游调用，返回已生成的部分内容和 ctx 的错误
+func Complete(ctx context.Context, name string, messages []Message, onDelta StreamHandler, opts ...ChatOption) (*ChatResponse, error) {
+	cfg, ok := GetModelConfig(name)
+	if !ok {
+		return nil, fmt.Errorf("不支持的模型类型: %s", name)
+	}
+
+	// 已向客户端输出过内容（包括思维链）时不能再切换模型，否则回复会重复或错乱
+	emitted := false
+	if onDelta != nil {
+		handler := onDelta
+		onDelta = func(delta string) error {
+			emitted = true
+			return handler(delta)
+		}
+	}
+	opts = append(opts, func(req *ChatRequest) {
+		if req.OnReasoning != nil {
+			handler := req.OnReasoning
+			req.OnReasoning = func(delta string) error {
+				emitted = true
+				return handler(delta)
+			}
+		}
+	})
+
+	var lastErr error
+	tokens := EstimateMessagesTokens(messages)
+	for i, candidate := range append([]string{name}, cfg.Fallback...) {
+		// 备用模型的上下文窗口放不下当前对话时跳过
+		if i > 0 && tokens > ContextBudget(candidate) {
+			continue
+		}
+		resp, err := completeOnce(ctx, candidate, messages, onDelta, opts)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+
+		var pe *ProviderError
+		if !errors.As(err, &pe) || emitted {
+			// 非上游错误（如客户端断开）或已输出部分内容，直接返回
+			return resp, err
+		}
+		log.Printf("模型 %s 调用失败: %v", candidate, err)
+	}
+	return nil, lastErr
+}
+
+// 使用单个模型完成对话，包含超时、重试与熔断
+func completeOnce(ctx context.Context, name string, messages []Message, onDelta StreamHandler, opts []ChatOption) (*ChatResponse, error) {
+	p, cfg, err := GetProvider(name)
+	if err != nil {
+		return nil, &ProviderError{Code: 500, Message: err.Error()}
+	}
+	req := &ChatRequest{
+		Model:       cfg.Model,
+		Messages:    messages,
+		Temperature: cfg.Temperature,
+		MaxTokens:   cfg.MaxTokens,
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+	// 不支持工具调用的模型（如降级到的备用模型）改为普通对话，工具调用过程转为文字
+	if !cfg.Tools {
+		req.Tools = nil
+		req.Messages = flattenToolMessages(req.Messages)
+	}
+
+	resp, err := callWithRetry(ctx, p, cfg, req, onDelta)
+	if resp != nil {
+		resp.Model = cfg.Name
+	}
+	return resp, err
+}
+
+// ChatWithModel 与 Chat 相同，但按原有接口风格返回 (内容, 状态码)
+func ChatWithModel(ctx context.Context, name string, prompt string) (string, int) {
+	return CompleteWithModel(ctx, name, UserMessages(prompt))
+}
+
+// CompleteWithModel 与 Complete 相同，但按原有接口风格返回 (内容, 状态码)
+func CompleteWithModel(ctx context.Context, name string, messages []Message) (string, int) {
+	resp, err := Complete(ctx, name, messages, nil)
+	if err != nil {
+		var pe *ProviderError
+		if errors.As(err, &pe) {
+			return pe.Message, pe.Code
+		}
+		return err.Error(), 500
+	}
+	return resp.Content, 200
+}
+
+// Embed 使用指定模型批量生成文本向量，模型的提供方需要支持向量化
+func Embed(ctx context.Context, name string, texts []string) ([][]float64, error) {
+	p, cfg, err := GetProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := p.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("模型 %s 不支持向量化", name)
+	}
+	return e.Embed(ctx, cfg.Model, texts)
+}
@@ -0,0 +1,176 @@
+package ai
+
+import (
+	"Programming-Demo/config"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCallTimeout  = 180 * time.Second // 单次调用默认超时时间
+	DefaultMaxRetries   = 2                 // 默认重试次数
+	BreakerThreshold    = 5                 // 连续失败多少次后熔断
+	BreakerOpenDuration = 30 * time.Second  // 熔断持续时间，之后放行一次试探请求
+)
+
+// circuitBreaker 单个提供方的熔断器
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+var (
+	breakers   = make(map[string]*circuitBreaker)
+	breakerMux sync.Mutex
+)
+
+// 熔断器按提供方和接口地址区分，同一服务下的多个模型共用
+func getBreaker(cfg config.ModelConfig) *circuitBreaker {
+	key := cfg.Provider + "|" + cfg.BaseURL
+	breakerMux.Lock()
+	defer breakerMux.Unlock()
+	b, ok := breakers[key]
+	if !ok {
+		b = &circuitBreaker{}
+		breakers[key] = b
+	}
+	return b
+}
+
+// allow 判断是否放行请求；熔断期过后只放行一个试探请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < BreakerThreshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) onSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// release 释放试探名额，不改变熔断状态
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *circuitBreaker) onFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= BreakerThreshold {
+		b.openUntil = time.Now().Add(BreakerOpenDuration)
+	}
+}
+
+// 429、5xx 以及超时可以重试
+func isRetryable(err error) bool {
+	var pe *ProviderError
+	if !errors.As(err, &pe) {
+		return false
+	}
+	return pe.Code == 429 || pe.Code >= 500
+}
+
+// 调用单个模型，带超时、指数退避重试和熔断。
+// 流式输出已经开始或调用方主动中止时不再重试
+func callWithRetry(parent context.Context, p Provider, cfg config.ModelConfig, req *ChatRequest, onDelta StreamHandler) (*ChatResponse, error) {
+	breaker := getBreaker(cfg)
+
+	timeout := DefaultCallTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	maxRetries := DefaultMaxRetries
+	if cfg.MaxRetries < 0 {
+		maxRetries = 0
+	} else if cfg.MaxRetries > 0 {
+		maxRetries = cfg.MaxRetries
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(math.Pow(2, float64(attempt))*float64(800+rand.Intn(400))) * time.Millisecond
+			log.Printf("模型 %s 调用失败，%v 后进行第 %d 次重试: %v", cfg.Name, backoff, attempt, lastErr)
+			select {
+			case <-time.After(backoff):
+			case <-parent.Done():
+				return nil, parent.Err()
+			}
+		}
+		if !breaker.allow() {
+			return nil, &ProviderError{Code: 503, Message: fmt.Sprintf("模型 %s 暂时不可用（熔断中）", cfg.Name)}
+		}
+
+		emitted := false
+		handler := onDelta
+		if onDelta != nil {
+			handler = func(delta string) error {
+				emitted = true
+				return onDelta(delta)
+			}
+		}
+		attemptReq := *req
+		if req.OnReasoning != nil {
+			attemptReq.OnReasoning = func(delta string) error {
+				emitted = true
+				return req.OnReasoning(delta)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		var resp *ChatResponse
+		var err error
+		if handler != nil {
+			resp, err = p.ChatStream(ctx, &attemptReq, handler)
+		} else {
+			resp, err = p.Chat(ctx, &attemptReq)
+		}
+		cancel()
+
+		// 调用方已取消（如客户端断开），返回已生成的部分内容，不重试也不计入熔断
+		if parent.Err() != nil {
+			breaker.release()
+			if resp == nil {
+				resp = &ChatResponse{}
+			}
+			return resp, parent.Err()
+		}
+		if err == nil {
+			breaker.onSuccess()
+			return resp, nil
+		}
+		if !isRetryable(err) {
+			// 参数错误、鉴权失败或客户端断开，说明服务本身可用，不计入熔断
+			breaker.onSuccess()
+			return resp, err
+		}
+		breaker.onFailure()
+		lastErr = err
+		if emitted {
+			return resp, err
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,30 @@
+package ai
+
+import (
+	"Programming-Demo/config"
+	"context"
+	"testing"
+)
+
+type countingProvider struct{ calls int }
+
+func (p *countingProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	p.calls++
+	return nil, &ProviderError{Code: 500, Message: "upstream error"}
+}
+
+func (p *countingProvider) ChatStream(ctx context.Context, req *ChatRequest, onDelta StreamHandler) (*ChatResponse, error) {
+	return p.Chat(ctx, req)
+}
+
+func TestCallWithRetryNoRetries(t *testing.T) {
+	p := &countingProvider{}
+	cfg := config.ModelConfig{Name: "no-retry", Provider: "counting", MaxRetries: -1}
+	resp, err := callWithRetry(context.Background(), p, cfg, &ChatRequest{}, nil)
+	if err == nil || resp != nil {
+		t.Fatalf("expected error, got resp=%v err=%v", resp, err)
+	}
+	if p.calls != 1 {
+		t.Fatalf("MaxRetries -1 should call once, got %d", p.calls)
+	}
+}
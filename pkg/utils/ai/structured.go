@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+)
+
+const MaxJSONAttempts = 3 // 结构化输出最多尝试的次数（含修正）
+
+// Validator 结构化输出的业务校验，CompleteJSON 解析成功后调用
+type Validator interface {
+	Validate() error
+}
+
+// CompleteJSON 以 JSON 模式调用模型并将结果解析到 out（需为指针）。
+// out 实现 Validator 时还会进行校验；解析或校验失败时把错误发回模型要求修正，最多尝试 MaxJSONAttempts 次
+func CompleteJSON(ctx context.Context, name string, messages []Message, out interface{}, opts ...ChatOption) (*ChatResponse, error) {
+	opts = append(append([]ChatOption{}, opts...), WithJSONMode())
+	current := append([]Message{}, messages...)
+
+	var lastErr error
+	for attempt := 0; attempt < MaxJSONAttempts; attempt++ {
+		resp, err := Complete(ctx, name, current, nil, opts...)
+		if err != nil {
+			return resp, err
+		}
+
+		lastErr = decodeJSON(resp.Content, out)
+		if lastErr == nil {
+			if v, ok := out.(Validator); ok {
+				lastErr = v.Validate()
+			}
+		}
+		if lastErr == nil {
+			return resp, nil
+		}
+
+		log.Printf("模型 %s 第 %d 次输出的 JSON 无效: %v", name, attempt+1, lastErr)
+		current = append(current,
+			Message{Role: RoleAssistant, Content: resp.Content},
+			Message{Role: RoleUser, Content: fmt.Sprintf("上面的输出不符合要求：%v。请修正后重新输出完整的 JSON 对象，不要包含任何其他内容。", lastErr)},
+		)
+	}
+	return nil, fmt.Errorf("模型输出的 JSON 无效: %w", lastErr)
+}
+
+// 解析前清空 out，避免上一次尝试残留的字段
+func decodeJSON(content string, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("out 必须是非空指针")
+	}
+	v.Elem().Set(reflect.Zero(v.Elem().Type()))
+
+	text := ExtractJSON(content)
+	if text == "" {
+		return fmt.Errorf("没有找到 JSON 对象")
+	}
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		return fmt.Errorf("JSON 格式错误: %v", err)
+	}
+	return nil
+}
+
+// ExtractJSON 从模型输出中取出 JSON 对象，去掉 ```json 代码块和前后的说明文字
+func ExtractJSON(content string) string {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	}
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return ""
+	}
+	return content[start : end+1]
+}
@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"context"
+	"fmt"
+	"strings"
+)
+
+const summaryMaxTokens = 1024 // 摘要的最大输出长度
+
+// SummaryTranscript 将需要并入摘要的消息整理为对话文本，用于渲染摘要提示词。
+// 每次只需要传入上次摘要之后新被挤出上下文的消息
+func SummaryTranscript(name string, previous string, histories []ai_entity.ChatHistory) string {
+	// 单条消息过长（如粘贴的整份合同）时截断，保证摘要请求本身不超出上下文
+	budget := ContextBudget(name) - EstimateTokens(previous) - summaryMaxTokens - 512
+	perMessage := 256
+	if len(histories) > 0 && budget/len(histories) > perMessage {
+		perMessage = budget / len(histories)
+	}
+
+	var transcript strings.Builder
+	for _, h := range histories {
+		speaker := "用户"
+		switch {
+		case h.ToolCalls != "" && h.Content == "":
+			// 只有工具调用没有文字的中间消息，结果已在 tool 消息中
+			continue
+		case h.Role == RoleAssistant:
+			speaker = "助手"
+		case h.Role == RoleTool:
+			speaker = "工具结果"
+		}
+		transcript.WriteString(speaker + "：" + truncateTokens(h.Content, perMessage) + "\n")
+	}
+	return transcript.String()
+}
+
+// Summarize 使用渲染好的摘要提示词生成新的滚动摘要
+func Summarize(ctx context.Context, name string, prompt string) (string, error) {
+	resp, err := Complete(ctx, name, UserMessages(prompt), nil, WithTemperature(0.3), WithMaxTokens(summaryMaxTokens))
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", fmt.Errorf("模型返回了空摘要")
+	}
+	return summary, nil
+}
+
+// WithSummary 将早期对话摘要附加到系统提示词之后
+func WithSummary(system string, summary string) string {
+	if summary == "" {
+		return system
+	}
+	return system + "\n\n以下是本次咨询早期对话的摘要，回答时请结合其中的事实：\n" + summary
+}
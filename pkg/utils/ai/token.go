@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"Programming-Demo/internal/app/ai/ai_entity"
+	"unicode"
+)
+
+const (
+	DefaultContextWindow = 8192 // 未配置 ContextWindow 时的上下文窗口大小
+	DefaultOutputReserve = 1024 // 未配置 MaxTokens 时为输出预留的 token 数
+	messageOverhead      = 4    // 每条消息的角色、分隔符等格式开销
+)
+
+// EstimateTokens 粗略估算文本的 token 数：中日韩字符按 1 个计，其余字符按 4 个计 1 个。
+// 各家分词器不同，这里宁可估多一些
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) || unicode.IsPunct(r) && r > unicode.MaxASCII {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// EstimateMessagesTokens 估算一组消息的 token 数
+func EstimateMessagesTokens(messages []Message) int {
+	total := 0
+	for _, m := range messages {
+		total += EstimateTokens(m.Content) + messageOverhead
+	}
+	return total
+}
+
+// ContextBudget 模型可用于输入的 token 数：上下文窗口减去为输出预留的部分
+func ContextBudget(name string) int {
+	cfg, ok := GetModelConfig(name)
+	if !ok {
+		return 0
+	}
+	window := cfg.ContextWindow
+	if window <= 0 {
+		window = DefaultContextWindow
+	}
+	reserve := cfg.MaxTokens
+	if reserve <= 0 {
+		reserve = DefaultOutputReserve
+	}
+	return window - reserve
+}
+
+// SplitHistory 从最新的消息往前保留总量不超过 budget 的历史，返回更早的部分和保留的部分。
+// 保留部分总是从用户消息开始，避免出现没有提问的回答
+func SplitHistory(histories []ai_entity.ChatHistory, budget int) (older, recent []ai_entity.ChatHistory) {
+	start := len(histories)
+	used := 0
+	for i := len(histories) - 1; i >= 0; i-- {
+		used += EstimateTokens(histories[i].Content) + messageOverhead
+		if used > budget {
+			break
+		}
+		start = i
+	}
+	for start < len(histories) && histories[start].Role != RoleUser {
+		start++
+	}
+	return histories[:start], histories[start:]
+}
+
+// 按 token 估算截断文本，保留开头部分
+func truncateTokens(text string, max int) string {
+	if EstimateTokens(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if EstimateTokens(string(runes[:mid])) <= max {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo]) + "……（后文省略）"
+}
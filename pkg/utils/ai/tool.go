@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+const (
+	MaxToolRounds     = 5    // 单次回答中最多进行的工具调用轮数
+	MaxToolResultSize = 3000 // 单个工具结果发送给模型的最大 token 数
+)
+
+// ToolCall 模型发起的一次工具调用
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON 格式的参数
+}
+
+// ToolDefinition 提供给模型的工具说明
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON Schema 格式的参数定义
+}
+
+// Tool 可以被模型调用的工具
+type Tool interface {
+	Definition() ToolDefinition
+	// Call 执行工具，arguments 为模型给出的 JSON 参数，返回发送给模型的文本结果
+	Call(ctx context.Context, arguments string) (string, error)
+}
+
+// ToolHandler 每执行完一次工具调用后的回调，返回错误时中止对话
+type ToolHandler func(call ToolCall, result string) error
+
+type funcTool struct {
+	def ToolDefinition
+	fn  func(ctx context.Context, arguments string) (string, error)
+}
+
+func (t *funcTool) Definition() ToolDefinition {
+	return t.def
+}
+
+func (t *funcTool) Call(ctx context.Context, arguments string) (string, error) {
+	return t.fn(ctx, arguments)
+}
+
+// NewTool 使用函数创建工具
+func NewTool(def ToolDefinition, fn func(ctx context.Context, arguments string) (string, error)) Tool {
+	return &funcTool{def: def, fn: fn}
+}
+
+// CompleteWithTools 与 Complete 相同，但允许模型调用 tools。
+// 模型要求调用工具时执行工具并把结果发回模型，直到模型给出最终回答或达到 MaxToolRounds。
+// 除最终回答外，还返回中间产生的工具调用消息和工具结果消息，供调用方记录
+func CompleteWithTools(ctx context.Context, name string, messages []Message, tools []Tool, onDelta StreamHandler, onTool ToolHandler, opts ...ChatOption) (*ChatResponse, []Message, error) {
+	defs := make([]ToolDefinition, 0, len(tools))
+	toolMap := make(map[string]Tool, len(tools))
+	for _, t := range tools {
+		def := t.Definition()
+		defs = append(defs, def)
+		toolMap[def.Name] = t
+	}
+
+	var trail []Message
+	for round := 0; ; round++ {
+		roundOpts := opts
+		// 最后一轮不再提供工具，要求模型直接回答
+		if round < MaxToolRounds && len(defs) > 0 {
+			roundOpts = append(append([]ChatOption{}, opts...), WithTools(defs))
+		}
+
+		current := append(append([]Message{}, messages...), trail...)
+		resp, err := Complete(ctx, name, current, onDelta, roundOpts...)
+		if err != nil || len(resp.ToolCalls) == 0 {
+			return resp, trail, err
+		}
+
+		trail = append(trail, Message{Role: RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			result := runTool(ctx, toolMap, call)
+			if onTool != nil {
+				if err := onTool(call, result); err != nil {
+					return resp, trail, err
+				}
+			}
+			trail = append(trail, Message{Role: RoleTool, Content: result, ToolCallID: call.ID})
+		}
+	}
+}
+
+// 执行单个工具调用，出错时把错误信息作为结果返回给模型，由模型决定如何处理
+func runTool(ctx context.Context, toolMap map[string]Tool, call ToolCall) string {
+	tool, ok := toolMap[call.Name]
+	if !ok {
+		return fmt.Sprintf("工具 %s 不存在", call.Name)
+	}
+	result, err := tool.Call(ctx, call.Arguments)
+	if err != nil {
+		log.Printf("工具 %s 调用失败: %v", call.Name, err)
+		return fmt.Sprintf("工具调用失败: %v", err)
+	}
+	return truncateTokens(result, MaxToolResultSize)
+}
+
+// 不支持工具调用的模型无法识别 tool 消息，把工具调用过程转为普通文字
+func flattenToolMessages(messages []Message) []Message {
+	flat := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		switch {
+		case m.Role == RoleTool:
+			flat = appendTurn(flat, Message{Role: RoleUser, Content: "【工具返回结果】\n" + m.Content})
+		case len(m.ToolCalls) > 0:
+			if m.Content != "" {
+				flat = appendTurn(flat, Message{Role: RoleAssistant, Content: m.Content})
+			}
+		default:
+			flat = appendTurn(flat, m)
+		}
+	}
+	return flat
+}
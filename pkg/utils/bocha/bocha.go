@@ -2,6 +2,7 @@ package bocha
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -152,7 +153,7 @@ type SearchResponse struct {
 }
 
 // Search 执行博查搜索
-func (c *Client) Search(req SearchRequest) (string, error) {
+func (c *Client) Search(ctx context.Context, req SearchRequest) (string, error) {
 	// 设置默认值
 	if req.Freshness == "" {
 		req.Freshness = "noLimit"
@@ -171,7 +172,7 @@ func (c *Client) Search(req SearchRequest) (string, error) {
 	}
 
 	// 创建HTTP请求
-	httpReq, err := http.NewRequest("POST", BaseURL+"/web-search", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", BaseURL+"/web-search", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", fmt.Errorf("创建HTTP请求失败: %w", err)
 	}
@@ -264,3 +265,39 @@ func ExtractSearchInfo(searchResultJSON string) (string, error) {
 
 	return builder.String(), nil
 }
+
+// Source 搜索结果中的一个来源网页
+type Source struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Site  string `json:"site,omitempty"`
+}
+
+// ParseSources 从 ExtractSearchInfo 生成的文本中解析出来源网页
+func ParseSources(searchInfo string) []Source {
+	var sources []Source
+	var current *Source
+	for _, line := range strings.Split(searchInfo, "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- 结果 "):
+			sources = append(sources, Source{})
+			current = &sources[len(sources)-1]
+		case current == nil:
+		case strings.HasPrefix(line, "标题: "):
+			current.Title = strings.TrimPrefix(line, "标题: ")
+		case strings.HasPrefix(line, "网址: "):
+			current.URL = strings.TrimPrefix(line, "网址: ")
+		case strings.HasPrefix(line, "来源: "):
+			current.Site = strings.TrimPrefix(line, "来源: ")
+		}
+	}
+
+	// 去掉没有网址的结果
+	valid := sources[:0]
+	for _, s := range sources {
+		if s.URL != "" {
+			valid = append(valid, s)
+		}
+	}
+	return valid
+}
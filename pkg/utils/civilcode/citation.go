@@ -0,0 +1,128 @@
+package civilcode
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 引用的核对结果
+const (
+	StatusVerified       = "verified"        // 条文存在，引用的原文（如有）与条文一致
+	StatusTextMismatch   = "text_mismatch"   // 条文存在，但引用的原文与条文不符
+	StatusNotFound       = "not_found"       // 民法典中没有该条号
+	StatusUnknownStatute = "unknown_statute" // 不是民法典，无法核对
+)
+
+// Citation 回答中的一处法条引用及核对结果
+type Citation struct {
+	Text     string `json:"text"`               // 引用在回答中的原文，如“《民法典》第一千零七十九条”，用于前端定位
+	Statute  string `json:"statute"`            // 法律名称，如“民法典”
+	Article  int    `json:"article"`            // 条号
+	Quote    string `json:"quote,omitempty"`    // 紧随引用的条文原文
+	Status   string `json:"status"`             // 核对结果
+	Original string `json:"original,omitempty"` // 民法典中的条文原文，条文存在时返回
+}
+
+const numberPattern = `[〇零一二两三四五六七八九十百千\d]+`
+
+var (
+	// “《民法典》第一千零七十九条”“民法典第1079条”“《中华人民共和国合同法》第五十二条第二款”
+	citationPattern = regexp.MustCompile(`(?:《([^《》\n]{1,40})》|(民法典))\s*第\s*(` + numberPattern + `)\s*条(?:\s*第\s*` + numberPattern + `\s*款)?`)
+	// 同一部法律的后续条号，如“第一千零七十九条、第一千零八十条”中的后者
+	continuationPattern = regexp.MustCompile(`^\s*(?:、|，|,|和|及|以及|或者|或)\s*(第\s*(` + numberPattern + `)\s*条(?:\s*第\s*` + numberPattern + `\s*款)?)`)
+	// 引用后的原文，如“规定：“……””，引号前最多允许几个字的连接词
+	quotePattern = regexp.MustCompile(`^[^“”"「」\n]{0,8}?[“"「]([^”"」]{2,}?)[”"」]`)
+	// 省略号，引用原文时常用来跳过部分内容
+	ellipsisPattern = regexp.MustCompile(`…+|\.{3,}|。{3,}`)
+)
+
+// 民法典的常见名称，其他法律目前没有可以核对的条文数据
+var civilCodeNames = map[string]bool{
+	"民法典":        true,
+	"中华人民共和国民法典": true,
+}
+
+// VerifyCitations 解析文本中的法条引用，逐条与民法典核对。
+// 引用紧跟着用引号括起的原文时一并核对原文；同一条文被多次引用时每处分别返回
+func VerifyCitations(text string) []Citation {
+	citations := make([]Citation, 0)
+	for _, m := range citationPattern.FindAllStringSubmatchIndex(text, -1) {
+		statute := "民法典"
+		if m[2] >= 0 {
+			statute = text[m[2]:m[3]]
+		}
+		end := m[1]
+		citations = append(citations, verify(text[m[0]:end], statute, text[m[6]:m[7]], text[end:]))
+		// 同一部法律后续的条号
+		for {
+			c := continuationPattern.FindStringSubmatchIndex(text[end:])
+			if c == nil {
+				break
+			}
+			rest := text[end:]
+			citations = append(citations, verify(rest[c[2]:c[3]], statute, rest[c[4]:c[5]], rest[c[1]:]))
+			end += c[1]
+		}
+	}
+	return citations
+}
+
+// 核对一处引用，after 为引用之后的文本，用于提取原文
+func verify(raw string, statute string, number string, after string) Citation {
+	citation := Citation{Text: raw, Statute: strings.TrimPrefix(statute, "中华人民共和国")}
+	citation.Article, _ = ParseNumber(number)
+	if q := quotePattern.FindStringSubmatch(after); q != nil {
+		citation.Quote = q[1]
+	}
+	if !civilCodeNames[statute] {
+		citation.Status = StatusUnknownStatute
+		return citation
+	}
+
+	article, err := Lookup(number)
+	if errors.Is(err, ErrNotFound) {
+		citation.Status = StatusNotFound
+		return citation
+	}
+	if err != nil {
+		// 条文数据加载失败时不能判断是否编造
+		citation.Status = StatusUnknownStatute
+		return citation
+	}
+	citation.Original = article.Content
+	citation.Status = StatusVerified
+	if citation.Quote != "" && !quoteMatches(citation.Quote, article.Content) {
+		citation.Status = StatusTextMismatch
+	}
+	return citation
+}
+
+// 忽略空白和标点后，引用的每一段（以省略号分隔）都按顺序出现在条文中即视为一致。
+// 条文数据中部分条文只有第一款，引用以完整的条文数据开头时也视为一致
+func quoteMatches(quote string, content string) bool {
+	content = normalize(content)
+	rest := content
+	for _, part := range ellipsisPattern.Split(quote, -1) {
+		part = normalize(part)
+		if part == "" {
+			continue
+		}
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return content != "" && strings.HasPrefix(normalize(quote), content)
+		}
+		rest = rest[i+len(part):]
+	}
+	return true
+}
+
+func normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,160 @@
+package civilcode
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultPath 民法典条文数据，与导入 Milvus 使用的是同一份文件
+const DefaultPath = "民法典.csv"
+
+// Article 民法典中的一条条文
+type Article struct {
+	Number  int    // 条号
+	Title   string // 条号原文，如“第一千零八十条”
+	Book    string // 编
+	Chapter string // 章
+	Section string // 节
+	Content string // 内容
+}
+
+// ErrNotFound 民法典中没有该条号
+var ErrNotFound = errors.New("民法典中没有该条文")
+
+var (
+	articles map[int]Article
+	loadErr  error
+	loadOnce sync.Once
+)
+
+// 首次使用时加载条文，按条号建立索引
+func load() {
+	file, err := os.Open(DefaultPath)
+	if err != nil {
+		loadErr = fmt.Errorf("无法打开民法典数据: %v", err)
+		return
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.LazyQuotes = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		loadErr = fmt.Errorf("读取民法典数据失败: %v", err)
+		return
+	}
+
+	articles = make(map[int]Article, len(records))
+	// 跳过标题行：编,章,节,条号,内容
+	for _, record := range records[1:] {
+		if len(record) < 5 {
+			continue
+		}
+		number, ok := ParseNumber(record[3])
+		if !ok {
+			continue
+		}
+		articles[number] = Article{
+			Number:  number,
+			Title:   "第" + ToChinese(number) + "条",
+			Book:    record[0],
+			Chapter: record[1],
+			Section: record[2],
+			Content: record[4],
+		}
+	}
+}
+
+// Lookup 按条号查找条文，条号可以是“1080”“第1080条”或“第一千零八十条”
+func Lookup(number string) (Article, error) {
+	loadOnce.Do(load)
+	if loadErr != nil {
+		return Article{}, loadErr
+	}
+	n, ok := ParseNumber(number)
+	if !ok {
+		return Article{}, fmt.Errorf("无法识别的条号: %s", number)
+	}
+	article, ok := articles[n]
+	if !ok {
+		return Article{}, fmt.Errorf("%w: 第%d条", ErrNotFound, n)
+	}
+	return article, nil
+}
+
+var chineseDigits = map[rune]int{
+	'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4,
+	'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+var chineseUnits = map[rune]int{'十': 10, '百': 100, '千': 1000}
+
+// ParseNumber 解析条号，支持阿拉伯数字和中文数字，忽略“第”“条”及空白
+func ParseNumber(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "第")
+	s = strings.TrimSuffix(s, "条")
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, n > 0
+	}
+
+	total, digit := 0, -1
+	for _, r := range s {
+		if d, ok := chineseDigits[r]; ok {
+			digit = d
+			continue
+		}
+		unit, ok := chineseUnits[r]
+		if !ok {
+			return 0, false
+		}
+		// “十条”“十一条”省略了前面的“一”
+		if digit < 0 {
+			digit = 1
+		}
+		total += digit * unit
+		digit = -1
+	}
+	if digit > 0 {
+		total += digit
+	}
+	return total, total > 0
+}
+
+// ToChinese 将 1-9999 的整数转换为条号中使用的中文数字，如 1010 -> 一千零一十
+func ToChinese(n int) string {
+	digits := []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+	units := []string{"千", "百", "十", ""}
+	values := []int{1000, 100, 10, 1}
+
+	var sb strings.Builder
+	zero := false
+	for i, v := range values {
+		d := n / v % 10
+		if d == 0 {
+			if sb.Len() > 0 {
+				zero = true
+			}
+			continue
+		}
+		if zero {
+			sb.WriteString("零")
+			zero = false
+		}
+		// 10-19 写作“十”“十一”
+		if !(v == 10 && d == 1 && n < 20) {
+			sb.WriteString(digits[d])
+		}
+		sb.WriteString(units[i])
+	}
+	return sb.String()
+}
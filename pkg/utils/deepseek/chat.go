@@ -2,7 +2,13 @@ package deepseek
 
 import (
 	"Programming-Demo/config"
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -10,16 +16,45 @@ import (
 
 type DeepseekResponse struct {
 	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
+		Message ResponseMessage `json:"message"`
 	} `json:"choices"`
 }
 
+// ResponseMessage 模型返回的消息
+type ResponseMessage struct {
+	Content          string     `json:"content"`
+	ReasoningContent string     `json:"reasoning_content"` // deepseek-reasoner 的思维链
+	ToolCalls        []ToolCall `json:"tool_calls"`
+}
+
+// StreamChunk 流式响应中的单个数据块
+type StreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string          `json:"content"`
+			ReasoningContent string          `json:"reasoning_content"`
+			ToolCalls        []ToolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// StatusError 接口返回了非 200 状态码
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
 // Message 使用结构体构建请求
 type Message struct {
-	Content string `json:"content"`
-	Role    string `json:"role"`
+	Content    string     `json:"content"`
+	Role       string     `json:"role"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // assistant 消息中的工具调用
+	ToolCallID string     `json:"tool_call_id,omitempty"` // tool 消息对应的调用ID
 }
 
 type RequestBody struct {
@@ -43,13 +78,29 @@ type RequestBody struct {
 
 const BaseURL = "https://api.deepseek.com/chat/completions"
 
-func ChatWithDeepSeek(content string, method string, model string) (string, int) {
+// Client DeepSeek 接口客户端
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewClient 创建 DeepSeek 客户端，baseURL 为空时使用官方地址
+func NewClient(baseURL, apiKey string) *Client {
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+	return &Client{
+		BaseURL:    baseURL,
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{},
+	}
+}
 
-	requestBody := RequestBody{
-		Messages: []Message{
-			{Content: content, Role: "system"},
-			{Content: content, Role: "user"},
-		},
+// NewRequestBody 构建带默认参数的请求体
+func NewRequestBody(messages []Message, model string) RequestBody {
+	return RequestBody{
+		Messages:         messages,
 		Model:            model,
 		FrequencyPenalty: 0,
 		MaxTokens:        2048,
@@ -63,48 +114,164 @@ func ChatWithDeepSeek(content string, method string, model string) (string, int)
 		ToolChoice:  "none",
 		Logprobs:    false,
 	}
+}
+
+func ChatWithDeepSeek(ctx context.Context, content string, method string, model string) (string, int) {
+	requestBody := NewRequestBody([]Message{
+		{Content: content, Role: "user"},
+	}, model)
+	return NewClient(BaseURL, config.GetConfig().DeepSeekKey).Chat(ctx, requestBody, method)
+}
+
+// Chat 发送对话请求，返回回复内容与状态码，ctx 超时返回 504
+func (c *Client) Chat(ctx context.Context, requestBody RequestBody, method string) (string, int) {
+	response, msg, code := c.complete(ctx, requestBody, method)
+	if code != 200 {
+		return msg, code
+	}
+	return response.Choices[0].Message.Content, 200
+}
+
+// ChatCompletion 与 Chat 相同，但返回完整的消息，包括思维链和工具调用
+func (c *Client) ChatCompletion(ctx context.Context, requestBody RequestBody) (*ResponseMessage, error) {
+	response, msg, code := c.complete(ctx, requestBody, "POST")
+	if code != 200 {
+		return nil, &StatusError{Code: code, Message: msg}
+	}
+	return &response.Choices[0].Message, nil
+}
+
+// 发送非流式请求，失败时返回错误信息与状态码
+func (c *Client) complete(ctx context.Context, requestBody RequestBody, method string) (*DeepseekResponse, string, int) {
 	// 将结构体转换为 JSON
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return "JSON编码失败: " + err.Error(), 500
+		return nil, "JSON编码失败: " + err.Error(), 500
 	}
 
-	client := &http.Client{}
-	req, err := http.NewRequest(method, BaseURL, strings.NewReader(string(jsonData)))
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL, strings.NewReader(string(jsonData)))
 
 	if err != nil {
-		return err.Error(), 500
+		return nil, err.Error(), 500
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", "Bearer "+config.GetConfig().DeepSeekKey)
+	req.Header.Add("Authorization", "Bearer "+c.APIKey)
 
-	res, err := client.Do(req)
+	res, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return "请求失败: " + err.Error(), 500
+		return nil, "请求失败: " + err.Error(), requestErrorCode(ctx)
 	}
 	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return "读取响应失败: " + err.Error(), 500
+		return nil, "读取响应失败: " + err.Error(), requestErrorCode(ctx)
 	}
 
 	// 检查响应状态码
 	if res.StatusCode != http.StatusOK {
-		return "API 请求失败，状态码: " + res.Status + ", 响应内容: " + string(body), res.StatusCode
+		return nil, "API 请求失败，状态码: " + res.Status + ", 响应内容: " + string(body), res.StatusCode
 	}
 
 	// 尝试解析响应
 	var response DeepseekResponse
 	if err := json.Unmarshal(body, &response); err != nil {
 		// 打印原始响应内容以便调试
-		return "解析响应失败: " + err.Error() + "\n原始响应: " + string(body), 500
+		return nil, "解析响应失败: " + err.Error() + "\n原始响应: " + string(body), 500
 	}
 
 	// 验证响应内容
 	if len(response.Choices) == 0 {
-		return "响应格式正确但没有内容", 500
+		return nil, "响应格式正确但没有内容", 500
 	}
-	return response.Choices[0].Message.Content, 200
+	return &response, "", 200
+}
+
+// ChatStream 以流式方式发送对话请求，每收到一段内容调用一次 onDelta，
+// 每收到一段思维链调用一次 onReasoning（可为空），工具调用在读取完成后拼装返回。
+// 回调返回错误时停止读取，并返回已收到的内容和该错误
+func (c *Client) ChatStream(ctx context.Context, requestBody RequestBody, onDelta func(string) error, onReasoning func(string) error) (*ResponseMessage, error) {
+	requestBody.Stream = true
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("JSON编码失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "text/event-stream")
+	req.Header.Add("Authorization", "Bearer "+c.APIKey)
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &StatusError{Code: requestErrorCode(ctx), Message: "请求失败: " + err.Error()}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return nil, &StatusError{Code: res.StatusCode, Message: "API 请求失败，状态码: " + res.Status + ", 响应内容: " + string(body)}
+	}
+
+	var acc streamAccumulator
+	reader := bufio.NewReader(res.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				return acc.message(), nil
+			}
+			return acc.message(), &StatusError{Code: requestErrorCode(ctx), Message: "读取响应失败: " + err.Error()}
+		}
+
+		// 忽略空行和 keep-alive 注释
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		if string(data) == "[DONE]" {
+			return acc.message(), nil
+		}
+
+		var chunk StreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return acc.message(), &StatusError{Code: 500, Message: "解析响应失败: " + err.Error()}
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta
+		acc.addToolCalls(delta.ToolCalls)
+		// 思维链先于正文输出
+		if delta.ReasoningContent != "" {
+			acc.reasoning.WriteString(delta.ReasoningContent)
+			if onReasoning != nil {
+				if err := onReasoning(delta.ReasoningContent); err != nil {
+					return acc.message(), err
+				}
+			}
+		}
+		if delta.Content != "" {
+			acc.content.WriteString(delta.Content)
+			if onDelta != nil {
+				if err := onDelta(delta.Content); err != nil {
+					return acc.message(), err
+				}
+			}
+		}
+	}
+}
+
+// 请求出错时的状态码：超时视为 504，便于上层重试
+func requestErrorCode(ctx context.Context) int {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return 500
 }
@@ -0,0 +1,70 @@
+package deepseek
+
+import (
+	"strings"
+)
+
+// Tool 请求中声明的工具，目前只支持函数
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction 函数的名称、说明和 JSON Schema 格式的参数
+type ToolFunction struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Parameters  interface{} `json:"parameters"`
+}
+
+// ToolCall 模型发起的一次工具调用
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 被调用的函数及 JSON 格式的参数
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCallDelta 流式响应中的工具调用片段，同一调用的片段 Index 相同，参数分多次返回
+type ToolCallDelta struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// 拼装流式响应中的内容、思维链和工具调用
+type streamAccumulator struct {
+	content   strings.Builder
+	reasoning strings.Builder
+	toolCalls []ToolCall
+}
+
+func (a *streamAccumulator) addToolCalls(deltas []ToolCallDelta) {
+	for _, d := range deltas {
+		for len(a.toolCalls) <= d.Index {
+			a.toolCalls = append(a.toolCalls, ToolCall{Type: "function"})
+		}
+		call := &a.toolCalls[d.Index]
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		if d.Function.Name != "" {
+			call.Function.Name = d.Function.Name
+		}
+		call.Function.Arguments += d.Function.Arguments
+	}
+}
+
+func (a *streamAccumulator) message() *ResponseMessage {
+	return &ResponseMessage{
+		Content:          a.content.String(),
+		ReasoningContent: a.reasoning.String(),
+		ToolCalls:        a.toolCalls,
+	}
+}
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	FormatMarkdown = "md"
+	FormatDOCX     = "docx"
+	FormatPDF      = "pdf"
+
+	timeLayout = "2006-01-02 15:04:05"
+)
+
+// Document 导出的对话记录
+type Document struct {
+	Title      string   `json:"title"`
+	Meta       []string `json:"meta"` // 标题下的说明，如导出时间
+	Entries    []Entry  `json:"entries"`
+	Disclaimer string   `json:"disclaimer"`
+}
+
+// Entry 一条提问或回答
+type Entry struct {
+	Speaker string    `json:"speaker"` // 如“用户”“法务助手”
+	Time    time.Time `json:"time"`
+	Model   string    `json:"model,omitempty"` // 回答的模型，提问时为空
+	Content string    `json:"content"`
+	Sources []Source  `json:"sources,omitempty"`
+}
+
+// Source 回答参考的网页
+type Source struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Formats 支持的导出格式及对应的 Content-Type
+var Formats = map[string]string{
+	FormatMarkdown: "text/markdown; charset=utf-8",
+	FormatDOCX:     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	FormatPDF:      "application/pdf",
+}
+
+// Render 按格式生成文件内容
+func Render(doc *Document, format string) ([]byte, error) {
+	switch format {
+	case FormatMarkdown:
+		return Markdown(doc), nil
+	case FormatDOCX:
+		return DOCX(doc)
+	case FormatPDF:
+		return PDF(doc)
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// 条目的标题行，如“用户 · 2025-03-01 10:00:00”，回答附上模型名称
+func (e Entry) heading() string {
+	parts := []string{e.Speaker, e.Time.Format(timeLayout)}
+	if e.Model != "" {
+		parts = append(parts, e.Model)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Markdown 生成 Markdown，回答内容本身就是 Markdown，原样保留
+func Markdown(doc *Document) []byte {
+	var sb strings.Builder
+	sb.WriteString("# " + doc.Title + "\n\n")
+	for _, m := range doc.Meta {
+		sb.WriteString(m + "  \n")
+	}
+	for _, e := range doc.Entries {
+		sb.WriteString("\n---\n\n")
+		sb.WriteString("### " + e.heading() + "\n\n")
+		sb.WriteString(strings.TrimSpace(e.Content) + "\n")
+		if len(e.Sources) > 0 {
+			sb.WriteString("\n**参考来源**\n\n")
+			for i, s := range e.Sources {
+				sb.WriteString(fmt.Sprintf("%d. [%s](%s)\n", i+1, sourceTitle(s), s.URL))
+			}
+		}
+	}
+	if doc.Disclaimer != "" {
+		sb.WriteString("\n---\n\n*" + doc.Disclaimer + "*\n")
+	}
+	return []byte(sb.String())
+}
+
+func sourceTitle(s Source) string {
+	if s.Title == "" {
+		return s.URL
+	}
+	return s.Title
+}
+
+// 按行拆分内容，去掉行尾空白，合并多余的空行
+func contentLines(content string) []string {
+	raw := strings.Split(strings.ReplaceAll(strings.TrimSpace(content), "\r\n", "\n"), "\n")
+	lines := make([]string, 0, len(raw))
+	for _, line := range raw {
+		line = strings.TrimRight(line, " \t")
+		if line == "" && len(lines) > 0 && lines[len(lines)-1] == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,187 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// A4 页面（单位：点）
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 56.0
+)
+
+// 使用阅读器内置的 Adobe 中文字体 STSong-Light，不需要嵌入字体文件。
+// ASCII 字符为半角宽度，其余按全角计算
+const (
+	pdfType0Font  = `<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>`
+	pdfCIDFont    = `<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor %d 0 R /DW 1000 /W [1 95 500] >>`
+	pdfDescriptor = `<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>`
+)
+
+// 一行文字的样式
+type pdfStyle struct {
+	size  float64
+	gray  float64 // 0 为黑色
+	space float64 // 段前间距
+}
+
+var (
+	pdfTitle   = pdfStyle{size: 18, space: 0}
+	pdfHeading = pdfStyle{size: 11, gray: 0.3, space: 14}
+	pdfBody    = pdfStyle{size: 11, space: 2}
+	pdfNote    = pdfStyle{size: 9, gray: 0.4, space: 2}
+)
+
+// 简单的排版：逐行写入，超出宽度时折行，超出页面时换页
+type pdfWriter struct {
+	pages [][]byte
+	page  *bytes.Buffer
+	y     float64
+}
+
+// PDF 生成 PDF 文档，不依赖外部库
+func PDF(doc *Document) ([]byte, error) {
+	w := &pdfWriter{}
+	w.newPage()
+	w.paragraph(pdfTitle, doc.Title)
+	for _, m := range doc.Meta {
+		w.paragraph(pdfNote, m)
+	}
+	for _, e := range doc.Entries {
+		w.rule(pdfHeading.space)
+		w.paragraph(pdfHeading, e.heading())
+		for _, line := range contentLines(e.Content) {
+			w.paragraph(pdfBody, line)
+		}
+		if len(e.Sources) > 0 {
+			w.paragraph(pdfNote, "参考来源")
+			for i, s := range e.Sources {
+				w.paragraph(pdfNote, fmt.Sprintf("%d. %s  %s", i+1, sourceTitle(s), s.URL))
+			}
+		}
+	}
+	if doc.Disclaimer != "" {
+		w.rule(pdfHeading.space)
+		w.paragraph(pdfNote, doc.Disclaimer)
+	}
+	w.pages = append(w.pages, w.page.Bytes())
+	return w.bytes(), nil
+}
+
+func (w *pdfWriter) newPage() {
+	if w.page != nil {
+		w.pages = append(w.pages, w.page.Bytes())
+	}
+	w.page = &bytes.Buffer{}
+	w.y = pdfPageHeight - pdfMargin
+}
+
+// 写入一个段落，空行只占半行高度
+func (w *pdfWriter) paragraph(style pdfStyle, text string) {
+	lineHeight := style.size * 1.5
+	if text == "" {
+		w.y -= lineHeight / 2
+		return
+	}
+	w.y -= style.space
+	for _, line := range wrapText(text, style.size, pdfPageWidth-2*pdfMargin) {
+		if w.y-lineHeight < pdfMargin {
+			w.newPage()
+		}
+		w.y -= lineHeight
+		fmt.Fprintf(w.page, "BT %.2f g /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n",
+			style.gray, style.size, pdfMargin, w.y+style.size*0.3, encodeUCS2(line))
+	}
+}
+
+// 画一条分隔线
+func (w *pdfWriter) rule(space float64) {
+	if w.y-space-40 < pdfMargin {
+		// 分隔线后至少还能放下标题和一行正文
+		w.newPage()
+		return
+	}
+	w.y -= space
+	fmt.Fprintf(w.page, "0.75 G 0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, w.y, pdfPageWidth-pdfMargin, w.y)
+}
+
+// 组装 PDF 对象和交叉引用表
+func (w *pdfWriter) bytes() []byte {
+	// 1 目录，2 页面树，3-5 字体，之后每页两个对象（页面、内容）
+	const firstPage = 6
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		fmt.Sprintf(pdfType0Font, 4),
+		fmt.Sprintf(pdfCIDFont, 5),
+		pdfDescriptor,
+	}
+	kids := make([]string, 0, len(w.pages))
+	for i, content := range w.pages {
+		pageObj := firstPage + i*2
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObj))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, pageObj+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(w.pages))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// 按宽度折行，宽度以 1/1000 字号为单位：ASCII 为 500，其余为 1000
+func wrapText(text string, size float64, width float64) []string {
+	text = strings.ReplaceAll(text, "\t", "    ")
+	limit := width / size * 1000
+	var lines []string
+	var current strings.Builder
+	used := 0.0
+	for _, r := range text {
+		w := 1000.0
+		if r < utf8.RuneSelf {
+			w = 500
+		}
+		if used+w > limit && current.Len() > 0 {
+			lines = append(lines, current.String())
+			current.Reset()
+			used = 0
+		}
+		current.WriteRune(r)
+		used += w
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+	return lines
+}
+
+// 编码为 UCS-2 大端十六进制字符串，控制字符和基本多文种平面以外的字符替换为问号
+func encodeUCS2(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if r < 0x20 || r > 0xFFFF || (r >= 0xD800 && r <= 0xDFFF) {
+			r = '?'
+		}
+		fmt.Fprintf(&sb, "%04X", r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,148 @@
+package filetext
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MaxFileSize    = 10 * 1024 * 1024 // 可提取文本的最大文件大小
+	MaxDecodedSize = 64 * 1024 * 1024 // docx、pdf 中压缩内容解压后的最大总大小
+)
+
+// ErrUnsupported 不支持提取文本的文件类型
+var ErrUnsupported = errors.New("不支持提取该类型文件的文本")
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// Extract 按扩展名提取文件中的文本，支持 txt、docx 和 pdf（只能提取文字层，扫描件没有文本）
+func Extract(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("文件读取错误: %v", err)
+	}
+	if info.Size() > MaxFileSize {
+		return "", fmt.Errorf("文件大小超过限制")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("文件读取错误: %v", err)
+	}
+
+	var text string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt":
+		text, err = plainText(data)
+	case ".docx":
+		text, err = docxText(data)
+	case ".pdf":
+		text, err = pdfText(data)
+	default:
+		return "", ErrUnsupported
+	}
+	if err != nil {
+		return "", err
+	}
+	return cleanup(text), nil
+}
+
+// 文本文件可能是 UTF-8 或 GBK 编码
+func plainText(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("无法识别文本文件的编码")
+	}
+	return string(decoded), nil
+}
+
+// 读取 word/document.xml 中的文字，段落之间换行
+func docxText(data []byte) (string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("无法解析 docx 文件: %v", err)
+	}
+	var document *zip.File
+	for _, f := range reader.File {
+		if f.Name == "word/document.xml" {
+			document = f
+			break
+		}
+	}
+	if document == nil {
+		return "", fmt.Errorf("无法解析 docx 文件: 缺少 word/document.xml")
+	}
+	rc, err := document.Open()
+	if err != nil {
+		return "", fmt.Errorf("无法解析 docx 文件: %v", err)
+	}
+	defer rc.Close()
+	xmlData, err := io.ReadAll(io.LimitReader(rc, MaxDecodedSize+1))
+	if err != nil {
+		return "", fmt.Errorf("无法解析 docx 文件: %v", err)
+	}
+	if len(xmlData) > MaxDecodedSize {
+		return "", fmt.Errorf("docx 文件解压后的内容过大")
+	}
+
+	var sb strings.Builder
+	decoder := xml.NewDecoder(bytes.NewReader(xmlData))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("无法解析 docx 文件: %v", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+// 统一换行，去掉行尾空白和多余的空行
+func cleanup(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t　")
+	}
+	text = blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
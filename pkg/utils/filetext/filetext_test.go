@@ -0,0 +1,277 @@
+package filetext
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 按顺序拼出 PDF 文件，对象编号从 1 开始，1 号为目录
+func buildPDF(objects []string, trailerExtra string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailerExtra, xref)
+	return buf.Bytes()
+}
+
+func stream(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// 单页文档，content 为页面内容流，resources 为页面资源
+func onePage(content string, resources string, extra ...string) []string {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources " + resources + " /Contents 4 0 R >>",
+		stream("", []byte(content)),
+	}
+	return append(objects, extra...)
+}
+
+const helveticaResources = "<< /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> >>"
+
+// “合同”的 ToUnicode CMap，编码 0001、0002
+const cmap = `/CIDInit /ProcSet findresource begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar <0001> <5408> <0002> <540C> endbfchar
+endcmap`
+
+func TestPDFText(t *testing.T) {
+	valid := buildPDF(onePage("BT /F1 12 Tf (Hello World) Tj ET", helveticaResources), "")
+
+	compressed := buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources " + helveticaResources + " /Contents 4 0 R >>",
+		stream("/Filter /FlateDecode", deflate([]byte("BT /F1 12 Tf (Compressed text) Tj ET"))),
+	}, "")
+
+	toUnicode := buildPDF(onePage("BT /F1 12 Tf <00010002> Tj ET",
+		"<< /Font << /F1 5 0 R >> >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /SimSun /Encoding /Identity-H /ToUnicode 6 0 R >>",
+		stream("", []byte(cmap)),
+	), "")
+
+	// 页面和字体放在对象流中
+	page := "<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >> "
+	objStmHeader := fmt.Sprintf("3 0 4 %d ", len(page))
+	objStmBody := objStmHeader + page + "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+	objStm := buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"null",
+		"null",
+		stream("", []byte("BT /F1 12 Tf (From object stream) Tj ET")),
+		stream(fmt.Sprintf("/Type /ObjStm /N 2 /First %d /Filter /FlateDecode", len(objStmHeader)), deflate([]byte(objStmBody))),
+	}, "")
+	// 3、4 号对象只在对象流中定义，去掉占位的直接定义
+	objStm = bytes.Replace(objStm, []byte("3 0 obj\nnull\nendobj\n"), nil, 1)
+	objStm = bytes.Replace(objStm, []byte("4 0 obj\nnull\nendobj\n"), nil, 1)
+
+	negativeLength := []byte(strings.Replace(string(valid), "/Length 33", "/Length -100000", 1))
+
+	negativeFirst := buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		stream("/Type /ObjStm /N 1 /First -50", []byte("3 0 << /Type /Page >>")),
+	}, "")
+
+	// ParseFloat 接受 NaN，之前会算出负的偏移量
+	nanFirst := buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		stream("/Type /ObjStm /N 1 /First NaN", []byte("4 0 << /Type /Page >>")),
+	}, "")
+
+	bomb := buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources " + helveticaResources + " /Contents 4 0 R >>",
+		stream("/Filter /FlateDecode", deflate(make([]byte, maxStreamSize+1024))),
+	}, "")
+
+	encrypted := buildPDF(onePage("BT /F1 12 Tf (secret) Tj ET", helveticaResources,
+		"<< /Filter /Standard /V 2 /R 3 /O <00> /U <00> /P -4 >>",
+	), "/Encrypt 5 0 R")
+
+	mentionsEncrypt := buildPDF(onePage("BT /F1 12 Tf (The /Encrypt key) Tj ET", helveticaResources), "")
+
+	// 表单 XObject 引用自身多次，嵌套展开后的操作数呈指数增长
+	fanOut := buildPDF(onePage("BT /F1 12 Tf (Start) Tj ET /X0 Do",
+		"<< /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> /XObject << /X0 5 0 R >> >>",
+		stream("/Type /XObject /Subtype /Form /BBox [0 0 1 1]", []byte(strings.Repeat("/X0 Do ", 2000))),
+	), "")
+
+	deepNesting := buildPDF(onePage("BT /F1 12 Tf (Nested) Tj ET "+strings.Repeat("[", 1000000), helveticaResources), "")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string // 提取结果应包含的文字
+		wantErr error  // 为 nil 时要求成功
+		anyErr  bool   // 只要求不 panic，允许返回错误
+	}{
+		{name: "valid", data: valid, want: "Hello World"},
+		{name: "flate", data: compressed, want: "Compressed text"},
+		{name: "to unicode", data: toUnicode, want: "合同"},
+		{name: "object stream", data: objStm, want: "From object stream"},
+		{name: "truncated", data: valid[:len(valid)/2], anyErr: true},
+		{name: "truncated object stream", data: objStm[:len(objStm)*2/3], anyErr: true},
+		{name: "negative length", data: negativeLength, want: "Hello World"},
+		{name: "negative first", data: negativeFirst, wantErr: errNoText},
+		{name: "nan first", data: nanFirst, wantErr: errNoText},
+		{name: "zlib bomb", data: bomb, wantErr: errTooLarge},
+		{name: "encrypted", data: encrypted, wantErr: errEncrypted},
+		{name: "encrypt in text", data: mentionsEncrypt, want: "The /Encrypt key"},
+		{name: "form fan-out", data: fanOut, want: "Start"},
+		{name: "deep nesting", data: deepNesting, want: "Nested"},
+		{name: "garbage", data: []byte("%PDF-1.4\n1 0 obj << /Type /Catalog /Pages 2 0 R >> stream\n"), anyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := pdfText(tt.data)
+			if tt.anyErr {
+				return
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(text, tt.want) {
+				t.Fatalf("text = %q, want to contain %q", text, tt.want)
+			}
+		})
+	}
+}
+
+// 解析任意输入都不能 panic，也不能超出解压和操作数的限制：
+//
+//	go test -fuzz=FuzzPDFText ./pkg/utils/filetext/
+func FuzzPDFText(f *testing.F) {
+	f.Add(buildPDF(onePage("BT /F1 12 Tf (Hello World) Tj ET", helveticaResources), ""))
+	f.Add(buildPDF(onePage("BT /F1 12 Tf <00010002> Tj ET",
+		"<< /Font << /F1 5 0 R >> >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /SimSun /Encoding /Identity-H /ToUnicode 6 0 R >>",
+		stream("", []byte(cmap)),
+	), ""))
+	f.Add(buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources " + helveticaResources + " /Contents 4 0 R >>",
+		stream("/Filter /FlateDecode", deflate([]byte("BT /F1 12 Tf (Compressed text) Tj ET"))),
+	}, ""))
+	f.Add(buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		stream("/Type /ObjStm /N 1 /First 6", []byte("3 0 << /Type /Page /Contents 4 0 R >>")),
+		stream("", []byte("BT (x) Tj BI /W 1 ID \x00 EI ET [<0A> -300 (y)] TJ")),
+	}, ""))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		text, err := pdfText(data)
+		if err == nil && strings.TrimSpace(text) == "" {
+			t.Fatal("empty text without error")
+		}
+	})
+}
+
+func buildDocx(t *testing.T, documentXML []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(documentXML); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("租赁合同\r\n第一条 租期一年"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>借款合同</w:t></w:r></w:p>
+<w:p><w:r><w:t>甲方</w:t><w:tab/><w:t>张三</w:t></w:r></w:p>
+</w:body></w:document>`)
+	bombXML := append([]byte(`<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>`), bytes.Repeat([]byte(" "), MaxDecodedSize)...)
+	bombXML = append(bombXML, []byte(`</w:t></w:r></w:p></w:body></w:document>`)...)
+
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "utf-8 txt", file: "a.txt", data: []byte("\xef\xbb\xbf定金条款\r\n\r\n\r\n\r\n违约责任"), want: "定金条款\n\n违约责任"},
+		{name: "gbk txt", file: "b.txt", data: gbk, want: "租赁合同\n第一条 租期一年"},
+		{name: "docx", file: "c.docx", data: buildDocx(t, document), want: "借款合同\n甲方\t张三"},
+		{name: "docx bomb", file: "d.docx", data: buildDocx(t, bombXML), wantErr: true},
+		{name: "broken docx", file: "e.docx", data: []byte("not a zip"), wantErr: true},
+		{name: "pdf", file: "f.pdf", data: buildPDF(onePage("BT /F1 12 Tf (Lease) Tj ET", helveticaResources), ""), want: "Lease"},
+		{name: "unsupported", file: "g.png", data: []byte{0x89, 'P', 'N', 'G'}, wantErr: true},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			text, err := Extract(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got text %q", text)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if text != tt.want {
+				t.Fatalf("text = %q, want %q", text, tt.want)
+			}
+		})
+	}
+}
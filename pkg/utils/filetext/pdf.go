@@ -0,0 +1,846 @@
+package filetext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 只实现提取文字需要的部分：对象和对象流、FlateDecode、页面树、字体的 ToUnicode 和 UCS2 编码。
+// 没有 ToUnicode 的 Identity-H 字体无法还原文字，会被跳过
+
+var (
+	errEncrypted = errors.New("PDF 文件已加密，无法提取文本")
+	errNoText    = errors.New("PDF 文件中没有可提取的文字，可能是扫描件")
+	errTooLarge  = errors.New("PDF 文件解压后的内容过大")
+
+	objectPattern  = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	trailerPattern = regexp.MustCompile(`\btrailer\b`)
+)
+
+const (
+	maxFormDepth    = 5                // 表单 XObject 最多嵌套的层数
+	maxStreamSize   = 16 * 1024 * 1024 // 单个流解压后的最大大小
+	maxOperations   = 1000000          // 全部内容流中最多执行的操作符数，防止表单 XObject 被大量重复引用
+	maxNestingDepth = 100              // 数组和字典最多嵌套的层数
+	maxCMapEntries  = 1 << 17          // 单个字体 ToUnicode 映射的最大条目数，足够覆盖常用汉字
+)
+
+// PDF 对象：nil、bool、float64、pdfName、pdfString、pdfRef、[]interface{}、pdfDict、*pdfStream、pdfKeyword
+type (
+	pdfName    string
+	pdfString  string
+	pdfKeyword string
+	pdfDict    map[pdfName]interface{}
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		raw  []byte
+	}
+)
+
+type pdfDoc struct {
+	objects    map[int]interface{}
+	fonts      map[pdfRef]*pdfFont
+	trailers   []pdfDict
+	decoded    int   // 已解压的总字节数
+	operations int   // 已执行的操作符数
+	err        error // 解压超出限制等无法继续提取的错误
+}
+
+// 解析器处理的是不可信的上传文件，所有下标和长度都要检查，见 FuzzPDFText
+func pdfText(data []byte) (string, error) {
+	doc := &pdfDoc{objects: make(map[int]interface{}), fonts: make(map[pdfRef]*pdfFont)}
+	doc.load(data)
+	if doc.encrypted() {
+		return "", errEncrypted
+	}
+
+	var sb strings.Builder
+	for _, page := range doc.pages() {
+		doc.pageText(&sb, page)
+		sb.WriteString("\n\n")
+	}
+	if doc.err != nil {
+		return "", doc.err
+	}
+	text := sb.String()
+	if strings.TrimSpace(text) == "" {
+		return "", errNoText
+	}
+	return text, nil
+}
+
+// 读取全部间接对象，后出现的定义（增量更新）覆盖之前的；再展开对象流中的对象
+func (d *pdfDoc) load(data []byte) {
+	for _, m := range objectPattern.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		lex := &pdfLexer{data: data, pos: m[1]}
+		obj := lex.object()
+		if dict, ok := obj.(pdfDict); ok && lex.keyword("stream") {
+			obj = &pdfStream{dict: dict, raw: streamData(data, lex.pos, dict)}
+		}
+		d.objects[num] = obj
+	}
+	// 传统的 trailer 字典，交叉引用流的字典在对象中
+	for _, m := range trailerPattern.FindAllIndex(data, -1) {
+		if dict, ok := (&pdfLexer{data: data, pos: m[1]}).object().(pdfDict); ok {
+			d.trailers = append(d.trailers, dict)
+		}
+	}
+
+	for _, obj := range d.objects {
+		stream, ok := obj.(*pdfStream)
+		if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		content := d.decode(stream)
+		first := d.number(stream.dict["First"])
+		if first < 0 || first >= float64(len(content)) {
+			continue
+		}
+		n := d.number(stream.dict["N"])
+		header := &pdfLexer{data: content}
+		for i := 0; float64(i) < n; i++ {
+			num, ok1 := header.object().(float64)
+			offset, ok2 := header.object().(float64)
+			if !ok1 || !ok2 || offset < 0 || first+offset >= float64(len(content)) {
+				break
+			}
+			if _, exists := d.objects[int(num)]; !exists {
+				d.objects[int(num)] = (&pdfLexer{data: content, pos: int(first + offset)}).object()
+			}
+		}
+	}
+}
+
+// 文件是否加密：trailer 或交叉引用流的字典中有 /Encrypt
+func (d *pdfDoc) encrypted() bool {
+	for _, trailer := range d.trailers {
+		if _, ok := trailer["Encrypt"]; ok {
+			return true
+		}
+	}
+	for _, obj := range d.objects {
+		if stream, ok := obj.(*pdfStream); ok && stream.dict["Type"] == pdfName("XRef") {
+			if _, ok := stream.dict["Encrypt"]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stream 关键字之后的数据，/Length 不是直接给出或不可靠时查找 endstream
+func streamData(data []byte, pos int, dict pdfDict) []byte {
+	if pos < 0 || pos > len(data) {
+		return nil
+	}
+	if pos < len(data) && data[pos] == '\r' {
+		pos++
+	}
+	if pos < len(data) && data[pos] == '\n' {
+		pos++
+	}
+	if length, ok := dict["Length"].(float64); ok && length >= 0 && length <= float64(len(data)-pos) {
+		end := pos + int(length)
+		if bytes.HasPrefix(bytes.TrimLeft(data[end:], "\r\n \t"), []byte("endstream")) {
+			return data[pos:end]
+		}
+	}
+	end := bytes.Index(data[pos:], []byte("endstream"))
+	if end < 0 {
+		return data[pos:]
+	}
+	return bytes.TrimRight(data[pos:pos+end], "\r\n")
+}
+
+// 解析间接引用
+func (d *pdfDoc) resolve(obj interface{}) interface{} {
+	for i := 0; i < 10; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = d.objects[ref.num]
+	}
+	return nil
+}
+
+func (d *pdfDoc) dict(obj interface{}) pdfDict {
+	switch v := d.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+func (d *pdfDoc) number(obj interface{}) float64 {
+	n, _ := d.resolve(obj).(float64)
+	return n
+}
+
+// 解码流数据，只支持 FlateDecode，其他过滤器（图片等）返回空。
+// 单个流和全部流解压后的大小都有上限，超出时记录错误并返回空
+func (d *pdfDoc) decode(stream *pdfStream) []byte {
+	if d.err != nil {
+		return nil
+	}
+	var filters []interface{}
+	switch f := d.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{f}
+	case []interface{}:
+		filters = f
+	}
+	data := stream.raw
+	for _, f := range filters {
+		if d.resolve(f) != pdfName("FlateDecode") {
+			return nil
+		}
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil
+		}
+		limit := maxStreamSize
+		if remaining := MaxDecodedSize - d.decoded; remaining < limit {
+			limit = remaining
+		}
+		// 部分文件的压缩数据末尾不完整，保留已解压的部分
+		data, _ = io.ReadAll(io.LimitReader(r, int64(limit)+1))
+		r.Close()
+		if len(data) > limit {
+			d.err = errTooLarge
+			return nil
+		}
+		d.decoded += len(data)
+	}
+	return data
+}
+
+// 页面对象及其继承的资源，按页面树的顺序
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+func (d *pdfDoc) pages() []pdfPage {
+	var root pdfDict
+	for _, obj := range d.objects {
+		if dict := d.dict(obj); dict != nil && dict["Type"] == pdfName("Catalog") {
+			root = d.dict(dict["Pages"])
+			break
+		}
+	}
+	var pages []pdfPage
+	if root != nil {
+		d.walkPages(root, nil, &pages, 0)
+		return pages
+	}
+	// 没有目录时按对象编号排列页面
+	nums := make([]int, 0)
+	for num, obj := range d.objects {
+		if dict := d.dict(obj); dict != nil && dict["Type"] == pdfName("Page") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		dict := d.dict(d.objects[num])
+		pages = append(pages, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+	}
+	return pages
+}
+
+func (d *pdfDoc) walkPages(node pdfDict, inherited pdfDict, pages *[]pdfPage, depth int) {
+	if depth > 32 {
+		return
+	}
+	resources := inherited
+	if r := d.dict(node["Resources"]); r != nil {
+		resources = r
+	}
+	if node["Type"] == pdfName("Page") {
+		*pages = append(*pages, pdfPage{dict: node, resources: resources})
+		return
+	}
+	kids, _ := d.resolve(node["Kids"]).([]interface{})
+	for _, kid := range kids {
+		if dict := d.dict(kid); dict != nil {
+			d.walkPages(dict, resources, pages, depth+1)
+		}
+	}
+}
+
+// 页面的内容流可以是一个流或流的数组
+func (d *pdfDoc) pageText(sb *strings.Builder, page pdfPage) {
+	var content []byte
+	switch v := d.resolve(page.dict["Contents"]).(type) {
+	case *pdfStream:
+		content = d.decode(v)
+	case []interface{}:
+		for _, item := range v {
+			if stream, ok := d.resolve(item).(*pdfStream); ok {
+				content = append(content, d.decode(stream)...)
+				content = append(content, '\n')
+			}
+		}
+	}
+	d.contentText(sb, content, page.resources, 0)
+}
+
+// 执行内容流中与文字有关的操作符
+func (d *pdfDoc) contentText(sb *strings.Builder, content []byte, resources pdfDict, depth int) {
+	fonts := d.dict(resources["Font"])
+	var font *pdfFont
+	var operands []interface{}
+	lex := &pdfLexer{data: content}
+	for {
+		obj, ok := lex.next()
+		if !ok {
+			break
+		}
+		op, isOp := obj.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, obj)
+			continue
+		}
+		if d.operations++; d.operations > maxOperations {
+			return
+		}
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					font = d.font(fonts[name])
+				}
+			}
+		case "Tj":
+			if len(operands) >= 1 {
+				sb.WriteString(font.decode(operands[0]))
+			}
+		case "'", "\"":
+			sb.WriteString("\n")
+			if len(operands) >= 1 {
+				sb.WriteString(font.decode(operands[len(operands)-1]))
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				items, _ := operands[0].([]interface{})
+				for _, item := range items {
+					// 较大的负间距通常是单词之间的空格
+					if n, ok := item.(float64); ok && n < -200 && (font == nil || font.codeLength() == 1) {
+						sb.WriteString(" ")
+						continue
+					}
+					sb.WriteString(font.decode(item))
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[1].(float64); ok && ty != 0 {
+					sb.WriteString("\n")
+				}
+			}
+		case "T*", "ET":
+			sb.WriteString("\n")
+		case "Do":
+			if len(operands) >= 1 && depth < maxFormDepth {
+				name, _ := operands[0].(pdfName)
+				xobjects := d.dict(resources["XObject"])
+				if form, ok := d.resolve(xobjects[name]).(*pdfStream); ok && form.dict["Subtype"] == pdfName("Form") {
+					formResources := d.dict(form.dict["Resources"])
+					if formResources == nil {
+						formResources = resources
+					}
+					d.contentText(sb, d.decode(form), formResources, depth+1)
+				}
+			}
+		case "BI":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// 读取字体的编码方式，同一字体只解析一次
+func (d *pdfDoc) font(obj interface{}) *pdfFont {
+	ref, isRef := obj.(pdfRef)
+	if isRef {
+		if f, ok := d.fonts[ref]; ok {
+			return f
+		}
+	}
+	f := &pdfFont{}
+	dict := d.dict(obj)
+	if dict != nil {
+		encoding, _ := d.resolve(dict["Encoding"]).(pdfName)
+		f.ucs2 = strings.Contains(string(encoding), "UCS2")
+		f.twoByte = dict["Subtype"] == pdfName("Type0")
+		if stream, ok := d.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+			f.parseCMap(d.decode(stream))
+		}
+	}
+	if isRef {
+		d.fonts[ref] = f
+	}
+	return f
+}
+
+// 字体的编码：ToUnicode 映射优先，其次是 UCS2 编码的 CMap，单字节字体按 Latin-1 处理
+type pdfFont struct {
+	cmap    map[string]string
+	lengths []int // 编码的字节数，来自 codespacerange
+	ucs2    bool
+	twoByte bool
+}
+
+func (f *pdfFont) codeLength() int {
+	if f.twoByte || f.ucs2 {
+		return 2
+	}
+	if len(f.lengths) > 0 {
+		return f.lengths[0]
+	}
+	return 1
+}
+
+func (f *pdfFont) decode(obj interface{}) string {
+	s, ok := obj.(pdfString)
+	if !ok {
+		return ""
+	}
+	if f == nil {
+		return latin1(string(s))
+	}
+	if len(f.cmap) > 0 {
+		lengths := f.lengths
+		if len(lengths) == 0 {
+			lengths = []int{f.codeLength()}
+		}
+		var sb strings.Builder
+		for i := 0; i < len(s); {
+			matched := false
+			for _, n := range lengths {
+				if i+n <= len(s) {
+					if u, ok := f.cmap[string(s[i:i+n])]; ok {
+						sb.WriteString(u)
+						i += n
+						matched = true
+						break
+					}
+				}
+			}
+			if !matched {
+				i += lengths[0]
+			}
+		}
+		return sb.String()
+	}
+	if f.ucs2 {
+		return utf16BE([]byte(s))
+	}
+	if f.twoByte {
+		return ""
+	}
+	return latin1(string(s))
+}
+
+// 解析 ToUnicode CMap 中的 codespacerange、bfchar 和 bfrange
+func (f *pdfFont) parseCMap(data []byte) {
+	f.cmap = make(map[string]string)
+	lengths := make(map[int]bool)
+	var operands []interface{}
+	lex := &pdfLexer{data: data}
+	for {
+		obj, ok := lex.next()
+		if !ok {
+			break
+		}
+		op, isOp := obj.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, obj)
+			continue
+		}
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].(pdfString); ok && len(lo) > 0 {
+					lengths[len(lo)] = true
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(f.cmap) < maxCMapEntries {
+					f.cmap[string(src)] = utf16BE([]byte(dst))
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+					continue
+				}
+				start, end := codeValue(lo), codeValue(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				for code := start; code <= end && len(f.cmap) < maxCMapEntries; code++ {
+					var dst []byte
+					switch v := operands[i+2].(type) {
+					case pdfString:
+						dst = []byte(v)
+						if len(dst) >= 2 {
+							dst = append([]byte{}, dst...)
+							last := int(dst[len(dst)-2])<<8 | int(dst[len(dst)-1])
+							last += code - start
+							dst[len(dst)-2], dst[len(dst)-1] = byte(last>>8), byte(last)
+						}
+					case []interface{}:
+						if code-start < len(v) {
+							s, _ := v[code-start].(pdfString)
+							dst = []byte(s)
+						}
+					}
+					f.cmap[codeBytes(code, len(lo))] = utf16BE(dst)
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	for n := range lengths {
+		f.lengths = append(f.lengths, n)
+	}
+	sort.Ints(f.lengths)
+}
+
+func codeValue(b pdfString) int {
+	v := 0
+	for i := 0; i < len(b); i++ {
+		v = v<<8 | int(b[i])
+	}
+	return v
+}
+
+func codeBytes(v int, n int) string {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return string(b)
+}
+
+func utf16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func latin1(s string) string {
+	runes := make([]rune, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x20 || s[i] == '\t' {
+			runes = append(runes, rune(s[i]))
+		}
+	}
+	return string(runes)
+}
+
+// pdfLexer 读取 PDF 对象和内容流中的记号
+type pdfLexer struct {
+	data  []byte
+	pos   int
+	depth int // 当前数组和字典的嵌套层数
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFSpace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// keyword 下一个记号是指定的关键字时读取它
+func (l *pdfLexer) keyword(word string) bool {
+	save := l.pos
+	if obj, ok := l.next(); ok && obj == pdfKeyword(word) {
+		return true
+	}
+	l.pos = save
+	return false
+}
+
+// object 读取一个对象，数字后跟“gen R”时读取为引用
+func (l *pdfLexer) object() interface{} {
+	obj, _ := l.next()
+	if n, ok := obj.(float64); ok {
+		save := l.pos
+		if gen, ok := l.next(); ok {
+			if g, ok := gen.(float64); ok && l.keyword("R") {
+				return pdfRef{num: int(n), gen: int(g)}
+			}
+		}
+		l.pos = save
+	}
+	return obj
+}
+
+// next 读取下一个记号，数组和字典读取为完整的对象
+func (l *pdfLexer) next() (interface{}, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(unescapeName(string(l.data[start:l.pos]))), true
+	case c == '(':
+		return l.literalString(), true
+	case (c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' || c == '[') && l.depth >= maxNestingDepth:
+		// 嵌套过深的对象视为损坏，跳过其余内容
+		l.pos = len(l.data)
+		return nil, false
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		l.depth++
+		defer func() { l.depth-- }()
+		dict := make(pdfDict)
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return dict, true
+			}
+			if l.data[l.pos] == '>' {
+				l.pos++
+				if l.pos < len(l.data) && l.data[l.pos] == '>' {
+					l.pos++
+				}
+				return dict, true
+			}
+			key, ok := l.next()
+			if !ok {
+				return dict, true
+			}
+			name, isName := key.(pdfName)
+			value := l.object()
+			if isName {
+				dict[name] = value
+			}
+		}
+	case c == '<':
+		return l.hexString(), true
+	case c == '[':
+		l.pos++
+		l.depth++
+		defer func() { l.depth-- }()
+		array := make([]interface{}, 0)
+		for {
+			l.skipSpace()
+			if l.pos >= len(l.data) {
+				return array, true
+			}
+			if l.data[l.pos] == ']' {
+				l.pos++
+				return array, true
+			}
+			array = append(array, l.object())
+		}
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(string(c)), true
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	token := string(l.data[start:l.pos])
+	// PDF 的数字只有十进制写法，ParseFloat 接受的 NaN、Inf 和十六进制不是数字
+	if isPDFNumber(token) {
+		if n, err := strconv.ParseFloat(token, 64); err == nil {
+			return n, true
+		}
+	}
+	switch token {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	case "null":
+		return nil, true
+	}
+	return pdfKeyword(token), true
+}
+
+func isPDFNumber(token string) bool {
+	if token == "" {
+		return false
+	}
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if !(c >= '0' && c <= '9' || c == '.' || (c == '+' || c == '-') && i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++ // (
+	var buf []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(buf)
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return pdfString(buf)
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b':
+				buf = append(buf, '\b')
+			case 'f':
+				buf = append(buf, '\f')
+			case '\r':
+				// 续行
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					buf = append(buf, byte(v))
+				} else {
+					buf = append(buf, e)
+				}
+			}
+			continue
+		}
+		buf = append(buf, c)
+	}
+	return pdfString(buf)
+}
+
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	if l.pos < len(l.data) {
+		l.pos++ // >
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	buf := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		v, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			break
+		}
+		buf = append(buf, byte(v))
+	}
+	return pdfString(buf)
+}
+
+// 跳过内联图片：BI 参数 ID 二进制数据 EI
+func (l *pdfLexer) skipInlineImage() {
+	i := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if i < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += i + 2
+	for l.pos < len(l.data) {
+		j := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if j < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		end := l.pos + j
+		l.pos = end + 2
+		if end > 0 && isPDFSpace(l.data[end-1]) && (l.pos >= len(l.data) || isPDFSpace(l.data[l.pos])) {
+			return
+		}
+	}
+}
+
+// 名称中的 #xx 转义
+func unescapeName(s string) string {
+	if !strings.Contains(s, "#") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '#' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				sb.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
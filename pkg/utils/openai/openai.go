@@ -0,0 +1,246 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client OpenAI 兼容接口客户端，可对接 llama.cpp、vLLM 等自部署服务
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// ChatMessage 对话消息
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// 推理模型的思维链（vLLM 等开启 reasoning parser 时返回），请求中不发送
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`   // assistant 消息中的工具调用
+	ToolCallID       string     `json:"tool_call_id,omitempty"` // tool 消息对应的调用ID
+}
+
+// ChatCompletionRequest /v1/chat/completions 请求体
+type ChatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []ChatMessage `json:"messages"`
+	Temperature float64       `json:"temperature,omitempty"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Stream      bool          `json:"stream"`
+	Tools       []Tool        `json:"tools,omitempty"`
+	ToolChoice  string        `json:"tool_choice,omitempty"`
+	// ResponseFormat 为 {"type": "json_object"} 时要求模型输出 JSON
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat 输出格式
+type ResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// ChatCompletionResponse /v1/chat/completions 响应体
+type ChatCompletionResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      ChatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// ChatCompletionChunk 流式响应中的单个数据块
+type ChatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string          `json:"content"`
+			ReasoningContent string          `json:"reasoning_content"`
+			ToolCalls        []ToolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// EmbeddingRequest /v1/embeddings 请求体
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// EmbeddingResponse /v1/embeddings 响应体
+type EmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+// StatusError 接口返回了非 200 状态码
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// NewClient 创建客户端，baseURL 形如 http://127.0.0.1:8000，带不带 /v1 后缀均可
+func NewClient(baseURL, apiKey string) *Client {
+	baseURL = strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/v1")
+	return &Client{
+		BaseURL: baseURL,
+		APIKey:  apiKey,
+		HTTPClient: &http.Client{
+			Timeout: 5 * time.Minute,
+		},
+	}
+}
+
+// 发送 JSON 请求，非 200 状态码转换为 StatusError
+func (c *Client) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("JSON编码失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+path, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &StatusError{Code: requestErrorCode(ctx), Message: "请求失败: " + err.Error()}
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return nil, &StatusError{Code: res.StatusCode, Message: "API 请求失败，状态码: " + res.Status + ", 响应内容: " + string(data)}
+	}
+	return res, nil
+}
+
+// Chat 发送非流式对话请求
+func (c *Client) Chat(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	req.Stream = false
+	res, err := c.post(ctx, "/v1/chat/completions", req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var response ChatCompletionResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, &StatusError{Code: requestErrorCode(ctx), Message: "解析响应失败: " + err.Error()}
+	}
+	if len(response.Choices) == 0 {
+		return nil, &StatusError{Code: 500, Message: "响应格式正确但没有内容"}
+	}
+	return &response, nil
+}
+
+// ChatStream 发送流式对话请求，每收到一段内容调用一次 onDelta，每收到一段思维链调用一次 onReasoning（可为空），
+// 工具调用在读取完成后拼装返回。回调返回错误时停止读取，并返回已收到的内容和该错误
+func (c *Client) ChatStream(ctx context.Context, req ChatCompletionRequest, onDelta func(string) error, onReasoning func(string) error) (*ChatMessage, error) {
+	req.Stream = true
+	res, err := c.post(ctx, "/v1/chat/completions", req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var acc streamAccumulator
+	reader := bufio.NewReader(res.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				return acc.message(), nil
+			}
+			return acc.message(), &StatusError{Code: requestErrorCode(ctx), Message: "读取响应失败: " + err.Error()}
+		}
+
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		if string(data) == "[DONE]" {
+			return acc.message(), nil
+		}
+
+		var chunk ChatCompletionChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return acc.message(), &StatusError{Code: 500, Message: "解析响应失败: " + err.Error()}
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta
+		acc.addToolCalls(delta.ToolCalls)
+		if delta.ReasoningContent != "" {
+			acc.reasoning.WriteString(delta.ReasoningContent)
+			if onReasoning != nil {
+				if err := onReasoning(delta.ReasoningContent); err != nil {
+					return acc.message(), err
+				}
+			}
+		}
+		if delta.Content != "" {
+			acc.content.WriteString(delta.Content)
+			if onDelta != nil {
+				if err := onDelta(delta.Content); err != nil {
+					return acc.message(), err
+				}
+			}
+		}
+	}
+}
+
+// Embeddings 批量生成文本向量，返回顺序与 input 一致
+func (c *Client) Embeddings(ctx context.Context, model string, input []string) ([][]float64, error) {
+	res, err := c.post(ctx, "/v1/embeddings", EmbeddingRequest{Model: model, Input: input})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var response EmbeddingResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, &StatusError{Code: 500, Message: "解析响应失败: " + err.Error()}
+	}
+	if len(response.Data) != len(input) {
+		return nil, &StatusError{Code: 500, Message: fmt.Sprintf("向量数量(%d)与输入数量(%d)不匹配", len(response.Data), len(input))}
+	}
+
+	vectors := make([][]float64, len(input))
+	for _, d := range response.Data {
+		if d.Index < 0 || d.Index >= len(input) {
+			return nil, &StatusError{Code: 500, Message: fmt.Sprintf("无效的向量下标: %d", d.Index)}
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// 请求出错时的状态码：超时视为 504，便于上层重试
+func requestErrorCode(ctx context.Context) int {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return 500
+}
@@ -0,0 +1,218 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 启动模拟的 OpenAI 兼容服务，handler 收到解析后的请求体
+func newServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, body map[string]interface{})) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		handler(w, r, body)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL+"/v1/", "test-key")
+}
+
+// 按 SSE 格式写出数据块，最后写出 [DONE]
+func writeSSE(w http.ResponseWriter, chunks ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, c := range chunks {
+		fmt.Fprintf(w, "data: %s\n\n", c)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func TestChat(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if body["stream"] != false || body["model"] != "qwen" {
+			t.Errorf("body = %v", body)
+		}
+		if format, _ := body["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+			t.Errorf("response_format = %v", body["response_format"])
+		}
+		fmt.Fprint(w, `{"model":"qwen","choices":[{"message":{"role":"assistant","content":"你好","tool_calls":[{"id":"call_1","type":"function","function":{"name":"search","arguments":"{\"q\":\"租赁\"}"}}]},"finish_reason":"tool_calls"}]}`)
+	})
+
+	resp, err := c.Chat(context.Background(), ChatCompletionRequest{
+		Model:          "qwen",
+		Messages:       []ChatMessage{{Role: "user", Content: "你好"}},
+		Stream:         true,
+		ResponseFormat: &ResponseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	msg := resp.Choices[0].Message
+	if msg.Content != "你好" || len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Arguments != `{"q":"租赁"}` {
+		t.Fatalf("message = %+v", msg)
+	}
+}
+
+func TestChatErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantCode int
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized, body: `{"error":"invalid key"}`, wantCode: 401},
+		{name: "bad request", status: http.StatusBadRequest, body: `{"error":"context too long"}`, wantCode: 400},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{}`, wantCode: 429},
+		{name: "server error", status: http.StatusInternalServerError, body: `oops`, wantCode: 500},
+		{name: "empty choices", status: http.StatusOK, body: `{"choices":[]}`, wantCode: 500},
+		{name: "invalid json", status: http.StatusOK, body: `{"choices":`, wantCode: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+			_, err := c.Chat(context.Background(), ChatCompletionRequest{Model: "qwen"})
+			var se *StatusError
+			if !errors.As(err, &se) {
+				t.Fatalf("err = %v, want *StatusError", err)
+			}
+			if se.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d", se.Code, tt.wantCode)
+			}
+			if tt.status != http.StatusOK && !strings.Contains(se.Message, tt.body) {
+				t.Fatalf("message %q does not contain response body", se.Message)
+			}
+		})
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		if body["stream"] != true {
+			t.Errorf("stream = %v", body["stream"])
+		}
+		writeSSE(w,
+			`{"choices":[{"delta":{"reasoning_content":"想一想"}}]}`,
+			`{"choices":[{"delta":{"content":"根据"}}]}`,
+			`{"choices":[]}`,
+			`{"choices":[{"delta":{"content":"民法典"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"search","arguments":"{\"q\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"租赁\"}"}}]},"finish_reason":"tool_calls"}]}`,
+		)
+	})
+
+	var deltas, reasoning []string
+	msg, err := c.ChatStream(context.Background(), ChatCompletionRequest{Model: "qwen"},
+		func(s string) error { deltas = append(deltas, s); return nil },
+		func(s string) error { reasoning = append(reasoning, s); return nil })
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if !reflect.DeepEqual(deltas, []string{"根据", "民法典"}) || !reflect.DeepEqual(reasoning, []string{"想一想"}) {
+		t.Fatalf("deltas = %v, reasoning = %v", deltas, reasoning)
+	}
+	if msg.Content != "根据民法典" || msg.ReasoningContent != "想一想" {
+		t.Fatalf("message = %+v", msg)
+	}
+	want := []ToolCall{{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "search", Arguments: `{"q":"租赁"}`}}}
+	if !reflect.DeepEqual(msg.ToolCalls, want) {
+		t.Fatalf("tool calls = %+v, want %+v", msg.ToolCalls, want)
+	}
+}
+
+func TestChatStreamStopped(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		writeSSE(w, `{"choices":[{"delta":{"content":"第一段"}}]}`, `{"choices":[{"delta":{"content":"第二段"}}]}`)
+	})
+	stop := errors.New("client gone")
+	msg, err := c.ChatStream(context.Background(), ChatCompletionRequest{Model: "qwen"},
+		func(s string) error { return stop }, nil)
+	if !errors.Is(err, stop) {
+		t.Fatalf("err = %v, want %v", err, stop)
+	}
+	if msg == nil || msg.Content != "第一段" {
+		t.Fatalf("message = %+v, want partial content", msg)
+	}
+}
+
+func TestChatStreamErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  func(w http.ResponseWriter)
+		wantCode int
+		partial  string
+	}{
+		{
+			name: "non-2xx",
+			handler: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, "overloaded")
+			},
+			wantCode: 503,
+		},
+		{
+			name: "invalid chunk",
+			handler: func(w http.ResponseWriter) {
+				fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"部分\"}}]}\n\ndata: {broken\n\n")
+			},
+			wantCode: 500,
+			partial:  "部分",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+				tt.handler(w)
+			})
+			msg, err := c.ChatStream(context.Background(), ChatCompletionRequest{Model: "qwen"}, nil, nil)
+			var se *StatusError
+			if !errors.As(err, &se) || se.Code != tt.wantCode {
+				t.Fatalf("err = %v, want code %d", err, tt.wantCode)
+			}
+			if tt.partial != "" && (msg == nil || msg.Content != tt.partial) {
+				t.Fatalf("message = %+v, want partial %q", msg, tt.partial)
+			}
+		})
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		// 故意打乱返回顺序，结果应按 index 排列
+		fmt.Fprint(w, `{"data":[{"index":1,"embedding":[0.3,0.4]},{"index":0,"embedding":[0.1,0.2]}]}`)
+	})
+	vectors, err := c.Embeddings(context.Background(), "bge", []string{"甲", "乙"})
+	if err != nil {
+		t.Fatalf("Embeddings: %v", err)
+	}
+	want := [][]float64{{0.1, 0.2}, {0.3, 0.4}}
+	if !reflect.DeepEqual(vectors, want) {
+		t.Fatalf("vectors = %v, want %v", vectors, want)
+	}
+
+	c = newServer(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		fmt.Fprint(w, `{"data":[{"index":0,"embedding":[0.1]}]}`)
+	})
+	if _, err := c.Embeddings(context.Background(), "bge", []string{"甲", "乙"}); err == nil {
+		t.Fatal("expected error for mismatched vector count")
+	}
+}